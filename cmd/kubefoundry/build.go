@@ -30,13 +30,21 @@ var buildCmd = &cobra.Command{
 func build(command *cobra.Command, args []string) error {
 	//p, _ := command.Flags().GetString("example")
 	// TODO add more args (apart of the ones defined in the configuration file)
+	force, _ := command.Flags().GetBool("force")
+	platforms, _ := command.Flags().GetStringSlice("platform")
+	baseImagePolicy, _ := command.Flags().GetString("base-image-policy")
+	buildpacksPolicy, _ := command.Flags().GetString("buildpack-policy")
 	err := program.LoadConfig()
 	if err == nil {
-		err = program.BuildAppImage()
+		err = program.BuildAppImage(force, platforms, baseImagePolicy, buildpacksPolicy)
 	}
 	return err
 }
 
 func init() {
+	buildCmd.PersistentFlags().Bool("force", false, "Build the image even if there is one with the same source")
+	buildCmd.PersistentFlags().StringSlice("platform", []string{}, "Build the image for the platforms, eg: linux/amd64,linux/arm64")
+	buildCmd.PersistentFlags().String("base-image-policy", "", "Base image digest: pinned (lock file) or latest (update lock file)")
+	buildCmd.PersistentFlags().String("buildpack-policy", "", "Buildpacks versions: pinned (lock file) or latest (update lock file)")
 	Cmd.AddCommand(buildCmd)
 }
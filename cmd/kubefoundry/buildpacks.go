@@ -0,0 +1,120 @@
+// Copyright © 2021 Springer Nature Engineering Enablement, Jose Riguera
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubefoundry
+
+import (
+	cobra "github.com/spf13/cobra"
+)
+
+var buildpacksCmd = &cobra.Command{
+	Use:   "buildpacks",
+	Short: "Manage vendored buildpacks",
+	Long:  `Vendored buildpacks are copied to the staging container, so staging does not need network access to download them`,
+}
+
+var buildpacksVendorCmd = &cobra.Command{
+	Use:           "vendor [buildpack...]",
+	Short:         "Download buildpacks to the buildpacks folder",
+	Long:          `Download buildpacks (known names, <git-url>[#ref] or zip files) to the buildpacks folder. Without arguments the ones of DockerStaging.Buildpacks.Sources are used, all the known buildpacks by default`,
+	RunE:          buildpacksVendor,
+	SilenceUsage:  true,
+	SilenceErrors: false,
+}
+
+var buildpacksListCmd = &cobra.Command{
+	Use:           "list",
+	Short:         "List the vendored buildpacks",
+	Long:          `List the buildpacks in the buildpacks folder with their source and version`,
+	RunE:          buildpacksList,
+	SilenceUsage:  true,
+	SilenceErrors: false,
+}
+
+var buildpacksPushCmd = &cobra.Command{
+	Use:           "push [image]",
+	Short:         "Push the vendored buildpacks as an OCI artifact",
+	Long:          `Push the buildpacks of the buildpacks folder to the registry as an OCI artifact (DockerStaging.Buildpacks.Artifact)`,
+	Args:          cobra.MaximumNArgs(1),
+	RunE:          buildpacksPush,
+	SilenceUsage:  true,
+	SilenceErrors: false,
+}
+
+var buildpacksPullCmd = &cobra.Command{
+	Use:           "pull [image]",
+	Short:         "Pull the buildpacks of an OCI artifact",
+	Long:          `Pull the buildpacks of an OCI artifact (DockerStaging.Buildpacks.Artifact) to the buildpacks folder`,
+	Args:          cobra.MaximumNArgs(1),
+	RunE:          buildpacksPull,
+	SilenceUsage:  true,
+	SilenceErrors: false,
+}
+
+func buildpacksVendor(command *cobra.Command, args []string) error {
+	dir, _ := command.Flags().GetString("dir")
+	force, _ := command.Flags().GetBool("force")
+	err := program.LoadConfig()
+	if err == nil {
+		err = program.VendorBuildpacks(dir, args, force)
+	}
+	return err
+}
+
+func buildpacksList(command *cobra.Command, args []string) error {
+	dir, _ := command.Flags().GetString("dir")
+	format, _ := command.Flags().GetString("output")
+	err := program.LoadConfig()
+	if err == nil {
+		err = program.ListBuildpacks(dir, format)
+	}
+	return err
+}
+
+func buildpacksPush(command *cobra.Command, args []string) error {
+	dir, _ := command.Flags().GetString("dir")
+	image := ""
+	if len(args) > 0 {
+		image = args[0]
+	}
+	err := program.LoadConfig()
+	if err == nil {
+		err = program.PushBuildpacks(dir, image)
+	}
+	return err
+}
+
+func buildpacksPull(command *cobra.Command, args []string) error {
+	dir, _ := command.Flags().GetString("dir")
+	image := ""
+	if len(args) > 0 {
+		image = args[0]
+	}
+	err := program.LoadConfig()
+	if err == nil {
+		err = program.PullBuildpacks(dir, image)
+	}
+	return err
+}
+
+func init() {
+	buildpacksCmd.PersistentFlags().String("dir", "", "Buildpacks folder (DockerStaging.Buildpacks.Dir)")
+	buildpacksVendorCmd.PersistentFlags().Bool("force", false, "Download the buildpacks again if they are already vendored")
+	buildpacksListCmd.PersistentFlags().StringP("output", "o", "table", "Output format: table or json")
+	buildpacksCmd.AddCommand(buildpacksVendorCmd)
+	buildpacksCmd.AddCommand(buildpacksListCmd)
+	buildpacksCmd.AddCommand(buildpacksPushCmd)
+	buildpacksCmd.AddCommand(buildpacksPullCmd)
+	Cmd.AddCommand(buildpacksCmd)
+}
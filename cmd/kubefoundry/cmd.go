@@ -54,8 +54,8 @@ func Run(version, build string) {
 	Version = version
 	Build = build
 	if err := Cmd.Execute(); err != nil {
-		fmt.Printf("Errors:\n")
-		fmt.Printf("\t%s\n\n", err)
+		fmt.Fprintf(os.Stderr, "Errors:\n")
+		fmt.Fprintf(os.Stderr, "\t%s\n\n", err)
 		os.Exit(1)
 	}
 }
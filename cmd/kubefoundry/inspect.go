@@ -0,0 +1,42 @@
+// Copyright © 2021 Springer Nature Engineering Enablement, Jose Riguera
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubefoundry
+
+import (
+	cobra "github.com/spf13/cobra"
+)
+
+var inspectCmd = &cobra.Command{
+	Use:           "inspect",
+	Short:         "Show details of the application container image",
+	Long:          `Show id, digest, size, labels, buildpack, start command, git commit and build date of the application container image`,
+	RunE:          inspect,
+	SilenceUsage:  true,
+	SilenceErrors: false,
+}
+
+func inspect(command *cobra.Command, args []string) error {
+	format, _ := command.Flags().GetString("output")
+	err := program.LoadConfig()
+	if err == nil {
+		err = program.InspectAppImage(format)
+	}
+	return err
+}
+
+func init() {
+	inspectCmd.PersistentFlags().StringP("output", "o", "table", "Output format: table or json")
+	Cmd.AddCommand(inspectCmd)
+}
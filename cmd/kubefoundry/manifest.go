@@ -27,6 +27,22 @@ var manifestCmd = &cobra.Command{
 	SilenceErrors: false,
 }
 
+var manifestTemplatesCmd = &cobra.Command{
+	Use:   "templates",
+	Short: "Manage manifest templates",
+	Long:  `Templates in the repository folder .kubefoundry/templates and in Deployment.Manifest.Templates override the embedded ones with the same name`,
+}
+
+var manifestTemplatesExportCmd = &cobra.Command{
+	Use:           "export [dir]",
+	Short:         "Export the embedded manifest templates",
+	Long:          `Write the embedded manifest templates in the folder (default .kubefoundry/templates) as starting point to customize them`,
+	Args:          cobra.MaximumNArgs(1),
+	RunE:          manifestTemplatesExport,
+	SilenceUsage:  true,
+	SilenceErrors: false,
+}
+
 func manifest(command *cobra.Command, args []string) error {
 	err := program.LoadConfig()
 	if err == nil {
@@ -35,6 +51,22 @@ func manifest(command *cobra.Command, args []string) error {
 	return err
 }
 
+func manifestTemplatesExport(command *cobra.Command, args []string) error {
+	force, _ := command.Flags().GetBool("force")
+	dir := ""
+	if len(args) > 0 {
+		dir = args[0]
+	}
+	err := program.LoadConfig()
+	if err == nil {
+		err = program.ExportManifestTemplates(dir, force)
+	}
+	return err
+}
+
 func init() {
+	manifestTemplatesExportCmd.PersistentFlags().Bool("force", false, "Overwrite the existing templates")
+	manifestTemplatesCmd.AddCommand(manifestTemplatesExportCmd)
+	manifestCmd.AddCommand(manifestTemplatesCmd)
 	Cmd.AddCommand(manifestCmd)
 }
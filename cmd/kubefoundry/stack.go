@@ -0,0 +1,55 @@
+// Copyright © 2021 Springer Nature Engineering Enablement, Jose Riguera
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubefoundry
+
+import (
+	cobra "github.com/spf13/cobra"
+)
+
+var stackCmd = &cobra.Command{
+	Use:   "stack",
+	Short: "Manage stack images",
+	Long:  `Stack images are base images with the staging assets and the buildpacks preinstalled`,
+}
+
+var stackBuildCmd = &cobra.Command{
+	Use:           "build",
+	Short:         "Build and push a stack image",
+	Long:          `Build a stack image from a base image with the staging assets and the buildpacks preinstalled, and push it to the registry. Use it as DockerStaging.BaseImage to stage applications without downloading the buildpacks`,
+	RunE:          stackBuild,
+	SilenceUsage:  true,
+	SilenceErrors: false,
+}
+
+func stackBuild(command *cobra.Command, args []string) error {
+	image, _ := command.Flags().GetString("image")
+	base, _ := command.Flags().GetString("base")
+	buildpacks, _ := command.Flags().GetStringSlice("buildpack")
+	push, _ := command.Flags().GetBool("push")
+	err := program.LoadConfig()
+	if err == nil {
+		err = program.BuildStackImage(image, base, buildpacks, push)
+	}
+	return err
+}
+
+func init() {
+	stackBuildCmd.PersistentFlags().String("image", "", "Name of the stack image (DockerStaging.Stack.Image)")
+	stackBuildCmd.PersistentFlags().String("base", "", "Base image of the stack (DockerStaging.Stack.BaseImage)")
+	stackBuildCmd.PersistentFlags().StringSlice("buildpack", []string{}, "Buildpacks (names or git urls) to preinstall, all the known ones by default")
+	stackBuildCmd.PersistentFlags().Bool("push", true, "Push the stack image to the registry")
+	stackCmd.AddCommand(stackBuildCmd)
+	Cmd.AddCommand(stackCmd)
+}
@@ -29,13 +29,21 @@ var stageCmd = &cobra.Command{
 
 func stage(command *cobra.Command, args []string) error {
 	// TODO: add more args (apart of the ones defined in the configuration file)
+	force, _ := command.Flags().GetBool("force")
+	platforms, _ := command.Flags().GetStringSlice("platform")
+	baseImagePolicy, _ := command.Flags().GetString("base-image-policy")
+	buildpacksPolicy, _ := command.Flags().GetString("buildpack-policy")
 	err := program.LoadConfig()
 	if err == nil {
-		err = program.StageAppImage()
+		err = program.StageAppImage(force, platforms, baseImagePolicy, buildpacksPolicy)
 	}
 	return err
 }
 
 func init() {
+	stageCmd.PersistentFlags().Bool("force", false, "Build and push the image even if there is one with the same source")
+	stageCmd.PersistentFlags().StringSlice("platform", []string{}, "Build and push the image for the platforms, eg: linux/amd64,linux/arm64")
+	stageCmd.PersistentFlags().String("base-image-policy", "", "Base image digest: pinned (lock file) or latest (update lock file)")
+	stageCmd.PersistentFlags().String("buildpack-policy", "", "Buildpacks versions: pinned (lock file) or latest (update lock file)")
 	Cmd.AddCommand(stageCmd)
 }
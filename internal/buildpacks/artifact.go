@@ -0,0 +1,267 @@
+package buildpacks
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	registry "kubefoundry/pkg/registry"
+	kftar "kubefoundry/pkg/tar"
+
+	ocispecs "github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+const (
+	// Media types of the OCI artifact with the buildpacks, one layer per
+	// buildpack (title annotation is the slug) and the index as config
+	ArtifactConfigMediaType = "application/vnd.kubefoundry.buildpacks.config.v1+json"
+	ArtifactLayerMediaType  = "application/vnd.kubefoundry.buildpack.layer.v1.tar+gzip"
+)
+
+// artifactConfig is the config of the OCI artifact
+type artifactConfig struct {
+	Buildpacks []Vendored `json:"buildpacks"`
+}
+
+// Push uploads the buildpacks of the store to the registry as an OCI
+// artifact and returns its digest
+func (s *Store) Push(ctx context.Context, client *registry.Client, image string) (digest string, err error) {
+	ref, err := registry.ParseReference(image)
+	if err != nil {
+		s.log.Error(err)
+		return
+	}
+	bps, err := s.List()
+	if err != nil {
+		return
+	}
+	if len(bps) == 0 {
+		err = fmt.Errorf("No buildpacks vendored in '%s'", s.Dir)
+		s.log.Error(err)
+		return
+	}
+	manifest := ocispec.Manifest{
+		Versioned: ocispecs.Versioned{SchemaVersion: 2},
+	}
+	for _, bp := range bps {
+		content, errP := s.pack(ctx, bp)
+		if errP != nil {
+			err = errP
+			return
+		}
+		layer, errB := client.PushBlob(ctx, ref, ArtifactLayerMediaType, content)
+		if errB != nil {
+			err = fmt.Errorf("Unable to push buildpack '%s' to '%s': %s", bp.Name, image, errB.Error())
+			s.log.Error(err)
+			return
+		}
+		layer.Annotations = map[string]string{ocispec.AnnotationTitle: bp.Slug}
+		manifest.Layers = append(manifest.Layers, layer)
+		s.log.Debugf("Pushed buildpack '%s' layer %s", bp.Name, layer.Digest)
+	}
+	config, err := json.Marshal(artifactConfig{Buildpacks: bps})
+	if err != nil {
+		s.log.Error(err)
+		return
+	}
+	if manifest.Config, err = client.PushBlob(ctx, ref, ArtifactConfigMediaType, config); err != nil {
+		s.log.Error(err)
+		return
+	}
+	body, err := json.Marshal(manifest)
+	if err != nil {
+		s.log.Error(err)
+		return
+	}
+	desc, err := client.PushManifest(ctx, ref, ocispec.MediaTypeImageManifest, body)
+	if err != nil {
+		s.log.Error(err)
+		return
+	}
+	digest = desc.Digest.String()
+	s.log.Infof("Pushed %d buildpacks to '%s@%s'", len(bps), ref.Name(), digest)
+	return
+}
+
+// Pull downloads the buildpacks of the OCI artifact to the store, unless
+// the store was already pulled from the same digest
+func (s *Store) Pull(ctx context.Context, client *registry.Client, image string) (err error) {
+	ref, err := registry.ParseReference(image)
+	if err != nil {
+		s.log.Error(err)
+		return
+	}
+	desc, body, err := client.Manifest(ctx, ref)
+	if err != nil {
+		err = fmt.Errorf("Unable to get buildpacks artifact '%s': %s", image, err.Error())
+		s.log.Error(err)
+		return
+	}
+	if desc.Digest.String() == s.artifact() {
+		s.log.Infof("Buildpacks in '%s' are up to date with '%s'", s.Dir, image)
+		return
+	}
+	manifest := ocispec.Manifest{}
+	if err = json.Unmarshal(body, &manifest); err != nil || manifest.Config.MediaType != ArtifactConfigMediaType {
+		err = fmt.Errorf("Image '%s' is not a buildpacks artifact", image)
+		s.log.Error(err)
+		return
+	}
+	content, err := client.Blob(ctx, ref, manifest.Config.Digest)
+	if err != nil {
+		err = fmt.Errorf("Unable to get config of buildpacks artifact '%s': %s", image, err.Error())
+		s.log.Error(err)
+		return
+	}
+	config := artifactConfig{}
+	if err = json.Unmarshal(content, &config); err != nil {
+		err = fmt.Errorf("Invalid config of buildpacks artifact '%s': %s", image, err.Error())
+		s.log.Error(err)
+		return
+	}
+	stackDir := filepath.Join(s.Dir, StackDir)
+	if err = os.MkdirAll(stackDir, 0755); err != nil {
+		err = fmt.Errorf("Unable to create buildpacks folder '%s': %s", stackDir, err.Error())
+		s.log.Error(err)
+		return
+	}
+	for _, layer := range manifest.Layers {
+		slug := layer.Annotations[ocispec.AnnotationTitle]
+		if slug == "" || slug != filepath.Base(slug) || strings.HasPrefix(slug, ".") {
+			err = fmt.Errorf("Invalid buildpack layer %s in '%s'", layer.Digest, image)
+			s.log.Error(err)
+			return
+		}
+		if content, err = client.Blob(ctx, ref, layer.Digest); err != nil {
+			err = fmt.Errorf("Unable to get buildpack layer %s from '%s': %s", layer.Digest, image, err.Error())
+			s.log.Error(err)
+			return
+		}
+		if err = s.unpack(content, slug); err != nil {
+			return
+		}
+		s.log.Debugf("Pulled buildpack '%s' layer %s", slug, layer.Digest)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	idx, err := s.load()
+	if err != nil {
+		return
+	}
+	bps := config.Buildpacks
+	for _, v := range idx.Buildpacks {
+		found := false
+		for _, bp := range config.Buildpacks {
+			found = found || bp.Slug == v.Slug
+		}
+		if !found {
+			bps = append(bps, v)
+		}
+	}
+	idx.Artifact = desc.Digest.String()
+	idx.Buildpacks = bps
+	if err = s.save(idx); err == nil {
+		s.log.Infof("Pulled %d buildpacks from '%s@%s' to '%s'", len(manifest.Layers), ref.Name(), desc.Digest, s.Dir)
+	}
+	return
+}
+
+// pack returns the buildpack folder as a reproducible tar.gz
+func (s *Store) pack(ctx context.Context, bp Vendored) (content []byte, err error) {
+	buffer := &bytes.Buffer{}
+	zw := gzip.NewWriter(buffer)
+	t := kftar.NewTar(".", s.log, zw)
+	t.SetReproducible(bp.Date)
+	err = t.Add(ctx, filepath.Join(s.Dir, StackDir, bp.Slug), bp.Slug)
+	if errc := t.Close(); err == nil {
+		err = errc
+	}
+	if errc := zw.Close(); err == nil {
+		err = errc
+	}
+	if err != nil {
+		err = fmt.Errorf("Unable to package buildpack '%s': %s", bp.Name, err.Error())
+		s.log.Error(err)
+		return
+	}
+	content = buffer.Bytes()
+	return
+}
+
+// unpack extracts the layer of a buildpack in a temporary folder and
+// replaces the buildpack with it
+func (s *Store) unpack(content []byte, slug string) (err error) {
+	stackDir := filepath.Join(s.Dir, StackDir)
+	tmp, err := ioutil.TempDir(stackDir, ".pull-")
+	if err != nil {
+		err = fmt.Errorf("Unable to create temporary folder in '%s': %s", stackDir, err.Error())
+		s.log.Error(err)
+		return
+	}
+	defer os.RemoveAll(tmp)
+	if err = extractTarGz(bytes.NewReader(content), tmp); err == nil {
+		path := filepath.Join(stackDir, slug)
+		if err = os.RemoveAll(path); err == nil {
+			err = os.Rename(filepath.Join(tmp, slug), path)
+		}
+	}
+	if err != nil {
+		err = fmt.Errorf("Unable to extract buildpack '%s': %s", slug, err.Error())
+		s.log.Error(err)
+	}
+	return
+}
+
+func extractTarGz(reader io.Reader, dir string) (err error) {
+	zr, err := gzip.NewReader(reader)
+	if err != nil {
+		return
+	}
+	defer zr.Close()
+	tr := tar.NewReader(zr)
+	for {
+		header, errN := tr.Next()
+		if errN == io.EOF {
+			return
+		} else if errN != nil {
+			return errN
+		}
+		path := filepath.Join(dir, filepath.FromSlash(header.Name))
+		if !strings.HasPrefix(path, filepath.Clean(dir)+string(os.PathSeparator)) {
+			return fmt.Errorf("Invalid path '%s'", header.Name)
+		}
+		mode := os.FileMode(header.Mode).Perm()
+		switch header.Typeflag {
+		case tar.TypeDir:
+			err = os.MkdirAll(path, mode|0700)
+		case tar.TypeSymlink:
+			if err = os.MkdirAll(filepath.Dir(path), 0755); err == nil {
+				err = os.Symlink(header.Linkname, path)
+			}
+		case tar.TypeReg:
+			if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return
+			}
+			out, errO := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode)
+			if errO != nil {
+				return errO
+			}
+			_, err = io.Copy(out, tr)
+			if errc := out.Close(); err == nil {
+				err = errc
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
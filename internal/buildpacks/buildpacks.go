@@ -0,0 +1,135 @@
+package buildpacks
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const (
+	// Folder (in the buildpacks dir) with the buildpacks installed, the same
+	// one used by staging.py for the buildpacks preinstalled in stack images
+	StackDir = "stack"
+)
+
+// Buildpack known by name
+type Buildpack struct {
+	Name string
+	URL  string
+}
+
+// Known buildpacks, the same list (and order, used for the automatic
+// detection) as staging.py, pointing to the default branch
+var Known = []Buildpack{
+	{"staticfile_buildpack", "https://github.com/cloudfoundry/staticfile-buildpack.git"},
+	{"java_buildpack", "https://github.com/cloudfoundry/java-buildpack.git"},
+	{"python_buildpack", "https://github.com/cloudfoundry/python-buildpack.git"},
+	{"ruby_buildpack", "https://github.com/cloudfoundry/ruby-buildpack.git"},
+	{"nodejs_buildpack", "https://github.com/cloudfoundry/nodejs-buildpack.git"},
+	{"php_buildpack", "https://github.com/cloudfoundry/php-buildpack.git"},
+	{"go_buildpack", "https://github.com/cloudfoundry/go-buildpack.git"},
+	{"dotnet_core_buildpack", "https://github.com/cloudfoundry/dotnet-core-buildpack.git"},
+	{"binary_buildpack", "https://github.com/cloudfoundry/binary-buildpack.git"},
+	{"nginx_buildpack", "https://github.com/cloudfoundry/nginx-buildpack.git"},
+	{"r_buildpack", "https://github.com/cloudfoundry/r-buildpack.git"},
+}
+
+var (
+	slugRegexp   = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+	commitRegexp = regexp.MustCompile(`^[0-9a-f]{40}$`)
+)
+
+// Source of a buildpack, a git repository with an optional reference
+// (branch, tag, commit) or a zip file (url or local path) with an optional
+// sha256 digest
+type Source struct {
+	Name   string
+	URL    string
+	Ref    string
+	Digest string
+	Zip    bool
+}
+
+// Pinned checks if the source is an immutable version of the buildpack
+func (s Source) Pinned() bool {
+	if s.Zip {
+		return s.Digest != ""
+	}
+	return commitRegexp.MatchString(s.Ref)
+}
+
+// String returns the source as buildpack name for staging.py
+func (s Source) String() string {
+	switch {
+	case s.Zip && s.Digest != "":
+		return s.URL + "#" + s.Digest
+	case !s.Zip && s.Ref != "":
+		return s.URL + "#" + s.Ref
+	}
+	return s.URL
+}
+
+// Location returns the url of a known buildpack or the name
+func Location(name string) string {
+	for _, bp := range Known {
+		if bp.Name == name {
+			return bp.URL
+		}
+	}
+	return name
+}
+
+// Names returns the names of the known buildpacks
+func Names() (names []string) {
+	for _, bp := range Known {
+		names = append(names, bp.Name)
+	}
+	return
+}
+
+// Slug is the name of the folder of the buildpack, staging.py looks for
+// the buildpacks of the manifest in <buildpacksdir>/stack/<slug>
+func Slug(name string) string {
+	return slugRegexp.ReplaceAllString(Location(name), "_")
+}
+
+// ParseSource returns the source of the buildpack name used in the
+// manifest: a known name, <git-url>[#ref] or <zip>[#sha256:digest]
+func ParseSource(name string) (s Source, err error) {
+	s.Name = name
+	location := Location(name)
+	s.URL = location
+	if i := strings.LastIndex(location, "#"); i > 0 {
+		s.URL = location[:i]
+		s.Ref = location[i+1:]
+	}
+	if strings.HasSuffix(strings.ToLower(s.URL), ".zip") {
+		s.Zip = true
+		s.Digest, s.Ref = s.Ref, ""
+		if s.Digest != "" && !strings.HasPrefix(s.Digest, "sha256:") {
+			err = fmt.Errorf("Buildpack '%s' is a zip file, only sha256 digests are supported as reference", name)
+		}
+		return
+	}
+	if strings.HasPrefix(s.URL, "git@") || strings.HasSuffix(s.URL, ".git") {
+		return
+	}
+	if u, errU := url.Parse(s.URL); errU == nil && (u.Scheme == "git" || u.Scheme == "ssh") {
+		return
+	}
+	err = fmt.Errorf("Unknown buildpack '%s', it is not a git repository, a zip file or a known buildpack", name)
+	return
+}
+
+// expandHome replaces ~/ with the home folder of the user
+func expandHome(path string) string {
+	if strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, path[2:])
+		}
+	}
+	return path
+}
@@ -0,0 +1,112 @@
+package buildpacks
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"strings"
+
+	log "kubefoundry/internal/log"
+	git "kubefoundry/pkg/git"
+)
+
+// Resolver maps the buildpacks of the manifest to their sources and pins
+// them to an exact version: a git commit or the digest of a zip file
+type Resolver struct {
+	aliases map[string]string
+	store   *Store
+	log     log.Logger
+}
+
+// NewResolver returns a resolver with the sources of the aliases, defined
+// as name=source (eg. java_buildpack=https://github.com/org/java-buildpack.git#v4.50)
+func NewResolver(aliases []string, store *Store, l log.Logger) (r *Resolver, err error) {
+	r = &Resolver{
+		aliases: make(map[string]string),
+		store:   store,
+		log:     l,
+	}
+	for _, alias := range aliases {
+		parts := strings.SplitN(alias, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" || strings.TrimSpace(parts[1]) == "" {
+			err = fmt.Errorf("Invalid buildpack alias '%s', it must be name=source", alias)
+			l.Error(err)
+			return nil, err
+		}
+		r.aliases[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+	return
+}
+
+// Source returns the source of the buildpack: the alias, the url of the
+// known buildpack or the name itself
+func (r *Resolver) Source(name string) (source Source, err error) {
+	location := name
+	if alias, ok := r.aliases[name]; ok {
+		location = alias
+	}
+	if source, err = ParseSource(location); err != nil {
+		r.log.Error(err)
+	}
+	source.Name = name
+	return
+}
+
+// Resolve returns the buildpack pinned to a version. The vendored version is
+// used if available, unless latest is true, then the remote source is
+// checked first (falling back to the vendored version if it fails).
+func (r *Resolver) Resolve(ctx context.Context, name string, latest bool) (resolved string, err error) {
+	source, err := r.Source(name)
+	if err != nil || source.Pinned() {
+		return source.String(), err
+	}
+	vendored, found := r.vendored(source)
+	if found && !latest {
+		r.log.Debugf("Buildpack '%s' resolved to the vendored version: %s", name, vendored.String())
+		return vendored.String(), nil
+	}
+	pinned := source
+	if source.Zip {
+		content, errZ := r.store.readZip(ctx, source, os.TempDir())
+		err = errZ
+		if err == nil {
+			pinned.Digest = fmt.Sprintf("sha256:%x", sha256.Sum256(content))
+		}
+	} else {
+		pinned.Ref, err = git.ResolveReference(source.URL, source.Ref)
+	}
+	switch {
+	case err != nil && found:
+		r.log.Warnf("Unable to resolve buildpack '%s', using the vendored version: %s", name, err.Error())
+		return vendored.String(), nil
+	case err != nil:
+		err = fmt.Errorf("Unable to resolve buildpack '%s' from '%s': %s", name, source.String(), err.Error())
+		r.log.Error(err)
+		return
+	}
+	r.log.Debugf("Buildpack '%s' resolved to %s", name, pinned.String())
+	return pinned.String(), nil
+}
+
+// vendored returns the source pinned to the version in the store
+func (r *Resolver) vendored(source Source) (pinned Source, found bool) {
+	bps, err := r.store.List()
+	if err != nil {
+		return
+	}
+	pinned = source
+	for _, bp := range bps {
+		if bp.URL != source.URL {
+			continue
+		}
+		if source.Zip && bp.Digest != "" {
+			pinned.Digest = bp.Digest
+			return pinned, true
+		} else if !source.Zip && bp.Commit != "" && bp.Ref == source.Ref {
+			pinned.Ref = bp.Commit
+			return pinned, true
+		}
+	}
+	return
+}
@@ -0,0 +1,210 @@
+package buildpacks
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	log "kubefoundry/internal/log"
+)
+
+const (
+	testCommit      = "0123456789abcdef0123456789abcdef01234567"
+	testOtherCommit = "89abcdef0123456789abcdef0123456789abcdef"
+)
+
+// testStore returns a store in a temporary folder with the buildpacks
+// vendored (the index and the folders)
+func testStore(t *testing.T, vendored ...Vendored) *Store {
+	s := NewStore(t.TempDir(), log.StandardLogger())
+	for _, bp := range vendored {
+		if err := os.MkdirAll(filepath.Join(s.Dir, StackDir, bp.Slug), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.add(bp); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return s
+}
+
+// testZip writes a zip buildpack and returns its path and digest
+func testZip(t *testing.T, dir string) (path, digest string) {
+	path = filepath.Join(dir, "custom-buildpack.zip")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(file)
+	w, err := zw.Create("bin/detect")
+	if err == nil {
+		_, err = w.Write([]byte("#!/bin/sh\n"))
+	}
+	if err == nil {
+		err = zw.Close()
+	}
+	if errC := file.Close(); err == nil {
+		err = errC
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path, fmt.Sprintf("sha256:%x", sha256.Sum256(content))
+}
+
+func TestResolve(t *testing.T) {
+	dir := t.TempDir()
+	zipPath, zipDigest := testZip(t, dir)
+	missingZip := filepath.Join(dir, "missing.zip")
+	nodejs := "https://github.com/cloudfoundry/nodejs-buildpack.git"
+	cases := []struct {
+		name     string
+		aliases  []string
+		vendored []Vendored
+		bp       string
+		latest   bool
+		resolved string
+		err      bool
+	}{
+		{
+			name:     "pinned alias",
+			aliases:  []string{"java_buildpack=https://github.com/org/java-buildpack.git#" + testCommit},
+			bp:       "java_buildpack",
+			latest:   true,
+			resolved: "https://github.com/org/java-buildpack.git#" + testCommit,
+		},
+		{
+			name:     "pinned name",
+			bp:       nodejs + "#" + testCommit,
+			resolved: nodejs + "#" + testCommit,
+		},
+		{
+			name:     "vendored git",
+			vendored: []Vendored{{Name: "nodejs_buildpack", Slug: Slug("nodejs_buildpack"), URL: nodejs, Commit: testCommit}},
+			bp:       "nodejs_buildpack",
+			resolved: nodejs + "#" + testCommit,
+		},
+		{
+			name:     "alias replaces the vendored source",
+			vendored: []Vendored{{Name: "nodejs_buildpack", Slug: Slug("nodejs_buildpack"), URL: nodejs, Commit: testCommit}},
+			aliases:  []string{"nodejs_buildpack=" + missingZip},
+			bp:       "nodejs_buildpack",
+			err:      true,
+		},
+		{
+			name:     "zip not vendored",
+			aliases:  []string{"custom=" + zipPath},
+			bp:       "custom",
+			resolved: zipPath + "#" + zipDigest,
+		},
+		{
+			name:     "vendored zip",
+			aliases:  []string{"custom=" + missingZip},
+			vendored: []Vendored{{Name: "custom", Slug: "custom", URL: missingZip, Digest: zipDigest}},
+			bp:       "custom",
+			resolved: missingZip + "#" + zipDigest,
+		},
+		{
+			name:     "latest zip",
+			aliases:  []string{"custom=" + zipPath},
+			vendored: []Vendored{{Name: "custom", Slug: "custom", URL: zipPath, Digest: "sha256:old"}},
+			bp:       "custom",
+			latest:   true,
+			resolved: zipPath + "#" + zipDigest,
+		},
+		{
+			name:     "latest unavailable uses vendored",
+			aliases:  []string{"custom=" + missingZip},
+			vendored: []Vendored{{Name: "custom", Slug: "custom", URL: missingZip, Digest: zipDigest}},
+			bp:       "custom",
+			latest:   true,
+			resolved: missingZip + "#" + zipDigest,
+		},
+		{
+			name:    "unavailable and not vendored",
+			aliases: []string{"custom=" + missingZip},
+			bp:      "custom",
+			err:     true,
+		},
+		{
+			name: "unknown buildpack",
+			bp:   "unknown_buildpack",
+			err:  true,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r, err := NewResolver(c.aliases, testStore(t, c.vendored...), log.StandardLogger())
+			if err != nil {
+				t.Fatal(err)
+			}
+			resolved, err := r.Resolve(context.Background(), c.bp, c.latest)
+			if c.err {
+				if err == nil {
+					t.Fatalf("Expected error, got %s", resolved)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resolved != c.resolved {
+				t.Errorf("Expected %s, got %s", c.resolved, resolved)
+			}
+		})
+	}
+}
+
+func TestNewResolverInvalidAlias(t *testing.T) {
+	for _, alias := range []string{"java_buildpack", "=https://example.com/bp.git", "java_buildpack= "} {
+		if _, err := NewResolver([]string{alias}, testStore(t), log.StandardLogger()); err == nil {
+			t.Errorf("Expected error with alias '%s'", alias)
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+	nodejs := "https://github.com/cloudfoundry/nodejs-buildpack.git"
+	s := testStore(t,
+		Vendored{Name: "nodejs_buildpack", Slug: Slug("nodejs_buildpack"), URL: nodejs, Commit: testCommit},
+		Vendored{Name: "custom", Slug: "custom", URL: "/buildpacks/custom.zip", Digest: "sha256:abc"},
+	)
+	cases := []struct {
+		name  string
+		bp    string
+		slug  string
+		found bool
+	}{
+		{name: "by name", bp: "nodejs_buildpack", slug: Slug("nodejs_buildpack"), found: true},
+		{name: "by url", bp: nodejs, slug: Slug("nodejs_buildpack"), found: true},
+		{name: "pinned commit", bp: nodejs + "#" + testCommit, slug: Slug("nodejs_buildpack"), found: true},
+		{name: "other commit", bp: nodejs + "#" + testOtherCommit},
+		{name: "not pinned ref", bp: nodejs + "#main"},
+		{name: "pinned zip", bp: "/buildpacks/custom.zip#sha256:abc", slug: "custom", found: true},
+		{name: "other zip digest", bp: "/buildpacks/custom.zip#sha256:def"},
+		{name: "not vendored", bp: "java_buildpack"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			path, found := s.Lookup(c.bp)
+			if found != c.found {
+				t.Fatalf("Expected found %v, got %v (%s)", c.found, found, path)
+			}
+			if found && path != filepath.Join(s.Dir, StackDir, c.slug) {
+				t.Errorf("Expected folder of %s, got %s", c.slug, path)
+			}
+			if !found && path != "" {
+				t.Errorf("Expected empty path, got %s", path)
+			}
+		})
+	}
+}
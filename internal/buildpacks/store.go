@@ -0,0 +1,171 @@
+package buildpacks
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	log "kubefoundry/internal/log"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+const (
+	// Index of the buildpacks in the store folder
+	IndexFile = "buildpacks.yml"
+)
+
+// Vendored buildpack in the store
+type Vendored struct {
+	Name   string    `yaml:"name" json:"name"`
+	Slug   string    `yaml:"slug" json:"slug"`
+	URL    string    `yaml:"url" json:"url"`
+	Ref    string    `yaml:"ref,omitempty" json:"ref,omitempty"`
+	Commit string    `yaml:"commit,omitempty" json:"commit,omitempty"`
+	Digest string    `yaml:"digest,omitempty" json:"digest,omitempty"`
+	Date   time.Time `yaml:"date" json:"date"`
+}
+
+type index struct {
+	Artifact   string     `yaml:"artifact,omitempty"`
+	Buildpacks []Vendored `yaml:"buildpacks"`
+}
+
+// Store is a folder with buildpacks vendored, ready to be copied to the
+// buildpacks folder of the staging container
+type Store struct {
+	Dir string
+	log log.Logger
+	mu  sync.Mutex
+}
+
+// NewStore returns the store of buildpacks in the folder
+func NewStore(dir string, l log.Logger) *Store {
+	return &Store{
+		Dir: expandHome(dir),
+		log: l,
+	}
+}
+
+// Path returns the folder of the buildpack
+func (s *Store) Path(name string) string {
+	return filepath.Join(s.Dir, StackDir, Slug(name))
+}
+
+// Has checks if the buildpack is vendored
+func (s *Store) Has(name string) bool {
+	info, err := os.Stat(s.Path(name))
+	return err == nil && info.IsDir()
+}
+
+// Lookup returns the folder of the buildpack vendored with the same name,
+// or with the same url and version if the name is pinned
+func (s *Store) Lookup(name string) (path string, found bool) {
+	if s.Has(name) {
+		return s.Path(name), true
+	}
+	source, err := ParseSource(name)
+	if err != nil || !source.Pinned() {
+		return
+	}
+	bps, err := s.List()
+	if err != nil {
+		return
+	}
+	for _, bp := range bps {
+		version, wanted := bp.Commit, source.Ref
+		if source.Zip {
+			version, wanted = bp.Digest, source.Digest
+		}
+		if bp.URL == source.URL && version == wanted {
+			return filepath.Join(s.Dir, StackDir, bp.Slug), true
+		}
+	}
+	return
+}
+
+// List returns the buildpacks vendored sorted by name
+func (s *Store) List() (bps []Vendored, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	idx, err := s.load()
+	if err != nil {
+		return
+	}
+	for _, bp := range idx.Buildpacks {
+		if info, errS := os.Stat(filepath.Join(s.Dir, StackDir, bp.Slug)); errS == nil && info.IsDir() {
+			bps = append(bps, bp)
+		}
+	}
+	sort.Slice(bps, func(i, j int) bool { return bps[i].Name < bps[j].Name })
+	return
+}
+
+// add records the buildpack in the index
+func (s *Store) add(bp Vendored) (err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	idx, err := s.load()
+	if err != nil {
+		return
+	}
+	bps := []Vendored{bp}
+	for _, v := range idx.Buildpacks {
+		if v.Slug != bp.Slug {
+			bps = append(bps, v)
+		}
+	}
+	idx.Buildpacks = bps
+	return s.save(idx)
+}
+
+// artifact returns the digest of the OCI artifact the store was pulled from
+func (s *Store) artifact() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	idx, err := s.load()
+	if err != nil {
+		return ""
+	}
+	return idx.Artifact
+}
+
+func (s *Store) load() (idx *index, err error) {
+	idx = &index{}
+	path := filepath.Join(s.Dir, IndexFile)
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return idx, nil
+	} else if err != nil {
+		err = fmt.Errorf("Unable to read buildpacks index '%s': %s", path, err.Error())
+		s.log.Error(err)
+		return
+	}
+	if err = yaml.Unmarshal(content, idx); err != nil {
+		err = fmt.Errorf("Unable to parse buildpacks index '%s': %s", path, err.Error())
+		s.log.Error(err)
+	}
+	return
+}
+
+func (s *Store) save(idx *index) (err error) {
+	path := filepath.Join(s.Dir, IndexFile)
+	sort.Slice(idx.Buildpacks, func(i, j int) bool { return idx.Buildpacks[i].Name < idx.Buildpacks[j].Name })
+	buffer := &bytes.Buffer{}
+	encoder := yaml.NewEncoder(buffer)
+	encoder.SetIndent(2)
+	if err = encoder.Encode(idx); err == nil {
+		encoder.Close()
+		err = ioutil.WriteFile(path, buffer.Bytes(), 0644)
+	}
+	if err != nil {
+		err = fmt.Errorf("Unable to write buildpacks index '%s': %s", path, err.Error())
+		s.log.Error(err)
+	}
+	return
+}
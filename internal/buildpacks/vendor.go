@@ -0,0 +1,263 @@
+package buildpacks
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	git "kubefoundry/pkg/git"
+)
+
+// Vendor downloads the buildpack to the store, unless it is already there
+// and force is false
+func (s *Store) Vendor(ctx context.Context, name string, force bool) (bp Vendored, err error) {
+	source, err := ParseSource(name)
+	if err != nil {
+		s.log.Error(err)
+		return
+	}
+	path := s.Path(name)
+	if _, errS := os.Stat(path); errS == nil && !force {
+		s.log.Infof("Buildpack '%s' already vendored in '%s'", name, path)
+		bp, err = s.vendored(name)
+		return
+	}
+	stackDir := filepath.Join(s.Dir, StackDir)
+	if err = os.MkdirAll(stackDir, 0755); err != nil {
+		err = fmt.Errorf("Unable to create buildpacks folder '%s': %s", stackDir, err.Error())
+		s.log.Error(err)
+		return
+	}
+	// Download to a temporary folder, the buildpack is only replaced if
+	// the download is complete
+	tmp, err := ioutil.TempDir(stackDir, ".vendor-")
+	if err != nil {
+		err = fmt.Errorf("Unable to create temporary folder in '%s': %s", stackDir, err.Error())
+		s.log.Error(err)
+		return
+	}
+	defer os.RemoveAll(tmp)
+	bp = Vendored{
+		Name: name,
+		Slug: Slug(name),
+		URL:  source.URL,
+		Ref:  source.Ref,
+		Date: time.Now().UTC().Truncate(time.Second),
+	}
+	if source.Pinned() {
+		bp.Ref = ""
+	}
+	s.log.Infof("Vendoring buildpack '%s' from '%s' ...", name, Location(name))
+	if source.Zip {
+		bp.Digest, err = s.vendorZip(ctx, source, tmp)
+	} else {
+		bp.Commit, err = s.vendorGit(source, tmp)
+	}
+	if err != nil {
+		return
+	}
+	if err = os.RemoveAll(path); err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		err = fmt.Errorf("Unable to install buildpack '%s' in '%s': %s", name, path, err.Error())
+		s.log.Error(err)
+		return
+	}
+	if err = s.add(bp); err == nil {
+		s.log.Infof("Buildpack '%s' vendored in '%s'", name, path)
+	}
+	return
+}
+
+// vendored returns the buildpack from the index
+func (s *Store) vendored(name string) (bp Vendored, err error) {
+	bps, err := s.List()
+	if err != nil {
+		return
+	}
+	for _, v := range bps {
+		if v.Slug == Slug(name) {
+			return v, nil
+		}
+	}
+	bp = Vendored{Name: name, Slug: Slug(name), URL: Location(name)}
+	return
+}
+
+// vendorGit clones the repository, the reference can be a tag or a branch
+func (s *Store) vendorGit(source Source, dir string) (commit string, err error) {
+	refs := []string{""}
+	switch {
+	case source.Pinned():
+		// Commits are checked out after cloning the default branch
+	case strings.HasPrefix(source.Ref, "refs/"):
+		refs = []string{source.Ref}
+	case source.Ref != "":
+		refs = []string{"refs/tags/" + source.Ref, "refs/heads/" + source.Ref}
+	}
+	for _, ref := range refs {
+		if err = os.RemoveAll(dir); err == nil {
+			err = os.Mkdir(dir, 0755)
+		}
+		if err != nil {
+			break
+		}
+		// Objects in memory, the .git folder is not needed
+		repo, errR := git.NewGitRepo(dir, true)
+		if errR != nil {
+			err = errR
+			break
+		}
+		if err = repo.Clone(source.URL, ref, true); err == nil {
+			if source.Pinned() {
+				err = repo.Checkout(source.Ref, true)
+			}
+			if err == nil {
+				commit, err = repo.Commit()
+			}
+			break
+		}
+		s.log.Debugf("Unable to clone '%s' reference '%s': %s", source.URL, ref, err.Error())
+	}
+	if err != nil {
+		err = fmt.Errorf("Unable to clone buildpack '%s': %s", source.Name, err.Error())
+		s.log.Error(err)
+	}
+	return
+}
+
+// vendorZip extracts the zip file (downloading it if it is an url). If all
+// the files are in the same folder, it is removed from the paths.
+func (s *Store) vendorZip(ctx context.Context, source Source, dir string) (digest string, err error) {
+	content, err := s.readZip(ctx, source, filepath.Dir(dir))
+	if err != nil {
+		return
+	}
+	digest = fmt.Sprintf("sha256:%x", sha256.Sum256(content))
+	if source.Digest != "" && source.Digest != digest {
+		err = fmt.Errorf("Buildpack '%s' digest mismatch, got %s", source.Name, digest)
+		s.log.Error(err)
+		return
+	}
+	zipReader, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		err = fmt.Errorf("Invalid zip file for buildpack '%s': %s", source.Name, err.Error())
+		s.log.Error(err)
+		return
+	}
+	prefix := ""
+	if len(zipReader.File) > 0 {
+		prefix = strings.SplitAfter(zipReader.File[0].Name, "/")[0]
+		for _, f := range zipReader.File {
+			if !strings.HasPrefix(f.Name, prefix) || !strings.HasSuffix(prefix, "/") {
+				prefix = ""
+				break
+			}
+		}
+	}
+	for _, f := range zipReader.File {
+		if err = extractZipFile(f, dir, prefix); err != nil {
+			err = fmt.Errorf("Unable to extract buildpack '%s': %s", source.Name, err.Error())
+			s.log.Error(err)
+			return
+		}
+	}
+	return
+}
+
+// readZip returns the content of the zip file, downloading it to the
+// folder if it is an url
+func (s *Store) readZip(ctx context.Context, source Source, dir string) (content []byte, err error) {
+	file := source.URL
+	if strings.HasPrefix(file, "http://") || strings.HasPrefix(file, "https://") {
+		if file, err = s.download(ctx, source.URL, dir); err != nil {
+			return
+		}
+		defer os.Remove(file)
+	}
+	if content, err = ioutil.ReadFile(expandHome(file)); err != nil {
+		err = fmt.Errorf("Unable to read buildpack '%s': %s", source.Name, err.Error())
+		s.log.Error(err)
+	}
+	return
+}
+
+// download gets the url in a temporary file in the folder
+func (s *Store) download(ctx context.Context, url, dir string) (file string, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		s.log.Error(err)
+		return
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		err = fmt.Errorf("Unable to download '%s': %s", url, err.Error())
+		s.log.Error(err)
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		err = fmt.Errorf("Unable to download '%s': %s", url, resp.Status)
+		s.log.Error(err)
+		return
+	}
+	tmp, err := ioutil.TempFile(dir, ".download-")
+	if err != nil {
+		s.log.Error(err)
+		return
+	}
+	defer tmp.Close()
+	if _, err = io.Copy(tmp, resp.Body); err != nil {
+		os.Remove(tmp.Name())
+		err = fmt.Errorf("Unable to download '%s': %s", url, err.Error())
+		s.log.Error(err)
+		return
+	}
+	file = tmp.Name()
+	return
+}
+
+func extractZipFile(f *zip.File, dir, prefix string) (err error) {
+	name := strings.TrimPrefix(f.Name, prefix)
+	if name == "" {
+		return
+	}
+	path := filepath.Join(dir, filepath.FromSlash(name))
+	if !strings.HasPrefix(path, filepath.Clean(dir)+string(os.PathSeparator)) {
+		return fmt.Errorf("Invalid path '%s'", f.Name)
+	}
+	if f.FileInfo().IsDir() {
+		return os.MkdirAll(path, 0755)
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return
+	}
+	mode := f.Mode().Perm()
+	if mode == 0 {
+		mode = 0644
+	}
+	reader, err := f.Open()
+	if err != nil {
+		return
+	}
+	defer reader.Close()
+	out, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode)
+	if err != nil {
+		return
+	}
+	if _, err = io.Copy(out, reader); err != nil {
+		out.Close()
+		return
+	}
+	return out.Close()
+}
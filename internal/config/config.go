@@ -8,19 +8,47 @@ const (
 	ConfigUserAgent string = "kubefoundry"
 )
 
+// Environment with a kustomize overlay, the settings not defined are the
+// ones of the base manifests. The routes in the default domain are moved to
+// the domain of the environment.
+type Environment struct {
+	Name      string `mapstructure:"name"`
+	Namespace string `mapstructure:"namespace"`
+	Instances int    `mapstructure:"instances"`
+	Mem       string `mapstructure:"mem"`
+	CPU       string `mapstructure:"cpu"`
+	Disk      string `mapstructure:"disk"`
+	Domain    string `mapstructure:"domain"`
+}
+
 type KubeVela struct {
-	Api         string `mapstructure:"api"`
-	KubeConfig  string `mapstructure:"kubeconfig" valid:"required" default:"~/.kube/config" flag:"kubernetes config"`
-	Cluster     string `mapstructure:"cluster"`
-	Environment string `mapstructure:"environment" valid:"required" flag:"kubevela environment"`
-	Namespace   string `mapstructure:"namespace" valid:"required" flag:"kubernetes namespace"`
+	Api          string        `mapstructure:"api"`
+	KubeConfig   string        `mapstructure:"kubeconfig" valid:"required" default:"~/.kube/config" flag:"kubernetes config"`
+	Cluster      string        `mapstructure:"cluster"`
+	Environment  string        `mapstructure:"environment" valid:"required" flag:"kubevela environment"`
+	Namespace    string        `mapstructure:"namespace" valid:"required" flag:"kubernetes namespace"`
+	Environments []Environment `mapstructure:"environments"`
+}
+
+// Credentials for a registry, the password can be read from a file or an
+// environment variable
+type RegistryAuth struct {
+	Registry     string `mapstructure:"registry"`
+	Username     string `mapstructure:"username"`
+	Password     string `mapstructure:"password"`
+	PasswordFile string `mapstructure:"passwordfile"`
+	PasswordEnv  string `mapstructure:"passwordenv"`
 }
 
 type Docker struct {
-	API      string `mapstructure:"api" flag:"docker api"`
-	Registry string `mapstructure:"registry"`
-	Username string `mapstructure:"username"`
-	Password string `mapstructure:"password"`
+	API          string         `mapstructure:"api" flag:"docker api"`
+	Registry     string         `mapstructure:"registry"`
+	Username     string         `mapstructure:"username"`
+	Password     string         `mapstructure:"password"`
+	PasswordFile string         `mapstructure:"passwordfile"`
+	PasswordEnv  string         `mapstructure:"passwordenv"`
+	Config       string         `mapstructure:"config" flag:"docker config"`
+	Registries   []RegistryAuth `mapstructure:"registries"`
 }
 
 type CF struct {
@@ -31,18 +59,94 @@ type CF struct {
 	ReadManifest string `mapstructure:"readmanifest" valid:"in(yes|no|try),required" default:"try" flag:"cf read manifest"`
 }
 
+// Defaults of the applications, the sizes have CF units (1G is 1Gi). With
+// MemPerCPU the cpu is proportional to the memory, the requests are a ratio
+// of the limits (0 or 1 is the limit)
 type Defaults struct {
-	Domain string `mapstructure:"domain" valid:"required" flag:"default domain"`
-	Port   int    `mapstructure:"port" default:"8080" flag:"default port"`
-	Mem    string `mapstructure:"mem" default:"1G" flag:"default mem"`
-	CPU    string `mapstructure:"cpu" default:"1" flag:"default cpu"`
-	Disk   string `mapstructure:"disk" default:"4G" flag:"default disk"`
+	Domain      string  `mapstructure:"domain" valid:"required" flag:"default domain"`
+	Port        int     `mapstructure:"port" default:"8080" flag:"default port"`
+	Mem         string  `mapstructure:"mem" default:"1G" flag:"default mem"`
+	CPU         string  `mapstructure:"cpu" default:"1" flag:"default cpu"`
+	Disk        string  `mapstructure:"disk" default:"4G" flag:"default disk"`
+	MemPerCPU   string  `mapstructure:"mempercpu" flag:"default mem per cpu"`
+	CPURequests float64 `mapstructure:"cpurequests" default:"1" flag:"default cpu requests"`
+	MemRequests float64 `mapstructure:"memrequests" default:"1" flag:"default mem requests"`
 }
 
 type Manifest struct {
 	AppFile   string `mapstructure:"appfile" default:"vela.yml" valid:"required" flag:"kubevela appfile"`
-	Generate  string `mapstructure:"generate" valid:"in(appfile|kubefoundry|kubernetes|all),required" default:"kubefoundry" flag:"manifest generate"`
+	Generate  string `mapstructure:"generate" valid:"in(appfile|kubefoundry|kubernetes|helm|kustomize|all),required" default:"kubefoundry" flag:"manifest generate"`
 	OverWrite bool   `mapstructure:"overwrite" default:"true" flag:"manifest overwrite"`
+	Templates string `mapstructure:"templates" flag:"manifest templates"`
+}
+
+// Toleration of a node taint, without value with the Exists operator
+type Toleration struct {
+	Key      string `mapstructure:"key"`
+	Operator string `mapstructure:"operator" valid:"in(Equal|Exists)" default:"Equal"`
+	Value    string `mapstructure:"value"`
+	Effect   string `mapstructure:"effect" valid:"in(NoSchedule|PreferNoSchedule|NoExecute)"`
+}
+
+// Node affinity with the labels of the nodes, required without weight and
+// preferred with weight (1-100)
+type NodeAffinity struct {
+	Key      string   `mapstructure:"key"`
+	Operator string   `mapstructure:"operator" valid:"in(In|NotIn|Exists|DoesNotExist|Gt|Lt)" default:"In"`
+	Values   []string `mapstructure:"values"`
+	Weight   int      `mapstructure:"weight"`
+}
+
+// Pod affinity (or anti affinity) with the pods with the labels (key=value,
+// by default the instances of the application) in the topology domain (by
+// default the node), required without weight and preferred with weight (1-100)
+type PodAffinity struct {
+	TopologyKey string   `mapstructure:"topologykey"`
+	Labels      []string `mapstructure:"labels"`
+	Weight      int      `mapstructure:"weight"`
+}
+
+// Spread of the instances of the application in the topology domains
+type TopologySpread struct {
+	TopologyKey       string `mapstructure:"topologykey"`
+	MaxSkew           int    `mapstructure:"maxskew" default:"1"`
+	WhenUnsatisfiable string `mapstructure:"whenunsatisfiable" valid:"in(DoNotSchedule|ScheduleAnyway)" default:"ScheduleAnyway"`
+}
+
+// Scheduling defaults of a KubeVela environment
+type EnvironmentScheduling struct {
+	Environment       string           `mapstructure:"environment"`
+	Tolerations       []Toleration     `mapstructure:"tolerations"`
+	NodeSelector      []string         `mapstructure:"nodeselector"`
+	Affinity          []NodeAffinity   `mapstructure:"affinity"`
+	PodAffinity       []PodAffinity    `mapstructure:"podaffinity"`
+	PodAntiAffinity   []PodAffinity    `mapstructure:"podantiaffinity"`
+	TopologySpread    []TopologySpread `mapstructure:"topologyspread"`
+	PriorityClassName string           `mapstructure:"priorityclassname"`
+}
+
+// Scheduling of the instances in the nodes, the settings not defined are taken
+// from the environment of KubeVela. The node selector is a list of key=value.
+// Without any setting the dedicated katee-default nodes are used.
+type Scheduling struct {
+	Tolerations       []Toleration            `mapstructure:"tolerations"`
+	NodeSelector      []string                `mapstructure:"nodeselector" flag:"scheduling node selector"`
+	Affinity          []NodeAffinity          `mapstructure:"affinity"`
+	PodAffinity       []PodAffinity           `mapstructure:"podaffinity"`
+	PodAntiAffinity   []PodAffinity           `mapstructure:"podantiaffinity"`
+	TopologySpread    []TopologySpread        `mapstructure:"topologyspread"`
+	PriorityClassName string                  `mapstructure:"priorityclassname" flag:"scheduling priority class"`
+	Environments      []EnvironmentScheduling `mapstructure:"environments"`
+}
+
+// Routing of the external traffic to the applications with Istio (default
+// gateways istio-system/private, istio-system/public and mesh), Kubernetes
+// Ingress or Gateway API (gateways namespace/name)
+type Routing struct {
+	Provider     string   `mapstructure:"provider" valid:"in(istio|ingress|gateway)" default:"istio" flag:"routing provider"`
+	Gateways     []string `mapstructure:"gateways" flag:"routing gateways"`
+	IngressClass string   `mapstructure:"ingressclass" flag:"routing ingress class"`
+	TLSSecret    string   `mapstructure:"tlssecret" flag:"routing tls secret"`
 }
 
 type Deployment struct {
@@ -52,18 +156,47 @@ type Deployment struct {
 	AppVersion    string            `mapstructure:"appversion" default:"" flag:"version"`
 	AppRoutes     []string          `mapstructure:"approutes" default:"[]" flag:"routes"`
 	StagingDriver string            `mapstructure:"stagingdriver" valid:"required" default:"DockerStaging" flag:"staging"`
+	Workload      string            `mapstructure:"workload" valid:"in(auto|deployment|statefulset)" default:"auto" flag:"workload"`
 	Args          map[string]string `mapstructure:"args" flag:"args"`
 	RegistryTag   string            `mapstructure:"registry" flag:"registry prefix"`
 	Defaults      Defaults          `mapstructure:"defaults"`
 	Manifest      Manifest          `mapstructure:"manifest"`
+	Scheduling    Scheduling        `mapstructure:"scheduling"`
+	Routing       Routing           `mapstructure:"routing"`
+}
+
+// Stack image with the staging assets and the buildpacks preinstalled
+type Stack struct {
+	Image      string   `mapstructure:"image"`
+	BaseImage  string   `mapstructure:"baseimage" default:"cloudfoundry/cflinuxfs3:latest"`
+	Buildpacks []string `mapstructure:"buildpacks"`
+}
+
+// Buildpacks vendored to stage without network access
+type Buildpacks struct {
+	Dir      string   `mapstructure:"dir" default:"~/.kubefoundry/buildpacks"`
+	Artifact string   `mapstructure:"artifact"`
+	Sources  []string `mapstructure:"sources"`
+	Aliases  []string `mapstructure:"aliases"`
+	Policy   string   `mapstructure:"policy" valid:"in(pinned|latest)" default:"pinned"`
 }
 
 // This config what the driver gets (dockerstaging)
 type DockerStaging struct {
-	RemoveBeforeBuild bool   `mapstructure:"removebeforebuild" default:"true"`
-	RestartPolicy     string `mapstructure:"restartpolicy" valid:"in(no|unless-stopped|on-failure)" default:"unless-stopped"`
-	DynamicPorts      bool   `mapstructure:"dynamicports" default:"false"`
-	BaseImage         string `mapstructure:"baseimage" default:"cloudfoundry/cflinuxfs3:latest"`
+	RemoveBeforeBuild bool       `mapstructure:"removebeforebuild" default:"true"`
+	RestartPolicy     string     `mapstructure:"restartpolicy" valid:"in(no|unless-stopped|on-failure)" default:"unless-stopped"`
+	DynamicPorts      bool       `mapstructure:"dynamicports" default:"false"`
+	BaseImage         string     `mapstructure:"baseimage" default:"cloudfoundry/cflinuxfs3:latest"`
+	Reproducible      bool       `mapstructure:"reproducible" default:"false"`
+	Force             bool       `mapstructure:"force" default:"false"`
+	SigningKey        string     `mapstructure:"signingkey" flag:"dockerstaging signing key"`
+	Attest            bool       `mapstructure:"attest" default:"false"`
+	SBOM              string     `mapstructure:"sbom" valid:"in(none|registry|file|all)" default:"none" flag:"dockerstaging sbom"`
+	Labels            []string   `mapstructure:"labels"`
+	Platforms         []string   `mapstructure:"platforms"`
+	BaseImagePolicy   string     `mapstructure:"baseimagepolicy" valid:"in(pinned|latest)" default:"pinned"`
+	Stack             Stack      `mapstructure:"stack"`
+	Buildpacks        Buildpacks `mapstructure:"buildpacks"`
 }
 
 type Logging struct {
@@ -74,6 +207,7 @@ type Logging struct {
 // Config the application's configuration
 type Config struct {
 	Log           Logging       `mapstructure:"log"`
+	Progress      string        `mapstructure:"progress" valid:"in(text|json)" default:"text" flag:"progress output, text or json events"`
 	Team          string        `mapstructure:"team" valid:"required" flag:"team"`
 	Deployment    Deployment    `mapstructure:"deployment"`
 	KubeVela      *KubeVela     `mapstructure:"kubevela"`
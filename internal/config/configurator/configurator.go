@@ -136,9 +136,14 @@ func (c *configurator) LoadConfig(key string) (*config.Config, error) {
 	}
 	// Set default config
 	defaults.SetDefaultConfig(cfg)
+	output := cfg.Log.Output
+	if cfg.Progress == "json" && (output == "split" || output == "stdout") {
+		// Progress events are written to stdout
+		output = "stderr"
+	}
 	logger, err := log.New(&log.Config{
 		Level:         cfg.Log.Level,
-		Output:        cfg.Log.Output,
+		Output:        output,
 		ConsoleFormat: c.ConsoleFormat,
 	})
 	if err != nil {
@@ -0,0 +1,113 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// Types of events
+const (
+	PhaseStart      = "phase.start"
+	PhaseEnd        = "phase.end"
+	LayerProgress   = "layer.progress"
+	ImageBuilt      = "image.built"
+	ImagePushed     = "image.pushed"
+	ManifestWritten = "manifest.written"
+	ApplyResult     = "apply.result"
+	Error           = "error"
+)
+
+// Status of the phases and results
+const (
+	StatusSuccess = "success"
+	StatusFailure = "failure"
+)
+
+// Event about the progress of a command, only the fields of its type are set
+type Event struct {
+	Time      time.Time `json:"time"`
+	Type      string    `json:"type"`
+	App       string    `json:"app,omitempty"`
+	Phase     string    `json:"phase,omitempty"`
+	Buildpack string    `json:"buildpack,omitempty"`
+	Status    string    `json:"status,omitempty"`
+	Duration  float64   `json:"duration,omitempty"`
+	Image     string    `json:"image,omitempty"`
+	Digest    string    `json:"digest,omitempty"`
+	Layer     string    `json:"layer,omitempty"`
+	Current   int64     `json:"current,omitempty"`
+	Total     int64     `json:"total,omitempty"`
+	File      string    `json:"file,omitempty"`
+	Kind      string    `json:"kind,omitempty"`
+	Name      string    `json:"name,omitempty"`
+	Namespace string    `json:"namespace,omitempty"`
+	Message   string    `json:"message,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	Hint      string    `json:"hint,omitempty"`
+}
+
+// Sink receives the events of the facade and the staging drivers
+type Sink interface {
+	Emit(e Event)
+}
+
+type discard struct{}
+
+func (discard) Emit(Event) {}
+
+// Discard is the sink without output, used with the text progress
+var Discard Sink = discard{}
+
+type jsonSink struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+}
+
+// NewJSONSink returns a sink writing the events as NDJSON
+func NewJSONSink(w io.Writer) Sink {
+	return &jsonSink{encoder: json.NewEncoder(w)}
+}
+
+func (s *jsonSink) Emit(e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.encoder.Encode(e)
+}
+
+// New returns the sink for the progress format: text (no events) or json
+func New(format string, w io.Writer) (Sink, error) {
+	switch format {
+	case "", "text":
+		return Discard, nil
+	case "json":
+		return NewJSONSink(w), nil
+	}
+	return nil, fmt.Errorf("Unknown progress format '%s', use 'text' or 'json'", format)
+}
+
+// Phase emits the start of the phase of the event and returns the function
+// to emit its end with the duration and the error
+func Phase(s Sink, e Event) func(err error) {
+	start := time.Now()
+	e.Type = PhaseStart
+	e.Time = start
+	s.Emit(e)
+	return func(err error) {
+		end := e
+		end.Type = PhaseEnd
+		end.Time = time.Now()
+		end.Duration = end.Time.Sub(start).Seconds()
+		end.Status = StatusSuccess
+		if err != nil {
+			end.Status = StatusFailure
+			end.Error = err.Error()
+		}
+		s.Emit(end)
+	}
+}
@@ -0,0 +1,114 @@
+package kubefoundry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"text/tabwriter"
+
+	buildpacks "kubefoundry/internal/buildpacks"
+	staging "kubefoundry/internal/staging"
+)
+
+const (
+	// Name of the buildpacks artifact when it is not defined
+	DefaultBuildpacksArtifact = "kubefoundry-buildpacks:latest"
+)
+
+// VendorBuildpacks downloads the buildpacks of the configuration (all the
+// known ones if empty) to the buildpacks folder
+func (d *KubeFoundryCliFacade) VendorBuildpacks(ctx context.Context, force bool) (err error) {
+	store := buildpacks.NewStore(d.c.DockerStaging.Buildpacks.Dir, d.l)
+	sources := d.c.DockerStaging.Buildpacks.Sources
+	if len(sources) == 0 {
+		sources = buildpacks.Names()
+	}
+	for _, source := range sources {
+		if _, err = store.Vendor(ctx, source, force); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// ListBuildpacks shows the vendored buildpacks as table or json
+func (d *KubeFoundryCliFacade) ListBuildpacks(format string) (err error) {
+	store := buildpacks.NewStore(d.c.DockerStaging.Buildpacks.Dir, d.l)
+	bps, err := store.List()
+	if err != nil {
+		return
+	}
+	switch format {
+	case "json":
+		if bps == nil {
+			bps = []buildpacks.Vendored{}
+		}
+		output, errJ := json.MarshalIndent(bps, "", "  ")
+		if errJ != nil {
+			err = fmt.Errorf("Unable to render buildpacks: %s", errJ.Error())
+			d.l.Error(err)
+			return
+		}
+		fmt.Fprintln(d.output, string(output))
+	case "table", "":
+		w := tabwriter.NewWriter(d.output, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tURL\tREF\tVERSION\tDATE")
+		for _, bp := range bps {
+			version := bp.Commit
+			if version == "" {
+				version = bp.Digest
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", bp.Name, bp.URL, bp.Ref, version, bp.Date.Format("2006-01-02 15:04:05"))
+		}
+		err = w.Flush()
+	default:
+		err = fmt.Errorf("Unknown output format '%s'", format)
+		d.l.Error(err)
+	}
+	return
+}
+
+// PushBuildpacks uploads the vendored buildpacks to the registry as an OCI
+// artifact, to provide them to builds without network access
+func (d *KubeFoundryCliFacade) PushBuildpacks(ctx context.Context) (err error) {
+	publisher, err := d.buildpacksPublisher()
+	if err != nil {
+		return
+	}
+	image := d.buildpacksArtifact()
+	digest, err := publisher.PushBuildpacks(ctx, image)
+	if err == nil {
+		fmt.Fprintf(d.output, "Buildpacks pushed to %s (%s)\n", image, digest)
+	}
+	return
+}
+
+// PullBuildpacks downloads the buildpacks of the OCI artifact to the
+// buildpacks folder
+func (d *KubeFoundryCliFacade) PullBuildpacks(ctx context.Context) (err error) {
+	publisher, err := d.buildpacksPublisher()
+	if err != nil {
+		return
+	}
+	return publisher.PullBuildpacks(ctx, d.buildpacksArtifact())
+}
+
+func (d *KubeFoundryCliFacade) buildpacksPublisher() (publisher staging.BuildpacksPublisher, err error) {
+	publisher, ok := d.stager.(staging.BuildpacksPublisher)
+	if !ok {
+		err = fmt.Errorf("Staging driver '%s' does not support buildpacks artifacts", d.c.Deployment.StagingDriver)
+		d.l.Error(err)
+	}
+	return
+}
+
+func (d *KubeFoundryCliFacade) buildpacksArtifact() (image string) {
+	image = d.c.DockerStaging.Buildpacks.Artifact
+	if image == "" {
+		image = DefaultBuildpacksArtifact
+		if d.c.Deployment.RegistryTag != "" {
+			image = d.c.Deployment.RegistryTag + "/" + d.team + "/" + image
+		}
+	}
+	return
+}
@@ -0,0 +1,37 @@
+package kubefoundry
+
+import (
+	"errors"
+	"strings"
+
+	events "kubefoundry/internal/events"
+	staging "kubefoundry/internal/staging"
+)
+
+// phase emits the start of a phase of the application and returns the
+// function to emit its end
+func (d *KubeFoundryCliFacade) phase(app, phase string) func(err error) {
+	return events.Phase(d.events, events.Event{
+		App:   app,
+		Phase: phase,
+	})
+}
+
+// emitError emits the error event, the staging errors include the phase,
+// the buildpack, the hint and the last lines of output
+func (d *KubeFoundryCliFacade) emitError(app string, err error) {
+	e := events.Event{
+		Type:  events.Error,
+		App:   app,
+		Error: err.Error(),
+	}
+	var stagingErr *staging.StagingError
+	if errors.As(err, &stagingErr) {
+		e.App = stagingErr.App
+		e.Phase = string(stagingErr.Phase)
+		e.Buildpack = stagingErr.Buildpack
+		e.Hint = stagingErr.Hint
+		e.Message = strings.Join(stagingErr.Lines, "\n")
+	}
+	d.events.Emit(e)
+}
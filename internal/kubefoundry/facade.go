@@ -7,6 +7,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
 
 	k8sClientKubernetes "k8s.io/client-go/kubernetes"
 	k8sClientRest "k8s.io/client-go/rest"
@@ -17,6 +18,7 @@ import (
 	//_ "k8s.io/client-go/plugin/pkg/client/auth"
 
 	config "kubefoundry/internal/config"
+	events "kubefoundry/internal/events"
 	log "kubefoundry/internal/log"
 	manifest "kubefoundry/internal/manifests"
 	staging "kubefoundry/internal/staging"
@@ -25,6 +27,11 @@ import (
 	_ "kubefoundry/internal/staging/dockerstaging"
 )
 
+const (
+	// Name of the stack image when it is not defined
+	DefaultStackImage = "kubefoundry-stack:latest"
+)
+
 type KubeFoundryCliFacade struct {
 	kubeconfig *k8sClientRest.Config
 	kubeclient *k8sClientKubernetes.Clientset
@@ -34,6 +41,7 @@ type KubeFoundryCliFacade struct {
 	c          *config.Config
 	output     io.Writer
 	stager     staging.AppStaging
+	events     events.Sink
 }
 
 func New(config *config.Config, l log.Logger) (*KubeFoundryCliFacade, error) {
@@ -51,6 +59,16 @@ func New(config *config.Config, l log.Logger) (*KubeFoundryCliFacade, error) {
 		c:      config,
 		output: os.Stdout,
 	}
+	sink, err := events.New(config.Progress, os.Stdout)
+	if err != nil {
+		d.l.Error(err)
+		return nil, err
+	}
+	d.events = sink
+	if sink != events.Discard {
+		// Stdout is only for the events
+		d.output = os.Stderr
+	}
 	driver := config.Deployment.StagingDriver
 	d.l.Debugf("List of registered staging drivers: %s", staging.ListStaginDrivers())
 	stager, err := staging.LoadStagingDriver(driver, config, l)
@@ -58,6 +76,9 @@ func New(config *config.Config, l log.Logger) (*KubeFoundryCliFacade, error) {
 		d.l.Error(err)
 		return nil, err
 	}
+	if emitter, ok := stager.(staging.EventsEmitter); ok {
+		emitter.SetEvents(sink)
+	}
 	d.stager = stager
 	return d, nil
 }
@@ -65,48 +86,126 @@ func New(config *config.Config, l log.Logger) (*KubeFoundryCliFacade, error) {
 func (d *KubeFoundryCliFacade) GenerateManifest() (err error) {
 	data, err := d.getMetadata()
 	if err != nil {
+		d.emitError("", err)
 		return err
 	}
 	//  d.c.Deployment.Manifest.Generate
-	// (appfile|kubefoundry|kubernetes|all)
+	// (appfile|kubefoundry|kubernetes|helm|kustomize|all), all without the
+	// folders (Helm chart and kustomize), they have to be generated explicitly
 	fullpath := d.path
 	truncate := d.c.Deployment.Manifest.OverWrite
+	generate := d.c.Deployment.Manifest.Generate
 	for _, man := range manifest.Types() {
 		if man == manifest.CF || man == manifest.Unknown {
 			continue
 		}
 		fullpath = filepath.Join(d.path, man.Filename())
-		if d.c.Deployment.Manifest.Generate == "all" || d.c.Deployment.Manifest.Generate == man.String() {
+		if (generate == "all" && !man.Folder()) || manifest.Type(generate) == man {
 			d.l.Infof("Generating %s manifest: %s", man.String(), fullpath)
-			err = manifest.New(man, data, fullpath, truncate)
+			err = manifest.New(man, data, fullpath, truncate, d.templateDirs()...)
 			if err != nil {
 				d.l.Errorf("Unable to generate %s manifest: %s", man.String(), err.Error())
+				d.emitError("", err)
 				break
 			}
+			d.events.Emit(events.Event{
+				Type: events.ManifestWritten,
+				Kind: man.String(),
+				File: fullpath,
+			})
 		}
 	}
 	return err
 }
 
+// ExportTemplates writes the embedded manifest templates in the folder (by
+// default the templates folder of the repository) to customize them
+func (d *KubeFoundryCliFacade) ExportTemplates(dir string, force bool) (err error) {
+	if dir == "" {
+		dir = filepath.Join(d.path, manifest.TemplatesDir)
+	}
+	files, err := manifest.ExportTemplates(dir, force)
+	if err != nil {
+		d.l.Error(err)
+		return
+	}
+	for _, file := range files {
+		fmt.Fprintln(d.output, file)
+	}
+	return
+}
+
+// templateDirs returns the folders with templates overriding the embedded
+// ones: the repository one and the one of the configuration
+func (d *KubeFoundryCliFacade) templateDirs() []string {
+	dir := d.c.Deployment.Manifest.Templates
+	if strings.HasPrefix(dir, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			dir = filepath.Join(home, dir[2:])
+		}
+	}
+	return []string{
+		filepath.Join(d.path, manifest.TemplatesDir),
+		dir,
+	}
+}
+
 func (d *KubeFoundryCliFacade) StageApp(ctx context.Context, build, push bool) (err error) {
 	apps, err := d.initStager()
 	if err == nil {
 		for _, app := range apps {
 			if build {
-				if _, err := app.Build(ctx); err != nil {
+				end := d.phase(app.Name(), "build")
+				_, err := app.Build(ctx)
+				end(err)
+				if err != nil {
+					d.emitError(app.Name(), err)
 					return err
 				}
 			}
 			if push {
-				if err = app.Push(ctx); err != nil {
+				end := d.phase(app.Name(), "push")
+				err = app.Push(ctx)
+				end(err)
+				if err != nil {
+					d.emitError(app.Name(), err)
 					return err
 				}
 			}
 		}
+	} else {
+		d.emitError("", err)
 	}
 	return err
 }
 
+// BuildStack builds (and pushes) the stack image defined in the configuration
+func (d *KubeFoundryCliFacade) BuildStack(ctx context.Context, push bool) (err error) {
+	builder, ok := d.stager.(staging.StackBuilder)
+	if !ok {
+		err = fmt.Errorf("Staging driver '%s' does not support stack images", d.c.Deployment.StagingDriver)
+		d.l.Error(err)
+		return
+	}
+	stack := d.c.DockerStaging.Stack
+	image := stack.Image
+	if image == "" {
+		image = DefaultStackImage
+		if d.c.Deployment.RegistryTag != "" {
+			image = d.c.Deployment.RegistryTag + "/" + d.team + "/" + image
+		}
+	}
+	// Only the dates of the sources are used to build the stack
+	data := manifest.NewContextMetadata(d.path, d.team, d.c.Deployment.RegistryTag, d.c.Deployment.Args, nil, nil)
+	end := d.phase("", "stack")
+	_, err = builder.BuildStack(ctx, data, image, stack.BaseImage, stack.Buildpacks, push, d.output)
+	end(err)
+	if err != nil {
+		d.emitError("", err)
+	}
+	return
+}
+
 func (d *KubeFoundryCliFacade) RunApp(ctx context.Context, persistentVolume string, env map[string]string) (err error) {
 	apps, err := d.initStager()
 	if err == nil {
@@ -129,6 +228,6 @@ func (d *KubeFoundryCliFacade) Push(ctx context.Context) (err error) {
 		d.l.Errorf("Could not read manifest: %s", err.Error())
 		return err
 	}
-	fmt.Printf("%v", manifestBuff.String())
+	fmt.Fprintf(d.output, "%v", manifestBuff.String())
 	return err
 }
@@ -0,0 +1,93 @@
+package kubefoundry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"text/tabwriter"
+
+	units "github.com/docker/go-units"
+)
+
+var (
+	// Fields of the image information displayed in table format, in order
+	inspectTableFields = []struct {
+		key   string
+		title string
+	}{
+		{"name", "NAME"},
+		{"id", "ID"},
+		{"digest", "DIGEST"},
+		{"size", "SIZE"},
+		{"architecture", "ARCH"},
+		{"date", "BUILD DATE"},
+		{"git", "GIT"},
+		{"commit", "COMMIT"},
+		{"team", "TEAM"},
+		{"buildpack", "BUILDPACK"},
+		{"startcommand", "START COMMAND"},
+		{"sourcehash", "SOURCE HASH"},
+		{"baseimage", "BASE IMAGE"},
+		{"basedigest", "BASE DIGEST"},
+	}
+)
+
+// InspectApp shows the details of the application images as table or json
+func (d *KubeFoundryCliFacade) InspectApp(ctx context.Context, format string) (err error) {
+	apps, err := d.initStager()
+	if err != nil {
+		return err
+	}
+	infos := []map[string]interface{}{}
+	for _, app := range apps {
+		info, err := app.Info(ctx)
+		if err != nil {
+			return err
+		}
+		infos = append(infos, info)
+	}
+	switch format {
+	case "json":
+		output, errJ := json.MarshalIndent(infos, "", "  ")
+		if errJ != nil {
+			err = fmt.Errorf("Unable to render image details: %s", errJ.Error())
+			d.l.Error(err)
+			return
+		}
+		fmt.Fprintln(d.output, string(output))
+	case "table", "":
+		w := tabwriter.NewWriter(d.output, 0, 4, 2, ' ', 0)
+		for i, info := range infos {
+			if i > 0 {
+				fmt.Fprintln(w)
+			}
+			for _, field := range inspectTableFields {
+				value := info[field.key]
+				if value == nil {
+					value = ""
+				} else if size, ok := value.(int64); ok {
+					value = units.HumanSize(float64(size))
+				}
+				fmt.Fprintf(w, "%s\t%v\n", field.title, value)
+			}
+			if labels, ok := info["labels"].(map[string]string); ok {
+				keys := []string{}
+				for k := range labels {
+					keys = append(keys, k)
+				}
+				sort.Strings(keys)
+				title := "LABELS"
+				for _, k := range keys {
+					fmt.Fprintf(w, "%s\t%s=%s\n", title, k, labels[k])
+					title = ""
+				}
+			}
+		}
+		err = w.Flush()
+	default:
+		err = fmt.Errorf("Unknown output format '%s'", format)
+		d.l.Error(err)
+	}
+	return
+}
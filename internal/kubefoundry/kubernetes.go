@@ -10,6 +10,7 @@ import (
 	"path/filepath"
 	"strings"
 
+	events "kubefoundry/internal/events"
 	manifest "kubefoundry/internal/manifests"
 
 	k8sApiMeta "k8s.io/apimachinery/pkg/api/meta"
@@ -79,7 +80,7 @@ func (d *KubeFoundryCliFacade) pushK8S(ctx context.Context) (err error) {
 		d.l.Errorf("Could not read manifest: %s", err.Error())
 		return err
 	}
-	fmt.Printf("%v", manifestBuff.String())
+	fmt.Fprintf(d.output, "%v", manifestBuff.String())
 	// Prepare a RESTMapper to find GVR
 	dc, err := k8sClientDiscovery.NewDiscoveryClientForConfig(d.kubeconfig)
 	if err != nil {
@@ -101,12 +102,12 @@ func (d *KubeFoundryCliFacade) pushK8S(ctx context.Context) (err error) {
 	}
 	// Find GVR kind == Application
 	mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
-	fmt.Printf("group kind %s, version %s \n", gvk.GroupKind(), gvk.Version)
-	fmt.Printf("mapping %s; ns %s, rs %s \n", mapping, obj.GetNamespace(), mapping.Resource)
+	d.l.Debugf("Group kind %s, version %s", gvk.GroupKind(), gvk.Version)
 	if err != nil {
 		d.l.Errorf("Cannot find GVK: %s", err.Error())
 		return err
 	}
+	d.l.Debugf("Mapping %v; ns %s, rs %s", mapping, obj.GetNamespace(), mapping.Resource)
 	// Obtain REST interface for the GVR
 	if mapping.Scope.Name() == k8sApiMeta.RESTScopeNameNamespace {
 		// namespaced resources should specify the namespace
@@ -130,8 +131,18 @@ func (d *KubeFoundryCliFacade) pushK8S(ctx context.Context) (err error) {
 	_, err = dr.Patch(ctx, obj.GetName(), k8sApiTypes.ApplyPatchType, kubedef, k8sApiMetav1.PatchOptions{
 		FieldManager: "kubefoundry",
 	})
+	result := events.Event{
+		Type:      events.ApplyResult,
+		Kind:      gvk.Kind,
+		Name:      obj.GetName(),
+		Namespace: obj.GetNamespace(),
+		Status:    events.StatusSuccess,
+	}
 	if err != nil {
 		d.l.Errorf("Cannot deploy application: %s", err.Error())
+		result.Status = events.StatusFailure
+		result.Error = err.Error()
 	}
+	d.events.Emit(result)
 	return err
 }
@@ -1,9 +1,12 @@
 package kubefoundry
 
 import (
+	"fmt"
 	"path/filepath"
-	"strconv"
+	"strings"
 
+	config "kubefoundry/internal/config"
+	lockfile "kubefoundry/internal/lockfile"
 	manifest "kubefoundry/internal/manifests"
 	staging "kubefoundry/internal/staging"
 )
@@ -12,17 +15,16 @@ func (d *KubeFoundryCliFacade) getMetadata() (data *manifest.ContextData, err er
 	appName := d.c.Deployment.AppName
 	appVersion := d.c.Deployment.AppVersion
 	appPath := d.c.Deployment.AppPath
-	appRoutes := make(map[string]string)
-	for i, r := range d.c.Deployment.AppRoutes {
-		// format is  route[<PORT>-<INDEX>] = r
-		appRoutes[strconv.Itoa(d.c.Deployment.Defaults.Port)+"-"+strconv.Itoa(i)] = r
-	}
+	appRoutes := d.c.Deployment.AppRoutes
 	rs := &manifest.ResourceData{
-		Domain: d.c.Deployment.Defaults.Domain,
-		Port:   d.c.Deployment.Defaults.Port,
-		CPU:    d.c.Deployment.Defaults.CPU,
-		Mem:    d.c.Deployment.Defaults.Mem,
-		Disk:   d.c.Deployment.Defaults.Disk,
+		Domain:      d.c.Deployment.Defaults.Domain,
+		Port:        d.c.Deployment.Defaults.Port,
+		CPU:         d.c.Deployment.Defaults.CPU,
+		Mem:         d.c.Deployment.Defaults.Mem,
+		Disk:        d.c.Deployment.Defaults.Disk,
+		MemPerCPU:   d.c.Deployment.Defaults.MemPerCPU,
+		CPURequests: d.c.Deployment.Defaults.CPURequests,
+		MemRequests: d.c.Deployment.Defaults.MemRequests,
 	}
 	kube := &manifest.KubeData{
 		NameSpace:   d.c.KubeVela.Namespace,
@@ -46,6 +48,40 @@ func (d *KubeFoundryCliFacade) getMetadata() (data *manifest.ContextData, err er
 		Apps:     []manifest.CfApplication{},
 	}
 	data = manifest.NewContextMetadata(d.path, d.team, d.c.Deployment.RegistryTag, d.c.Deployment.Args, kube, cf)
+	data.DefaultWorkload = d.c.Deployment.Workload
+	for _, env := range d.c.KubeVela.Environments {
+		data.Environments = append(data.Environments, &manifest.EnvironmentData{
+			Name:      env.Name,
+			NameSpace: env.Namespace,
+			Instances: env.Instances,
+			CPU:       env.CPU,
+			Mem:       env.Mem,
+			Disk:      env.Disk,
+			Domain:    env.Domain,
+		})
+	}
+	data.Routing = &manifest.RoutingData{
+		Provider:     d.c.Deployment.Routing.Provider,
+		Gateways:     d.c.Deployment.Routing.Gateways,
+		IngressClass: d.c.Deployment.Routing.IngressClass,
+		TLSSecret:    d.c.Deployment.Routing.TLSSecret,
+	}
+	if _, err = manifest.NewRoutingProvider(data.Routing); err != nil {
+		d.l.Error(err)
+		return nil, err
+	}
+	if data.Scheduling, err = d.getScheduling(); err != nil {
+		d.l.Error(err)
+		return nil, err
+	}
+	if lock, errL := lockfile.Load(lockfile.Path(d.path)); errL == nil {
+		if digest, found := lock.BaseImage(d.c.DockerStaging.BaseImage); found {
+			data.BaseImage = d.c.DockerStaging.BaseImage
+			data.BaseImageDigest = digest
+		}
+	} else {
+		d.l.Warn(errL.Error())
+	}
 	// (try|yes|no)
 	if d.c.CF.ReadManifest != "no" {
 		if err = data.GetAppContextMetadata(appPath, appName, appVersion, appRoutes, rs, true); err != nil {
@@ -64,6 +100,113 @@ func (d *KubeFoundryCliFacade) getMetadata() (data *manifest.ContextData, err er
 	return data, err
 }
 
+// getScheduling returns the scheduling of the deployment, the settings not
+// defined are taken from the KubeVela environment ones
+func (d *KubeFoundryCliFacade) getScheduling() (data *manifest.SchedulingData, err error) {
+	s := d.c.Deployment.Scheduling
+	if len(s.Tolerations) == 0 && len(s.NodeSelector) == 0 && len(s.Affinity) == 0 && len(s.PodAffinity) == 0 &&
+		len(s.PodAntiAffinity) == 0 && len(s.TopologySpread) == 0 && s.PriorityClassName == "" && len(s.Environments) == 0 {
+		d.l.Warnf("Deployment.Scheduling not defined, scheduling the instances in the dedicated nodes '%s'", manifest.DefaultDedicatedNodes)
+		return manifest.NewDefaultSchedulingData(), nil
+	}
+	for _, env := range s.Environments {
+		if env.Environment != d.c.KubeVela.Environment {
+			continue
+		}
+		if len(s.Tolerations) == 0 {
+			s.Tolerations = env.Tolerations
+		}
+		if len(s.NodeSelector) == 0 {
+			s.NodeSelector = env.NodeSelector
+		}
+		if len(s.Affinity) == 0 {
+			s.Affinity = env.Affinity
+		}
+		if len(s.PodAffinity) == 0 {
+			s.PodAffinity = env.PodAffinity
+		}
+		if len(s.PodAntiAffinity) == 0 {
+			s.PodAntiAffinity = env.PodAntiAffinity
+		}
+		if len(s.TopologySpread) == 0 {
+			s.TopologySpread = env.TopologySpread
+		}
+		if s.PriorityClassName == "" {
+			s.PriorityClassName = env.PriorityClassName
+		}
+		break
+	}
+	data = &manifest.SchedulingData{
+		NodeSelector:      make(map[string]string),
+		PriorityClassName: s.PriorityClassName,
+	}
+	for _, t := range s.Tolerations {
+		data.Tolerations = append(data.Tolerations, manifest.TolerationData{
+			Key:      t.Key,
+			Operator: t.Operator,
+			Value:    t.Value,
+			Effect:   t.Effect,
+		})
+	}
+	for _, selector := range s.NodeSelector {
+		pair := strings.SplitN(selector, "=", 2)
+		if len(pair) != 2 || pair[0] == "" {
+			err = fmt.Errorf("Invalid node selector '%s', the format is key=value", selector)
+			return nil, err
+		}
+		data.NodeSelector[pair[0]] = pair[1]
+	}
+	for _, a := range s.Affinity {
+		data.Affinity = append(data.Affinity, manifest.AffinityData{
+			Key:      a.Key,
+			Operator: a.Operator,
+			Values:   a.Values,
+			Weight:   a.Weight,
+		})
+	}
+	if data.PodAffinity, err = getPodAffinity(s.PodAffinity); err != nil {
+		return nil, err
+	}
+	if data.PodAntiAffinity, err = getPodAffinity(s.PodAntiAffinity); err != nil {
+		return nil, err
+	}
+	for _, t := range s.TopologySpread {
+		data.TopologySpread = append(data.TopologySpread, manifest.TopologySpreadData{
+			MaxSkew:           t.MaxSkew,
+			TopologyKey:       t.TopologyKey,
+			WhenUnsatisfiable: t.WhenUnsatisfiable,
+		})
+	}
+	return
+}
+
+// getPodAffinity returns the pod affinity terms, by default with the
+// instances of the application in the same node
+func getPodAffinity(terms []config.PodAffinity) (data []manifest.PodAffinityData, err error) {
+	for _, p := range terms {
+		term := manifest.PodAffinityData{
+			TopologyKey: p.TopologyKey,
+			Weight:      p.Weight,
+		}
+		if term.TopologyKey == "" {
+			term.TopologyKey = "kubernetes.io/hostname"
+		}
+		for _, label := range p.Labels {
+			pair := strings.SplitN(label, "=", 2)
+			if len(pair) != 2 || pair[0] == "" {
+				err = fmt.Errorf("Invalid pod affinity label '%s', the format is key=value", label)
+				return nil, err
+			}
+			if term.Labels == nil {
+				term.Labels = make(map[string]string)
+			}
+			term.Labels[pair[0]] = pair[1]
+		}
+		data = append(data, term)
+	}
+	return
+}
+
 func (d *KubeFoundryCliFacade) initStager() ([]staging.AppPackage, error) {
 	data, err := d.getMetadata()
 	if err != nil {
@@ -0,0 +1,128 @@
+package lockfile
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+const (
+	// Name of the lock file in the folder of the application
+	FileName = "kubefoundry.lock"
+	// Version of the format of the lock file
+	Version = 1
+	header  = "# Generated by kubefoundry, do not edit. Commit it to get reproducible builds.\n"
+)
+
+// LockFile pins the inputs of the build which are referenced by name, like
+// the base image tag or the buildpacks, to an immutable version
+type LockFile struct {
+	Version    int               `yaml:"version"`
+	BaseImages map[string]string `yaml:"baseImages,omitempty"`
+	Buildpacks map[string]string `yaml:"buildpacks,omitempty"`
+	path       string
+	changed    bool
+	mu         sync.Mutex
+}
+
+// Path returns the lock file of the folder
+func Path(dir string) string {
+	return filepath.Join(dir, FileName)
+}
+
+// Load reads the lock file, it is empty if the file does not exist
+func Load(path string) (l *LockFile, err error) {
+	l = &LockFile{
+		Version:    Version,
+		BaseImages: make(map[string]string),
+		Buildpacks: make(map[string]string),
+		path:       path,
+	}
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return l, nil
+	} else if err != nil {
+		err = fmt.Errorf("Unable to read lock file '%s': %s", path, err.Error())
+		return
+	}
+	if err = yaml.Unmarshal(content, l); err != nil {
+		err = fmt.Errorf("Unable to parse lock file '%s': %s", path, err.Error())
+		return
+	}
+	if l.Version > Version {
+		err = fmt.Errorf("Lock file '%s' version %d not supported, please upgrade", path, l.Version)
+		return
+	}
+	if l.BaseImages == nil {
+		l.BaseImages = make(map[string]string)
+	}
+	if l.Buildpacks == nil {
+		l.Buildpacks = make(map[string]string)
+	}
+	return
+}
+
+// BaseImage returns the digest locked for the base image
+func (l *LockFile) BaseImage(image string) (digest string, found bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	digest, found = l.BaseImages[image]
+	return
+}
+
+// SetBaseImage locks the base image to the digest
+func (l *LockFile) SetBaseImage(image, digest string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.BaseImages[image] != digest {
+		l.BaseImages[image] = digest
+		l.changed = true
+	}
+}
+
+// Buildpack returns the version locked for the buildpack of the manifest
+func (l *LockFile) Buildpack(name string) (resolved string, found bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	resolved, found = l.Buildpacks[name]
+	return
+}
+
+// SetBuildpack locks the buildpack of the manifest to the resolved version
+func (l *LockFile) SetBuildpack(name, resolved string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.Buildpacks[name] != resolved {
+		l.Buildpacks[name] = resolved
+		l.changed = true
+	}
+}
+
+// Save writes the lock file if it was changed
+func (l *LockFile) Save() (err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.changed {
+		return
+	}
+	l.Version = Version
+	buffer := bytes.NewBufferString(header)
+	encoder := yaml.NewEncoder(buffer)
+	encoder.SetIndent(2)
+	if err = encoder.Encode(l); err != nil {
+		err = fmt.Errorf("Unable to write lock file '%s': %s", l.path, err.Error())
+		return
+	}
+	encoder.Close()
+	if err = ioutil.WriteFile(l.path, buffer.Bytes(), 0644); err != nil {
+		err = fmt.Errorf("Unable to write lock file '%s': %s", l.path, err.Error())
+		return
+	}
+	l.changed = false
+	return
+}
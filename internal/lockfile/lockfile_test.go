@@ -0,0 +1,123 @@
+package lockfile
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestLoadMissing(t *testing.T) {
+	path := Path(t.TempDir())
+	l, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, found := l.BaseImage("cloudfoundry/cflinuxfs3"); found {
+		t.Error("Expected an empty lock file")
+	}
+	if err = l.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(path); !os.IsNotExist(err) {
+		t.Error("Expected no lock file to be written without changes")
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	path := Path(t.TempDir())
+	l, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.SetBaseImage("cloudfoundry/cflinuxfs3", "sha256:aaaa")
+	l.SetBuildpack("https://github.com/cloudfoundry/python-buildpack", "https://github.com/cloudfoundry/python-buildpack#abcd")
+	if err = l.Save(); err != nil {
+		t.Fatal(err)
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(content), header) {
+		t.Errorf("Expected the header in the lock file, got:\n%s", content)
+	}
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if digest, found := loaded.BaseImage("cloudfoundry/cflinuxfs3"); !found || digest != "sha256:aaaa" {
+		t.Errorf("Expected the locked base image, got '%s' %v", digest, found)
+	}
+	if resolved, found := loaded.Buildpack("https://github.com/cloudfoundry/python-buildpack"); !found || resolved != "https://github.com/cloudfoundry/python-buildpack#abcd" {
+		t.Errorf("Expected the locked buildpack, got '%s' %v", resolved, found)
+	}
+	// The same values do not change the file
+	if err = ioutil.WriteFile(path, []byte("version: 1\nbaseImages:\n  cloudfoundry/cflinuxfs3: sha256:aaaa\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if loaded, err = Load(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded.SetBaseImage("cloudfoundry/cflinuxfs3", "sha256:aaaa")
+	if err = loaded.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if content, _ = ioutil.ReadFile(path); strings.HasPrefix(string(content), header) {
+		t.Error("Expected the lock file not to be written without changes")
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	cases := map[string]string{
+		"newer version": "version: 99\n",
+		"invalid yaml":  "baseImages: [\n",
+	}
+	for name, content := range cases {
+		t.Run(name, func(t *testing.T) {
+			path := Path(t.TempDir())
+			if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := Load(path); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
+
+func TestConcurrent(t *testing.T) {
+	path := Path(t.TempDir())
+	l, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			image := fmt.Sprintf("stacks/image%d", i%5)
+			l.SetBaseImage(image, fmt.Sprintf("sha256:%d", i))
+			l.BaseImage(image)
+			l.SetBuildpack(image, image)
+			if errS := l.Save(); errS != nil {
+				t.Error(errS)
+			}
+		}(i)
+	}
+	wg.Wait()
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		image := fmt.Sprintf("stacks/image%d", i)
+		current, _ := l.BaseImage(image)
+		if digest, found := loaded.BaseImage(image); !found || digest != current {
+			t.Errorf("Expected %s locked to '%s', got '%s'", image, current, digest)
+		}
+	}
+}
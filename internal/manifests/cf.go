@@ -7,9 +7,6 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
-	"regexp"
-	"strconv"
-	"strings"
 
 	yaml "gopkg.in/yaml.v3"
 )
@@ -23,13 +20,36 @@ type CfApplication struct {
 	RandomRoute bool              `yaml:"random-route,omitempty"`
 	Routes      []CfRoute         `yaml:"routes,omitempty"`
 	Memory      string            `yaml:"memory,omitempty"`
+	DiskQuota   string            `yaml:"disk_quota,omitempty"`
 	Path        string            `yaml:"path,omitempty"`
 	Instances   int               `yaml:"instances,omitempty"`
+	Ports       []int             `yaml:"ports,omitempty"`
+	Processes   []CfProcess       `yaml:"processes,omitempty"`
+	// Kubefoundry keys
+	Workload string     `yaml:"workload,omitempty"`
+	Volumes  []CfVolume `yaml:"volumes,omitempty"`
 	// Other keys
 }
 
 type CfRoute struct {
-	Route string `yaml:"route"`
+	Route    string `yaml:"route"`
+	Protocol string `yaml:"protocol,omitempty"`
+	// Port of the application receiving the traffic, by default the first one
+	AppPort int `yaml:"app-port,omitempty"`
+}
+
+// CfProcess is a process of the application, the instances run the web one
+type CfProcess struct {
+	Type  string `yaml:"type"`
+	Ports []int  `yaml:"ports,omitempty"`
+	// Other keys
+}
+
+// CfVolume is a persistent volume of each instance
+type CfVolume struct {
+	Name string `yaml:"name"`
+	Path string `yaml:"path"`
+	Size string `yaml:"size,omitempty"`
 }
 
 type CfManifest struct {
@@ -112,60 +132,48 @@ func (app *CfApplication) GetUUID(data string) (uuid string) {
 	return
 }
 
-// Parses the human-readable size string into the amount it represents.
-func (app *CfApplication) ParseSize() (int64, error) {
-	if app.Memory == "" {
-		// 1G by default
-		return int64(1073741824), nil
-	}
-	sizeRegex := regexp.MustCompile(`^(\d+(\.\d+)*) ?([kKmMgGtTpP])?[iI]?[bB]?$`)
-	calcMap := map[string]int64{
-		"k": 1024,
-		"m": 1048576,
-		"g": 1073741824,
-	}
-	matches := sizeRegex.FindStringSubmatch(app.Memory)
-	if len(matches) != 4 {
-		return -1, fmt.Errorf("Invalid size defined in manifest: '%s'", app.Memory)
-	}
-	size, err := strconv.ParseFloat(matches[1], 64)
-	if err != nil {
-		return -1, fmt.Errorf("Invalid size defined in manifest: %s", err.Error())
-	}
-	unitPrefix := strings.ToLower(matches[3])
-	if mul, ok := calcMap[unitPrefix]; ok {
-		size *= float64(mul)
+func (app *CfApplication) GetVolumes() (volumes []VolumeData, err error) {
+	for _, v := range app.Volumes {
+		if v.Name == "" || v.Path == "" {
+			err = fmt.Errorf("Volume of application '%s' without name or path", app.Name)
+			return nil, err
+		}
+		size := v.Size
+		if size == "" {
+			size = DefaultVolumeSize
+		}
+		volumes = append(volumes, VolumeData{
+			Name: v.Name,
+			Path: v.Path,
+			Size: size,
+		})
 	}
-	return int64(size), nil
+	return
 }
 
-func (app *CfApplication) GetResources(cpuMemoryFactor float64) (memory int64, cpu float64, err error) {
-	cpu = float64(1)
-	memory, err = app.ParseSize()
-	if err == nil {
-		if cpuMemoryFactor > 0.1 {
-			cpu = float64(memory) / cpuMemoryFactor
-		} else {
-			cpu = float64(1)
+// GetPorts returns the listening ports of the web process or the application
+func (app *CfApplication) GetPorts() []int {
+	for _, p := range app.Processes {
+		if p.Type == "web" && len(p.Ports) > 0 {
+			return p.Ports
 		}
-	} else {
-		// 1G
-		memory = int64(1073741824)
 	}
-	return
+	return app.Ports
 }
 
-func (app *CfApplication) GetRoutes(randomDomain string) (routes []string, err error) {
-	if app.RandomRoute {
+// GetRoutes returns the routes of the application, a random one in the domain
+// with random-route and none with no-route
+func (app *CfApplication) GetRoutes(randomDomain string) (routes []CfRoute, err error) {
+	if app.NoRoute {
+		return
+	} else if app.RandomRoute {
 		if r := app.GetUUID(randomDomain); r != "" {
-			routes = append(routes, r+"."+randomDomain)
+			routes = append(routes, CfRoute{Route: r + "." + randomDomain})
 		} else {
 			err = fmt.Errorf("Cannot get UUID for application '%s'", app.Name)
 		}
 	} else if len(app.Routes) > 0 {
-		for _, r := range app.Routes {
-			routes = append(routes, r.Route)
-		}
+		routes = app.Routes
 	}
 	return
 }
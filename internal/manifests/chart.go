@@ -0,0 +1,44 @@
+package manifests
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	semverRegex       = regexp.MustCompile(`^v?(\d+\.\d+\.\d+(?:-[0-9A-Za-z.-]+)?(?:\+[0-9A-Za-z.-]+)?)$`)
+	chartInvalidRegex = regexp.MustCompile(`[^0-9A-Za-z-]+`)
+)
+
+// DefaultChartVersion is the version of the charts of the applications
+// without SemVer version, the version goes in the build metadata
+var DefaultChartVersion string = "0.0.0"
+
+// ChartName returns the name of the Helm chart, the name of the context in
+// lowercase
+func (d *ContextData) ChartName() string {
+	return strings.Trim(chartInvalidRegex.ReplaceAllString(strings.ToLower(d.Name), "-"), "-")
+}
+
+// ChartVersion returns the SemVer version of the Helm chart from the version
+// of the first application: 1.2.3 or v1.2.3 as they are, other versions
+// (commits, latest) as build metadata of the default version
+func (d *ContextData) ChartVersion() string {
+	version := d.AppVersion()
+	if matches := semverRegex.FindStringSubmatch(version); matches != nil {
+		return matches[1]
+	}
+	if metadata := strings.Trim(chartInvalidRegex.ReplaceAllString(version, "-"), "-"); metadata != "" {
+		return DefaultChartVersion + "+" + metadata
+	}
+	return DefaultChartVersion
+}
+
+// AppVersion returns the version of the first application, the reference of
+// the context without applications
+func (d *ContextData) AppVersion() string {
+	if len(d.Apps) > 0 && d.Apps[0].Version != "" {
+		return d.Apps[0].Version
+	}
+	return d.Ref
+}
@@ -0,0 +1,83 @@
+{{/*
+Annotations of the objects of an application: (dict "name" $name "app" $app "root" $)
+*/}}
+{{- define "kubefoundry.annotations" -}}
+"kubefoundry/app": {{ .name | quote }}
+{{- range $k, $v := .root.Values.annotations }}
+{{ $k | quote }}: {{ $v | quote }}
+{{- end }}
+"kubefoundry/version.0": {{ .app.version | quote }}
+{{- range $j, $r := .app.routes }}
+"kubefoundry/route.0.{{ $j }}": {{ include "kubefoundry.route" $r | quote }}
+{{- end }}
+{{- end }}
+
+{{/*
+Route in the CF format: host[:port][/path]
+*/}}
+{{- define "kubefoundry.route" -}}
+{{ .host }}{{ with .port }}:{{ . }}{{ end }}{{ with .path }}{{ . }}{{ end }}
+{{- end }}
+
+{{/*
+Metadata of the routing objects of an application: (dict "name" $name "object" $object "app" $app "root" $)
+*/}}
+{{- define "kubefoundry.routingMetadata" -}}
+name: {{ .object }}
+namespace: {{ include "kubefoundry.namespace" .root }}
+annotations:
+  {{- include "kubefoundry.annotations" . | nindent 2 }}
+{{- end }}
+
+{{/*
+References to the Gateway API gateways (namespace/name), with the port of the
+listener for the TCP routes: (dict "gateways" $gateways "port" $port)
+*/}}
+{{- define "kubefoundry.parentRefs" -}}
+{{- $port := .port }}
+{{- range $g := .gateways }}
+{{- $ref := splitList "/" $g }}
+{{- if eq (len $ref) 2 }}
+- name: {{ index $ref 1 | quote }}
+  namespace: {{ index $ref 0 | quote }}
+{{- else }}
+- name: {{ $g | quote }}
+{{- end }}
+{{- if $port }}
+  port: {{ $port }}
+{{- end }}
+{{- end }}
+{{- end }}
+
+{{/*
+Namespace of the objects, the release one by default
+*/}}
+{{- define "kubefoundry.namespace" -}}
+{{ .Values.namespace | default .Release.Namespace }}
+{{- end }}
+
+{{/*
+Pod affinity terms of an application, required without weight and preferred
+with weight, by default with its instances: (dict "name" $name "terms" $terms)
+*/}}
+{{- define "kubefoundry.podAffinity" -}}
+{{- $name := .name }}
+{{- $required := list }}
+{{- $preferred := list }}
+{{- range $t := .terms }}
+{{- $term := dict "topologyKey" ($t.topologyKey | default "kubernetes.io/hostname") "labelSelector" (dict "matchLabels" ($t.matchLabels | default (dict "app" $name))) }}
+{{- if $t.weight }}
+{{- $preferred = append $preferred (dict "weight" $t.weight "podAffinityTerm" $term) }}
+{{- else }}
+{{- $required = append $required $term }}
+{{- end }}
+{{- end }}
+{{- with $required }}
+requiredDuringSchedulingIgnoredDuringExecution:
+  {{- toYaml . | nindent 2 }}
+{{- end }}
+{{- with $preferred }}
+preferredDuringSchedulingIgnoredDuringExecution:
+  {{- toYaml . | nindent 2 }}
+{{- end }}
+{{- end }}
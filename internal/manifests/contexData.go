@@ -18,6 +18,15 @@ var (
 	DefaultMem        string = "1024M"
 	DefaultDisk       string = "4G"
 	DefaultRefVersion string = "latest"
+	DefaultVolumeSize string = "1Gi"
+)
+
+// Workloads of the applications, auto is a statefulset for the applications
+// with volumes and a deployment for the rest
+const (
+	WorkloadAuto        string = "auto"
+	WorkloadDeployment  string = "deployment"
+	WorkloadStatefulSet string = "statefulset"
 )
 
 type CfData struct {
@@ -34,12 +43,24 @@ type KubeData struct {
 	Cluster     string
 }
 
+// ResourceData are the defaults of the applications
 type ResourceData struct {
 	Domain string
 	Port   int
 	CPU    string
 	Mem    string
 	Disk   string
+	// Memory of each cpu core, the cpu is proportional to the memory
+	MemPerCPU string
+	// Requests as ratio of the limits, the limits by default
+	CPURequests float64
+	MemRequests float64
+}
+
+type VolumeData struct {
+	Name string
+	Path string
+	Size string
 }
 
 type AppData struct {
@@ -47,27 +68,46 @@ type AppData struct {
 	Dir       string
 	Image     string
 	Version   string
-	Routes    map[string]string
+	Routes    []*RouteData
 	Env       map[string]string
 	Instances int
 	Port      int
-	Resources *ResourceData
+	Ports     []*PortData
+	Resources *ResourcesData
+	Workload  string
+	Volumes   []VolumeData
+}
+
+// StatefulSet returns true if the application runs as a statefulset, with a
+// stable instance index and persistent volumes
+func (a *AppData) StatefulSet() bool {
+	return a.Workload == WorkloadStatefulSet
 }
 
 type ContextData struct {
-	Dir       string
-	Git       string
-	Name      string
-	Date      time.Time
-	DateHuman string
-	Registry  string
-	Ref       string
-	Team      string
-	Env       map[string]string
-	Args      map[string]string
-	Apps      []*AppData
-	Kubevela  *KubeData
-	CF        *CfData
+	Dir             string
+	Git             string
+	Commit          string
+	Name            string
+	Date            time.Time
+	DateHuman       string
+	SourceDate      time.Time
+	Registry        string
+	Ref             string
+	BaseImage       string
+	BaseImageDigest string
+	Team            string
+	Env             map[string]string
+	Args            map[string]string
+	Apps            []*AppData
+	Kubevela        *KubeData
+	CF              *CfData
+	Scheduling      *SchedulingData
+	Routing         *RoutingData
+	DefaultWorkload string
+	// Defaults of the applications and environments of the overlays
+	Defaults     *ResourceData
+	Environments []*EnvironmentData
 }
 
 func NewDefaultResourceData() *ResourceData {
@@ -88,12 +128,19 @@ func NewContextMetadata(contextDir, team, imgRegistry string, args map[string]st
 	// check if path is a git repo and get commit
 	opts := gitrepo.PlainOpenOptions{DetectDotGit: true}
 	git := ""
+	commitHash := ""
+	// Date of the sources, by default the commit date
+	sourceDate := t
 	if repo, err := gitrepo.PlainOpenWithOptions(contextDir, &opts); err == nil {
 		if head, err := repo.Head(); err == nil {
 			// 13 first chars from hash
 			ref = head.Strings()[1][1:13]
+			commitHash = head.Hash().String()
+			if commit, err := repo.CommitObject(head.Hash()); err == nil {
+				sourceDate = commit.Committer.When.UTC()
+			}
 		}
-		if remotes, err := repo.Remotes(); err == nil {
+		if remotes, err := repo.Remotes(); err == nil && len(remotes) > 0 {
 			git = remotes[0].Config().URLs[0]
 		}
 	}
@@ -102,29 +149,45 @@ func NewContextMetadata(contextDir, team, imgRegistry string, args map[string]st
 		pair := strings.SplitN(setting, "=", 2)
 		env[pair[0]] = pair[1]
 	}
+	// https://reproducible-builds.org/specs/source-date-epoch/
+	if epoch, ok := env["SOURCE_DATE_EPOCH"]; ok {
+		if seconds, err := strconv.ParseInt(epoch, 10, 64); err == nil {
+			sourceDate = time.Unix(seconds, 0).UTC()
+		}
+	}
 	contextData := &ContextData{
-		Dir:       contextDir,
-		Git:       git,
-		Name:      filepath.Base(contextDir),
-		Date:      t,
-		DateHuman: t.String(),
-		Registry:  imgRegistry,
-		Ref:       ref,
-		Team:      team,
-		Args:      args,
-		Env:       env,
-		Apps:      []*AppData{},
-		Kubevela:  kube,
-		CF:        cf,
+		Dir:        contextDir,
+		Git:        git,
+		Commit:     commitHash,
+		Name:       filepath.Base(contextDir),
+		Date:       t,
+		DateHuman:  t.String(),
+		SourceDate: sourceDate,
+		Registry:   imgRegistry,
+		Ref:        ref,
+		Team:       team,
+		Args:       args,
+		Env:        env,
+		Apps:       []*AppData{},
+		Kubevela:   kube,
+		CF:         cf,
+		// Workload of the applications without workload in the manifest
+		DefaultWorkload: WorkloadAuto,
 	}
 	return contextData
 }
 
-func (d *ContextData) GetAppContextMetadata(dir, name, version string, routes map[string]string, rs *ResourceData, parseCF bool) (err error) {
+// SourceDateEpoch returns the date of the sources as SOURCE_DATE_EPOCH
+func (d *ContextData) SourceDateEpoch() string {
+	return strconv.FormatInt(d.SourceDate.Unix(), 10)
+}
+
+func (d *ContextData) GetAppContextMetadata(dir, name, version string, routes []string, rs *ResourceData, parseCF bool) (err error) {
 	var apps []*AppData
 	if rs == nil {
 		rs = NewDefaultResourceData()
 	}
+	d.Defaults = rs
 	if dir == "" {
 		dir = d.Dir
 	}
@@ -138,14 +201,97 @@ func (d *ContextData) GetAppContextMetadata(dir, name, version string, routes ma
 			return fmt.Errorf("Unable to get CloudFoundry metadata, settings not defined")
 		}
 	} else {
-		apps = d.getAppContextMetadataDefault(dir, name, version, routes, rs)
+		if apps, err = d.getAppContextMetadataDefault(dir, name, version, routes, rs); err != nil {
+			return
+		}
 	}
 	// TODO: Load appfile and merge?
 	d.Apps = append(d.Apps, apps...)
 	return nil
 }
 
-func (d *ContextData) getAppContextMetadataDefault(dir, name, version string, routes map[string]string, rs *ResourceData) (apps []*AppData) {
+// appWorkload returns the workload of the application, the requested one or
+// the default of the context
+func (d *ContextData) appWorkload(name, workload string, volumes []VolumeData) (string, error) {
+	if workload == "" {
+		workload = d.DefaultWorkload
+	}
+	switch strings.ToLower(workload) {
+	case "", WorkloadAuto:
+		if len(volumes) > 0 {
+			return WorkloadStatefulSet, nil
+		}
+		return WorkloadDeployment, nil
+	case WorkloadDeployment:
+		if len(volumes) > 0 {
+			return "", fmt.Errorf("Application '%s' has volumes, it needs the workload '%s'", name, WorkloadStatefulSet)
+		}
+		return WorkloadDeployment, nil
+	case WorkloadStatefulSet:
+		return WorkloadStatefulSet, nil
+	}
+	return "", fmt.Errorf("Unknown workload '%s' of application '%s', use '%s' or '%s'", workload, name, WorkloadDeployment, WorkloadStatefulSet)
+}
+
+// defaultRoutes returns the default route of the application, with the name
+// and the version in the default domain
+func (d *ContextData) defaultRoutes(name, version string, rs *ResourceData) (routes []CfRoute) {
+	if rs.Domain != "" {
+		hostname := name + "-" + version
+		routes = append(routes, CfRoute{Route: strings.ToLower(hostname + "." + rs.Domain)})
+	}
+	return
+}
+
+// appRoutes parses the routes of the application to its ports, by default
+// to the first one. The ports of the HTTP/2 routes are HTTP/2.
+func (d *ContextData) appRoutes(app *AppData, routes []CfRoute, rs *ResourceData) (appRoutes []*RouteData, err error) {
+	for _, route := range routes {
+		appPort := route.AppPort
+		if appPort == 0 {
+			appPort = app.Port
+		}
+		port := app.GetPort(appPort)
+		if port == nil {
+			err = fmt.Errorf("Route '%s' of application '%s' to unknown port %d", route.Route, app.Name, appPort)
+			return nil, err
+		}
+		r, errR := ParseRoute(route.Route, route.Protocol, rs.Domain, appPort)
+		if errR != nil {
+			err = fmt.Errorf("Invalid route of application '%s': %s", app.Name, errR.Error())
+			return nil, err
+		}
+		r.ServicePort = port.ServicePort
+		if r.TCP() {
+			if err = tcpPortCollision(app, appRoutes, r); err != nil {
+				return nil, err
+			}
+			r.ServicePort = r.Port
+		} else if r.Protocol == ProtocolHTTP2 {
+			port.Protocol = ProtocolHTTP2
+		}
+		appRoutes = append(appRoutes, r)
+	}
+	return
+}
+
+// tcpPortCollision returns an error if the port of the TCP route is already
+// a port of the service, of the application ports or of another TCP route
+func tcpPortCollision(app *AppData, routes []*RouteData, r *RouteData) error {
+	for _, p := range app.Ports {
+		if p.ServicePort == r.Port {
+			return fmt.Errorf("TCP route '%s' of application '%s' collides with the service port %d of port %d", r, app.Name, p.ServicePort, p.Port)
+		}
+	}
+	for _, other := range routes {
+		if other.TCP() && other.Port == r.Port {
+			return fmt.Errorf("TCP routes '%s' and '%s' of application '%s' have the same port", other, r, app.Name)
+		}
+	}
+	return nil
+}
+
+func (d *ContextData) getAppContextMetadataDefault(dir, name, version string, routes []string, rs *ResourceData) (apps []*AppData, err error) {
 	if name == "" {
 		name = fmt.Sprintf("%s-webapp-%d", d.Name, len(d.Apps))
 	}
@@ -156,30 +302,46 @@ func (d *ContextData) getAppContextMetadataDefault(dir, name, version string, ro
 	if d.Registry != "" {
 		image = d.Registry + "/" + d.Team + "/" + image
 	}
-	appRoutes := make(map[string]string)
-	for k, v := range routes {
-		appRoutes[k] = v
+	var cfRoutes []CfRoute
+	for _, r := range routes {
+		cfRoutes = append(cfRoutes, CfRoute{Route: r})
 	}
-	if len(appRoutes) == 0 && rs.Domain != "" {
-		hostname := name + "-" + version
-		appRoutes[strconv.Itoa(rs.Port)+"-0"] = strings.ToLower(hostname + "." + rs.Domain)
+	if len(cfRoutes) == 0 {
+		cfRoutes = d.defaultRoutes(name, version, rs)
+	}
+	ports, err := newPorts(name, nil, rs.Port)
+	if err != nil {
+		return
+	}
+	workload, err := d.appWorkload(name, "", nil)
+	if err != nil {
+		return
+	}
+	resources, err := rs.GetResources("", "")
+	if err != nil {
+		err = fmt.Errorf("Invalid resources of application '%s': %s", name, err.Error())
+		return
 	}
 	appData := AppData{
 		Name:      name,
 		Dir:       dir,
 		Image:     image,
 		Version:   version,
-		Routes:    appRoutes,
 		Env:       make(map[string]string),
 		Instances: 1,
-		Port:      rs.Port,
-		Resources: rs,
+		Port:      ports[0].Port,
+		Ports:     ports,
+		Resources: resources,
+		Workload:  workload,
+	}
+	if appData.Routes, err = d.appRoutes(&appData, cfRoutes, rs); err != nil {
+		return
 	}
 	apps = append(apps, &appData)
 	return
 }
 
-func (d *ContextData) getAppContextMetadataCF(dir, name, version string, routes map[string]string, rs *ResourceData) (apps []*AppData, err error) {
+func (d *ContextData) getAppContextMetadataCF(dir, name, version string, routes []string, rs *ResourceData) (apps []*AppData, err error) {
 	err = UnmarshalCfManifest(d.CF.Manifest)
 	if err != nil {
 		return
@@ -198,18 +360,14 @@ func (d *ContextData) getAppContextMetadataCF(dir, name, version string, routes
 			if d.Registry != "" {
 				image = d.Registry + "/" + d.Team + "/" + image
 			}
-			appRoutes := make(map[string]string)
-			for k, v := range routes {
-				appRoutes[k] = v
-			}
-			if len(appRoutes) == 0 && rs.Domain != "" {
-				if cfoutes, err := appManifest.GetRoutes(rs.Domain); err == nil {
-					for i, rt := range cfoutes {
-						appRoutes[strconv.Itoa(rs.Port)+"-"+strconv.Itoa(i)] = rt
-					}
-				} else {
-					hostname := name + "-" + version
-					appRoutes[strconv.Itoa(rs.Port)+"-0"] = strings.ToLower(hostname + "." + rs.Domain)
+			var cfRoutes []CfRoute
+			for _, r := range routes {
+				cfRoutes = append(cfRoutes, CfRoute{Route: r})
+			}
+			if len(cfRoutes) == 0 && rs.Domain != "" {
+				var errR error
+				if cfRoutes, errR = appManifest.GetRoutes(rs.Domain); errR != nil {
+					cfRoutes = d.defaultRoutes(name, version, rs)
 				}
 			}
 			instances := 1
@@ -220,20 +378,37 @@ func (d *ContextData) getAppContextMetadataCF(dir, name, version string, routes
 			if appManifest.Path != "" {
 				path = appManifest.Path
 			}
-			if mem, cpu, err := appManifest.GetResources(0.0); err == nil {
-				rs.CPU = strconv.FormatFloat(cpu, 'f', -1, 64)
-				rs.Mem = strconv.FormatInt(mem, 10)
+			resources, errR := rs.GetResources(appManifest.Memory, appManifest.DiskQuota)
+			if errR != nil {
+				return nil, fmt.Errorf("Invalid resources of application '%s': %s", app, errR.Error())
+			}
+			volumes, errV := appManifest.GetVolumes()
+			if errV != nil {
+				return nil, errV
+			}
+			workload, errW := d.appWorkload(app, appManifest.Workload, volumes)
+			if errW != nil {
+				return nil, errW
+			}
+			ports, errP := newPorts(app, appManifest.GetPorts(), rs.Port)
+			if errP != nil {
+				return nil, errP
 			}
 			appData := AppData{
 				Name:      app,
 				Dir:       path,
 				Image:     image,
 				Version:   version,
-				Routes:    appRoutes,
 				Env:       appManifest.Env,
 				Instances: instances,
-				Port:      rs.Port,
-				Resources: rs,
+				Port:      ports[0].Port,
+				Ports:     ports,
+				Resources: resources,
+				Workload:  workload,
+				Volumes:   volumes,
+			}
+			if appData.Routes, err = d.appRoutes(&appData, cfRoutes, rs); err != nil {
+				return nil, err
 			}
 			apps = append(apps, &appData)
 		}
@@ -0,0 +1,22 @@
+package manifests
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSourceDateEpoch(t *testing.T) {
+	t.Setenv("SOURCE_DATE_EPOCH", "1700000000")
+	data := NewContextMetadata(t.TempDir(), "team", "", nil, nil, nil)
+	if !data.SourceDate.Equal(time.Unix(1700000000, 0)) {
+		t.Errorf("Expected the source date of SOURCE_DATE_EPOCH, got %s", data.SourceDate)
+	}
+	if epoch := data.SourceDateEpoch(); epoch != "1700000000" {
+		t.Errorf("Expected SOURCE_DATE_EPOCH 1700000000, got %s", epoch)
+	}
+	t.Setenv("SOURCE_DATE_EPOCH", "invalid")
+	data = NewContextMetadata(t.TempDir(), "team", "", nil, nil, nil)
+	if data.SourceDate.Before(time.Now().Add(-time.Minute)) {
+		t.Errorf("Expected the current date with invalid SOURCE_DATE_EPOCH, got %s", data.SourceDate)
+	}
+}
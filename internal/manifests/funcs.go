@@ -0,0 +1,179 @@
+package manifests
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"text/template"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// Funcs returns the helper functions of the templates, a subset of the sprig
+// ones with the same names and arguments
+func Funcs() template.FuncMap {
+	return template.FuncMap{
+		"default":    defaultValue,
+		"empty":      empty,
+		"coalesce":   coalesce,
+		"ternary":    ternary,
+		"required":   required,
+		"quote":      quote,
+		"squote":     squote,
+		"upper":      strings.ToUpper,
+		"lower":      strings.ToLower,
+		"trim":       strings.TrimSpace,
+		"trimPrefix": func(prefix, s string) string { return strings.TrimPrefix(s, prefix) },
+		"trimSuffix": func(suffix, s string) string { return strings.TrimSuffix(s, suffix) },
+		"replace":    func(old, new, s string) string { return strings.ReplaceAll(s, old, new) },
+		"contains":   func(substr, s string) bool { return strings.Contains(s, substr) },
+		"hasPrefix":  func(prefix, s string) bool { return strings.HasPrefix(s, prefix) },
+		"hasSuffix":  func(suffix, s string) bool { return strings.HasSuffix(s, suffix) },
+		"trunc":      trunc,
+		"join":       join,
+		"splitList":  func(sep, s string) []string { return strings.Split(s, sep) },
+		"indent":     indent,
+		"nindent":    func(n int, s string) string { return "\n" + indent(n, s) },
+		"toYaml":     toYaml,
+		"toJson":     toJson,
+		"list":       func(v ...interface{}) []interface{} { return v },
+		"dict":       dict,
+		"b64enc":     func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) },
+		"b64dec":     b64dec,
+		"sha256sum":  sha256sum,
+	}
+}
+
+func empty(v interface{}) bool {
+	value := reflect.ValueOf(v)
+	if !value.IsValid() {
+		return true
+	}
+	switch value.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return value.Len() == 0
+	case reflect.Ptr, reflect.Interface:
+		return value.IsNil()
+	}
+	return value.IsZero()
+}
+
+func defaultValue(d interface{}, given ...interface{}) interface{} {
+	if len(given) == 0 || empty(given[0]) {
+		return d
+	}
+	return given[0]
+}
+
+func coalesce(v ...interface{}) interface{} {
+	for _, value := range v {
+		if !empty(value) {
+			return value
+		}
+	}
+	return nil
+}
+
+func ternary(vt, vf interface{}, cond bool) interface{} {
+	if cond {
+		return vt
+	}
+	return vf
+}
+
+func required(msg string, v interface{}) (interface{}, error) {
+	if empty(v) {
+		return nil, errors.New(msg)
+	}
+	return v, nil
+}
+
+func quote(v ...interface{}) string {
+	out := []string{}
+	for _, s := range v {
+		if s != nil {
+			out = append(out, fmt.Sprintf("%q", fmt.Sprint(s)))
+		}
+	}
+	return strings.Join(out, " ")
+}
+
+func squote(v ...interface{}) string {
+	out := []string{}
+	for _, s := range v {
+		if s != nil {
+			out = append(out, "'"+fmt.Sprint(s)+"'")
+		}
+	}
+	return strings.Join(out, " ")
+}
+
+func trunc(n int, s string) string {
+	if n >= 0 && len(s) > n {
+		return s[:n]
+	}
+	if n < 0 && len(s) > -n {
+		return s[len(s)+n:]
+	}
+	return s
+}
+
+func join(sep string, v interface{}) string {
+	value := reflect.ValueOf(v)
+	if value.Kind() != reflect.Slice && value.Kind() != reflect.Array {
+		return fmt.Sprint(v)
+	}
+	out := make([]string, value.Len())
+	for i := 0; i < value.Len(); i++ {
+		out[i] = fmt.Sprint(value.Index(i).Interface())
+	}
+	return strings.Join(out, sep)
+}
+
+func indent(n int, s string) string {
+	pad := strings.Repeat(" ", n)
+	return pad + strings.ReplaceAll(s, "\n", "\n"+pad)
+}
+
+func toYaml(v interface{}) (string, error) {
+	buffer := &bytes.Buffer{}
+	encoder := yaml.NewEncoder(buffer)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(v); err != nil {
+		return "", err
+	}
+	encoder.Close()
+	return strings.TrimSuffix(buffer.String(), "\n"), nil
+}
+
+func toJson(v interface{}) (string, error) {
+	out, err := json.Marshal(v)
+	return string(out), err
+}
+
+func dict(v ...interface{}) (map[string]interface{}, error) {
+	if len(v)%2 != 0 {
+		return nil, fmt.Errorf("Dict requires pairs of key and value")
+	}
+	d := make(map[string]interface{}, len(v)/2)
+	for i := 0; i < len(v); i += 2 {
+		d[fmt.Sprint(v[i])] = v[i+1]
+	}
+	return d, nil
+}
+
+func b64dec(s string) (string, error) {
+	out, err := base64.StdEncoding.DecodeString(s)
+	return string(out), err
+}
+
+func sha256sum(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
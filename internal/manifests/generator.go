@@ -1,10 +1,14 @@
 package manifests
 
 import (
+	"bytes"
 	"embed"
 	"fmt"
 	"io"
+	"io/fs"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strings"
 	"text/template"
 )
@@ -13,6 +17,11 @@ import (
 //go:embed templates/*.tmpl
 var EmbedK8sTemplates embed.FS
 
+// EmbedChartTemplates holds the templates of the Helm chart, they are copied
+// as they are in the chart
+//go:embed chart/templates/*
+var EmbedChartTemplates embed.FS
+
 type ManifestType int
 
 const (
@@ -21,10 +30,12 @@ const (
 	AppFile
 	KubeFoundry
 	K8S
+	Helm
+	Kustomize
 )
 
 func Types() []ManifestType {
-	return []ManifestType{Unknown, CF, AppFile, KubeFoundry, K8S}
+	return []ManifestType{Unknown, CF, AppFile, KubeFoundry, K8S, Helm, Kustomize}
 }
 
 func Type(text string) (m ManifestType) {
@@ -37,6 +48,10 @@ func Type(text string) (m ManifestType) {
 		return KubeFoundry
 	case "kubernetes":
 		return K8S
+	case "helm":
+		return Helm
+	case "kustomize":
+		return Kustomize
 	default:
 		return Unknown
 	}
@@ -52,26 +67,57 @@ func (m ManifestType) Filename() string {
 		return "app.yml"
 	case K8S:
 		return "deploy.yml"
+	case Helm:
+		return "chart"
+	case Kustomize:
+		return "kustomize"
 	default:
 		return ""
 	}
 }
 
+// Folder returns true for the manifests written in a folder (Helm chart and
+// kustomize), they are not generated with all
+func (m ManifestType) Folder() bool {
+	return m == Helm || m == Kustomize
+}
+
 func (m ManifestType) String() string {
-	kinds := [...]string{"Unknown", "CF", "AppFile", "KubeFoundry", "K8S"}
+	kinds := [...]string{"Unknown", "CF", "AppFile", "KubeFoundry", "K8S", "Helm", "Kustomize"}
 	return kinds[int(m)]
 }
 
+const (
+	// Folder of the repository (deployment path) with the templates
+	TemplatesDir = ".kubefoundry/templates"
+)
+
 type Generator struct {
 	output    io.Writer
 	templates *template.Template
 }
 
-func NewGenerator(output io.Writer) (*Generator, error) {
-	templates, err := template.ParseFS(EmbedK8sTemplates, "templates/*")
+// NewGenerator returns a generator with the embedded templates overridden by
+// the files with the same name in the folders (first ones take precedence).
+// Other templates in the folders can be used with the template action.
+func NewGenerator(output io.Writer, dirs ...string) (*Generator, error) {
+	templates, err := template.New("").Funcs(Funcs()).ParseFS(EmbedK8sTemplates, "templates/*")
 	if err != nil {
 		panic(err)
 	}
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if dirs[i] == "" {
+			continue
+		}
+		files, _ := filepath.Glob(filepath.Join(dirs[i], "*.tmpl"))
+		if len(files) == 0 {
+			continue
+		}
+		if templates, err = templates.ParseFiles(files...); err != nil {
+			err = fmt.Errorf("Unable to parse templates in '%s': %s", dirs[i], err.Error())
+			return nil, err
+		}
+	}
 	m := &Generator{
 		templates: templates,
 		output:    output,
@@ -79,9 +125,43 @@ func NewGenerator(output io.Writer) (*Generator, error) {
 	return m, nil
 }
 
+// ExportTemplates writes the embedded templates in the folder, as starting
+// point to override them. Existing files are only replaced with force.
+func ExportTemplates(dir string, force bool) (files []string, err error) {
+	entries, err := fs.ReadDir(EmbedK8sTemplates, "templates")
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		if _, errS := os.Stat(path); errS == nil && !force {
+			err = fmt.Errorf("Template '%s' already exists, use force to overwrite it", path)
+			return
+		}
+		files = append(files, path)
+	}
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		err = fmt.Errorf("Unable to create templates folder '%s': %s", dir, err.Error())
+		return
+	}
+	for i, entry := range entries {
+		content, errR := EmbedK8sTemplates.ReadFile("templates/" + entry.Name())
+		if errR != nil {
+			return nil, errR
+		}
+		if err = ioutil.WriteFile(files[i], content, 0644); err != nil {
+			err = fmt.Errorf("Unable to write template '%s': %s", files[i], err.Error())
+			return
+		}
+	}
+	return
+}
+
 func (m *Generator) Generate(kind ManifestType, data *ContextData) (err error) {
 	if kind == CF {
 		err = fmt.Errorf("Cannot generate CF manifest file")
+	} else if kind.Folder() {
+		err = fmt.Errorf("Cannot generate %s manifests in a file, they are a folder", kind.String())
 	} else {
 		if filename := kind.Filename(); filename != "" {
 			err = m.templates.ExecuteTemplate(m.output, filename+".tmpl", data)
@@ -92,7 +172,16 @@ func (m *Generator) Generate(kind ManifestType, data *ContextData) (err error) {
 	return
 }
 
-func New(kind ManifestType, data *ContextData, fullpath string, truncate bool) error {
+// New writes the manifest of the kind in fullpath, with the templates of the
+// folders overriding the embedded ones. Without truncate the existing chart
+// and kustomize folders are not overwritten.
+func New(kind ManifestType, data *ContextData, fullpath string, truncate bool, dirs ...string) error {
+	switch kind {
+	case Helm:
+		return NewChart(data, fullpath, truncate, dirs...)
+	case Kustomize:
+		return NewKustomize(data, fullpath, truncate, dirs...)
+	}
 	flags := os.O_RDWR | os.O_CREATE
 	if truncate {
 		flags = os.O_RDWR | os.O_CREATE | os.O_TRUNC
@@ -103,9 +192,62 @@ func New(kind ManifestType, data *ContextData, fullpath string, truncate bool) e
 		return err
 	}
 	defer target.Close()
-	m, err := NewGenerator(target)
+	m, err := NewGenerator(target, dirs...)
 	if err != nil {
 		return err
 	}
 	return m.Generate(kind, data)
 }
+
+// NewChart writes a Helm chart in the folder: Chart.yaml and values.yaml from
+// their templates (they can be overridden like the manifests ones) and the
+// templates of the chart, rendering the values like deploy.yml
+func NewChart(data *ContextData, dir string, truncate bool, dirs ...string) (err error) {
+	chart := filepath.Join(dir, "Chart.yaml")
+	if _, errS := os.Stat(chart); errS == nil && !truncate {
+		err = fmt.Errorf("Helm chart '%s' already exists", dir)
+		return
+	}
+	// The routing objects are rendered by the chart, check the routes with
+	// the provider before
+	for _, app := range data.Apps {
+		if _, err = data.RoutingObjects(app); err != nil {
+			return
+		}
+	}
+	if err = os.MkdirAll(filepath.Join(dir, "templates"), 0755); err != nil {
+		err = fmt.Errorf("Unable to create Helm chart folder '%s': %s", dir, err.Error())
+		return
+	}
+	m, err := NewGenerator(nil, dirs...)
+	if err != nil {
+		return
+	}
+	for _, filename := range []string{"Chart.yaml", "values.yaml"} {
+		var output bytes.Buffer
+		if err = m.templates.ExecuteTemplate(&output, filename+".tmpl", data); err != nil {
+			return
+		}
+		path := filepath.Join(dir, filename)
+		if err = ioutil.WriteFile(path, output.Bytes(), 0644); err != nil {
+			err = fmt.Errorf("Unable to write Helm chart file '%s': %s", path, err.Error())
+			return
+		}
+	}
+	entries, err := fs.ReadDir(EmbedChartTemplates, "chart/templates")
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		content, errR := EmbedChartTemplates.ReadFile("chart/templates/" + entry.Name())
+		if errR != nil {
+			return errR
+		}
+		path := filepath.Join(dir, "templates", entry.Name())
+		if err = ioutil.WriteFile(path, content, 0644); err != nil {
+			err = fmt.Errorf("Unable to write Helm chart file '%s': %s", path, err.Error())
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,494 @@
+package manifests
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"text/template"
+	"time"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+// testContextData returns the context of the CF manifest in the folder of
+// testdata, with fixed dates and versions to render the same manifests
+func testContextData(t *testing.T, dir string) *ContextData {
+	date := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	data := &ContextData{
+		Dir:             dir,
+		Name:            filepath.Base(dir),
+		Date:            date,
+		DateHuman:       date.String(),
+		SourceDate:      date,
+		Registry:        "registry.example.com",
+		Ref:             "0123456789ab",
+		Team:            "team",
+		Env:             map[string]string{},
+		Args:            map[string]string{},
+		Apps:            []*AppData{},
+		Kubevela:        &KubeData{NameSpace: "team-ns", Environment: "dev", Cluster: "dev"},
+		DefaultWorkload: WorkloadAuto,
+		CF: &CfData{
+			Org:      "org",
+			Space:    "space",
+			Manifest: &CfManifest{Path: dir, Filename: "manifest.yml"},
+		},
+	}
+	rs := NewDefaultResourceData()
+	rs.Domain = "apps.example.com"
+	if err := data.GetAppContextMetadata(dir, "", "", nil, rs, true); err != nil {
+		t.Fatalf("Unable to get the metadata of '%s': %s", dir, err.Error())
+	}
+	return data
+}
+
+func TestGenerateK8S(t *testing.T) {
+	cases := []struct {
+		name    string
+		setup   func(data *ContextData)
+		objects []string
+	}{
+		{
+			name:    "single-app",
+			objects: []string{"Service/webapp", "VirtualService/webapp", "Deployment/webapp"},
+		},
+		{
+			name: "multi-app",
+			objects: []string{
+				"Service/frontend", "VirtualService/frontend", "Deployment/frontend",
+				"Service/backend", "VirtualService/backend", "Deployment/backend",
+				"Service/database", "StatefulSet/database",
+			},
+		},
+		{
+			name:    "random-route",
+			objects: []string{"Service/webapp", "VirtualService/webapp", "Deployment/webapp"},
+		},
+		{
+			name:    "no-route",
+			objects: []string{"Service/worker", "Deployment/worker"},
+		},
+		{
+			name: "scheduling",
+			setup: func(data *ContextData) {
+				data.Scheduling = &SchedulingData{
+					Tolerations: []TolerationData{
+						{Key: "dedicated", Operator: "Equal", Value: "team", Effect: "NoSchedule"},
+						{Key: "spot", Operator: "Exists"},
+					},
+					NodeSelector: map[string]string{"kubernetes.io/arch": "amd64"},
+					Affinity: []AffinityData{
+						{Key: "dedicated", Operator: "In", Values: []string{"team"}},
+						{Key: "node.kubernetes.io/instance-type", Operator: "In", Values: []string{"m5.large", "m5.xlarge"}, Weight: 50},
+						{Key: "spot", Operator: "DoesNotExist", Weight: 10},
+					},
+					PodAffinity: []PodAffinityData{
+						{TopologyKey: "topology.kubernetes.io/zone", Labels: map[string]string{"app": "database"}, Weight: 100},
+					},
+					PodAntiAffinity: []PodAffinityData{
+						{TopologyKey: "kubernetes.io/hostname"},
+						{TopologyKey: "topology.kubernetes.io/zone", Weight: 50},
+					},
+					TopologySpread: []TopologySpreadData{
+						{MaxSkew: 1, TopologyKey: "topology.kubernetes.io/zone", WhenUnsatisfiable: "ScheduleAnyway"},
+						{MaxSkew: 2, TopologyKey: "kubernetes.io/hostname", WhenUnsatisfiable: "DoNotSchedule"},
+					},
+					PriorityClassName: "high-priority",
+				}
+			},
+			objects: []string{
+				"Service/webapp", "VirtualService/webapp", "Deployment/webapp",
+				"Service/database", "StatefulSet/database",
+			},
+		},
+		{
+			name: "ingress",
+			setup: func(data *ContextData) {
+				data.Routing = &RoutingData{Provider: RoutingIngress, IngressClass: "nginx", TLSSecret: "apps-tls"}
+			},
+			objects: []string{"Service/webapp", "Ingress/webapp", "Deployment/webapp"},
+		},
+		{
+			name: "gateway",
+			setup: func(data *ContextData) {
+				data.Routing = &RoutingData{Provider: RoutingGateway, Gateways: []string{"gateway-ns/public"}}
+			},
+			objects: []string{
+				"Service/webapp", "HTTPRoute/webapp-0", "HTTPRoute/webapp-1", "Deployment/webapp",
+				"Service/database", "TCPRoute/database-tcp-5432", "Deployment/database",
+			},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			dir := filepath.Join("testdata", c.name)
+			data := testContextData(t, dir)
+			if c.setup != nil {
+				c.setup(data)
+			}
+			var output bytes.Buffer
+			m, err := NewGenerator(&output)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = m.Generate(K8S, data); err != nil {
+				t.Fatal(err)
+			}
+			objects := k8sObjects(t, output.Bytes())
+			if len(objects) != len(c.objects) {
+				t.Fatalf("Expected objects %v, got %v", c.objects, objects)
+			}
+			for i := range objects {
+				if objects[i] != c.objects[i] {
+					t.Errorf("Expected object %s, got %s", c.objects[i], objects[i])
+				}
+			}
+			checkGolden(t, filepath.Join(dir, K8S.Filename()+".golden"), output.Bytes())
+		})
+	}
+}
+
+// checkGolden compares the output with the golden file, updated with -update
+func checkGolden(t *testing.T, golden string, output []byte) {
+	t.Helper()
+	if *update {
+		if err := ioutil.WriteFile(golden, output, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expected, err := ioutil.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("Unable to read golden file, run the tests with -update: %s", err.Error())
+	}
+	if !bytes.Equal(output, expected) {
+		t.Errorf("Output differs from '%s', run the tests with -update to review the changes:\n%s", golden, output)
+	}
+}
+
+func TestNewChart(t *testing.T) {
+	cases := []struct {
+		name  string
+		setup func(data *ContextData)
+	}{
+		{name: "multi-app"},
+		{
+			name: "gateway",
+			setup: func(data *ContextData) {
+				data.Routing = &RoutingData{Provider: RoutingGateway, Gateways: []string{"gateway-ns/public"}}
+			},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			dir := filepath.Join("testdata", c.name)
+			data := testContextData(t, dir)
+			if c.setup != nil {
+				c.setup(data)
+			}
+			chart := t.TempDir()
+			if err := NewChart(data, chart, false); err != nil {
+				t.Fatal(err)
+			}
+			for _, filename := range []string{"Chart.yaml", "values.yaml"} {
+				output, err := ioutil.ReadFile(filepath.Join(chart, filename))
+				if err != nil {
+					t.Fatal(err)
+				}
+				var values map[string]interface{}
+				if err = yaml.Unmarshal(output, &values); err != nil {
+					t.Fatalf("Invalid %s: %s", filename, err.Error())
+				}
+				checkGolden(t, filepath.Join(dir, filename+".golden"), output)
+			}
+			rendered := renderChart(t, chart, nil)
+			checkGolden(t, filepath.Join(dir, "chart.yaml.golden"), rendered)
+			// The chart renders the same objects as deploy.yml
+			var output bytes.Buffer
+			m, err := NewGenerator(&output)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = m.Generate(K8S, data); err != nil {
+				t.Fatal(err)
+			}
+			// Without the route annotations of the pods, the values list the
+			// routes in the order of precedence
+			expected, got := k8sSpecs(t, output.Bytes()), k8sSpecs(t, rendered)
+			for object, spec := range expected {
+				if !reflect.DeepEqual(spec, got[object]) {
+					t.Errorf("Spec of %s differs from deploy.yml:\n%v\n%v", object, spec, got[object])
+				}
+			}
+			if len(expected) != len(got) {
+				t.Errorf("Expected objects %v, got %v", keys(expected), keys(got))
+			}
+		})
+	}
+}
+
+func TestChartRoutesValues(t *testing.T) {
+	data := testContextData(t, filepath.Join("testdata", "multi-app"))
+	chart := t.TempDir()
+	if err := NewChart(data, chart, false); err != nil {
+		t.Fatal(err)
+	}
+	// Like helm install --set, the routes of the values are rendered
+	rendered := string(renderChart(t, chart, func(values map[string]interface{}) {
+		app := values["apps"].(map[string]interface{})["frontend"].(map[string]interface{})
+		app["routes"] = []interface{}{
+			map[string]interface{}{"protocol": "http1", "host": "shop.example.org", "path": "/cart", "servicePort": 80},
+		}
+		values["routing"].(map[string]interface{})["gateways"] = []interface{}{"istio-system/public"}
+	}))
+	for _, expected := range []string{"exact: \"shop.example.org\"", "prefix: \"/cart\"", "\"kubefoundry/route.0.0\": \"shop.example.org/cart\"", "- istio-system/public"} {
+		if !strings.Contains(rendered, expected) {
+			t.Errorf("Expected '%s' in the rendered chart:\n%s", expected, rendered)
+		}
+	}
+}
+
+// renderChart renders the templates of the chart with its values, changed
+// by the function, like Helm: with the helpers of the chart and the Helm
+// functions used by the templates
+func renderChart(t *testing.T, chart string, change func(values map[string]interface{})) []byte {
+	t.Helper()
+	content, err := ioutil.ReadFile(filepath.Join(chart, "values.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := make(map[string]interface{})
+	if err = yaml.Unmarshal(content, &values); err != nil {
+		t.Fatal(err)
+	}
+	if change != nil {
+		change(values)
+	}
+	templates := template.New("chart").Option("missingkey=zero").Funcs(Funcs())
+	templates.Funcs(template.FuncMap{
+		"include": func(name string, data interface{}) (string, error) {
+			var output bytes.Buffer
+			err := templates.ExecuteTemplate(&output, name, data)
+			return output.String(), err
+		},
+		"append": func(list []interface{}, v interface{}) []interface{} { return append(list, v) },
+		"has": func(needle interface{}, haystack []interface{}) bool {
+			for _, v := range haystack {
+				if reflect.DeepEqual(v, needle) {
+					return true
+				}
+			}
+			return false
+		},
+		"fail":     func(msg string) (string, error) { return "", errors.New(msg) },
+		"toString": func(v interface{}) string { return fmt.Sprint(v) },
+	})
+	if _, err = templates.ParseGlob(filepath.Join(chart, "templates", "*")); err != nil {
+		t.Fatalf("Invalid chart templates: %s", err.Error())
+	}
+	files, err := filepath.Glob(filepath.Join(chart, "templates", "*.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(files)
+	root := map[string]interface{}{
+		"Values":  values,
+		"Release": map[string]interface{}{"Name": "release", "Namespace": "default"},
+	}
+	var output bytes.Buffer
+	for _, file := range files {
+		if err = templates.ExecuteTemplate(&output, filepath.Base(file), root); err != nil {
+			t.Fatalf("Unable to render %s: %s", filepath.Base(file), err.Error())
+		}
+		output.WriteString("\n")
+	}
+	return []byte(strings.ReplaceAll(output.String(), "<no value>", ""))
+}
+
+// k8sSpecs returns the specs of the objects of the manifest by kind/name,
+// without the route annotations of the pods
+func k8sSpecs(t *testing.T, manifest []byte) map[string]interface{} {
+	specs := make(map[string]interface{})
+	decoder := yaml.NewDecoder(bytes.NewReader(manifest))
+	for {
+		var object struct {
+			Kind     string `yaml:"kind"`
+			Metadata struct {
+				Name string `yaml:"name"`
+			} `yaml:"metadata"`
+			Spec interface{} `yaml:"spec"`
+		}
+		err := decoder.Decode(&object)
+		if errors.Is(err, io.EOF) {
+			return specs
+		} else if err != nil {
+			t.Fatalf("Invalid manifest: %s\n%s", err.Error(), manifest)
+		}
+		if object.Kind == "" {
+			continue
+		}
+		if spec, ok := object.Spec.(map[string]interface{}); ok {
+			if pod, ok := spec["template"].(map[string]interface{}); ok {
+				annotations, _ := pod["metadata"].(map[string]interface{})["annotations"].(map[string]interface{})
+				for key := range annotations {
+					if strings.HasPrefix(key, "kubefoundry/route.") {
+						delete(annotations, key)
+					}
+				}
+			}
+		}
+		specs[object.Kind+"/"+object.Metadata.Name] = object.Spec
+	}
+}
+
+func keys(m map[string]interface{}) (k []string) {
+	for key := range m {
+		k = append(k, key)
+	}
+	sort.Strings(k)
+	return
+}
+
+func TestNewKustomize(t *testing.T) {
+	cases := []struct {
+		name  string
+		setup func(data *ContextData)
+		envs  []string
+	}{
+		{name: "single-app", envs: []string{"dev"}},
+		{
+			name: "multi-app",
+			setup: func(data *ContextData) {
+				data.Environments = []*EnvironmentData{
+					{Name: "staging"},
+					{Name: "prod", NameSpace: "team-prod", Instances: 2, Mem: "2G", CPU: "2", Domain: "prod.example.com"},
+				}
+			},
+			envs: []string{"staging", "prod"},
+		},
+		{
+			name: "gateway",
+			setup: func(data *ContextData) {
+				data.Routing = &RoutingData{Provider: RoutingGateway, Gateways: []string{"gateway-ns/public"}}
+				data.Environments = []*EnvironmentData{{Name: "prod", Domain: "prod.example.com"}}
+			},
+			envs: []string{"prod"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			dir := filepath.Join("testdata", c.name)
+			data := testContextData(t, dir)
+			if c.setup != nil {
+				c.setup(data)
+			}
+			kustomize := t.TempDir()
+			// Overlay of an environment removed from the configuration
+			removed := filepath.Join(kustomize, "overlays", "removed")
+			if err := os.MkdirAll(removed, 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := ioutil.WriteFile(filepath.Join(removed, "kustomization.yaml"), []byte("resources:\n  - ../../base\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err := NewKustomize(data, kustomize, false); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := os.Stat(removed); !os.IsNotExist(err) {
+				t.Errorf("Expected the overlay of the removed environment to be removed")
+			}
+			for _, filename := range []string{K8S.Filename(), "kustomization.yaml"} {
+				if _, err := os.Stat(filepath.Join(kustomize, "base", filename)); err != nil {
+					t.Errorf("Base without %s: %s", filename, err.Error())
+				}
+			}
+			for _, env := range c.envs {
+				output, err := ioutil.ReadFile(filepath.Join(kustomize, "overlays", env, "kustomization.yaml"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				var overlay map[string]interface{}
+				if err = yaml.Unmarshal(output, &overlay); err != nil {
+					t.Fatalf("Invalid overlay of %s: %s", env, err.Error())
+				}
+				checkGolden(t, filepath.Join(dir, "overlay-"+env+".yaml.golden"), output)
+			}
+		})
+	}
+}
+
+func TestNewOverWrite(t *testing.T) {
+	data := testContextData(t, filepath.Join("testdata", "single-app"))
+	dir := t.TempDir()
+	for _, kind := range []ManifestType{K8S, Helm, Kustomize} {
+		fullpath := filepath.Join(dir, kind.Filename())
+		if err := New(kind, data, fullpath, false); err != nil {
+			t.Fatal(err)
+		}
+		// Only the folders are not overwritten
+		if err := New(kind, data, fullpath, false); (err == nil) == kind.Folder() {
+			t.Errorf("Unexpected result overwriting the %s manifest without truncate: %v", kind.String(), err)
+		}
+		if err := New(kind, data, fullpath, true); err != nil {
+			t.Errorf("Unable to overwrite the %s manifest: %s", kind.String(), err.Error())
+		}
+	}
+}
+
+// k8sObjects returns the kind and the name of the objects of the manifest,
+// the decoder fails with duplicated keys
+func k8sObjects(t *testing.T, manifest []byte) (objects []string) {
+	decoder := yaml.NewDecoder(bytes.NewReader(manifest))
+	for {
+		var object struct {
+			Kind     string `yaml:"kind"`
+			Metadata struct {
+				Name string `yaml:"name"`
+			} `yaml:"metadata"`
+		}
+		err := decoder.Decode(&object)
+		if errors.Is(err, io.EOF) {
+			return
+		} else if err != nil {
+			t.Fatalf("Invalid manifest: %s", err.Error())
+		}
+		if object.Kind != "" {
+			objects = append(objects, object.Kind+"/"+object.Metadata.Name)
+		}
+	}
+}
+
+func TestGenerateRoutingErrors(t *testing.T) {
+	cases := []struct {
+		name    string
+		dir     string
+		routing *RoutingData
+	}{
+		{name: "gateway without gateways", dir: "ingress", routing: &RoutingData{Provider: RoutingGateway}},
+		{name: "ingress with TCP route", dir: "gateway", routing: &RoutingData{Provider: RoutingIngress}},
+		{name: "unknown provider", dir: "single-app", routing: &RoutingData{Provider: "traefik"}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			data := testContextData(t, filepath.Join("testdata", c.dir))
+			data.Routing = c.routing
+			m, err := NewGenerator(ioutil.Discard)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = m.Generate(K8S, data); err == nil {
+				t.Errorf("Expected error with the routing provider '%s'", c.routing.Provider)
+			}
+		})
+	}
+}
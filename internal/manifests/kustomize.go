@@ -0,0 +1,227 @@
+package manifests
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// EnvironmentData is an environment with a kustomize overlay of the base
+// manifests, the settings not defined are the ones of the base
+type EnvironmentData struct {
+	Name      string
+	NameSpace string
+	Instances int
+	// Resources of the applications with the defaults of the base
+	CPU  string
+	Mem  string
+	Disk string
+	// Domain of the routes in the default domain
+	Domain string
+}
+
+// OverlayApp is an application patched in an overlay, nil or zero fields
+// keep the base settings. Routes has the routes moved to the domain of the
+// environment by their index.
+type OverlayApp struct {
+	App       *AppData
+	Instances int
+	Resources *ResourcesData
+	Routes    map[int]*RouteData
+	Routing   []RoutingObject
+}
+
+// Patched returns true if the workload of the application has patches
+func (o *OverlayApp) Patched() bool {
+	return o.Resources != nil || len(o.Routes) > 0
+}
+
+// Kind returns the kind of the workload of the application
+func (o *OverlayApp) Kind() string {
+	if o.App.StatefulSet() {
+		return "StatefulSet"
+	}
+	return "Deployment"
+}
+
+// OverlayData is the data of the overlay template of an environment
+type OverlayData struct {
+	*ContextData
+	Environment *EnvironmentData
+	Overlays    []*OverlayApp
+}
+
+// Patches returns true if the applications have patches in the overlay
+func (o *OverlayData) Patches() bool {
+	for _, app := range o.Overlays {
+		if app.Patched() || len(app.Routing) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// overlayApp returns the patches of the application in the environment, the
+// resources are recalculated with the defaults (ratios of requests) and the
+// routes in the default domain are moved to the domain of the environment
+func (d *ContextData) overlayApp(app *AppData, env *EnvironmentData, rs *ResourceData) (overlay *OverlayApp, err error) {
+	overlay = &OverlayApp{App: app, Instances: env.Instances}
+	if env.CPU != "" || env.Mem != "" || env.Disk != "" {
+		envRs := *rs
+		mem, disk := env.Mem, env.Disk
+		if app.Resources != nil {
+			if mem == "" {
+				mem = app.Resources.Limits.Mem.String()
+			}
+			if disk == "" {
+				disk = app.Resources.Limits.Disk.String()
+			}
+			if envRs.MemPerCPU == "" {
+				envRs.CPU = app.Resources.Limits.CPU.String()
+			}
+		}
+		if env.CPU != "" {
+			envRs.CPU, envRs.MemPerCPU = env.CPU, ""
+		}
+		if overlay.Resources, err = envRs.GetResources(mem, disk); err != nil {
+			err = fmt.Errorf("Invalid resources of application '%s' in environment '%s': %s", app.Name, env.Name, err.Error())
+			return nil, err
+		}
+	}
+	if env.Domain == "" || env.Domain == rs.Domain {
+		return
+	}
+	moved := make(map[int]*RouteData)
+	routes := []*RouteData{}
+	for i, r := range app.Routes {
+		route := *r
+		if route.Domain == rs.Domain {
+			route.Domain = env.Domain
+			moved[i] = &route
+		}
+		routes = append(routes, &route)
+	}
+	if len(moved) == 0 {
+		return
+	}
+	envApp := *app
+	envApp.Routes = routes
+	if overlay.Routing, err = d.RoutingObjects(&envApp); err != nil {
+		return nil, err
+	}
+	overlay.Routes = moved
+	return
+}
+
+// Overlay returns the data of the overlay of the environment
+func (d *ContextData) Overlay(env *EnvironmentData) (overlay *OverlayData, err error) {
+	rs := d.Defaults
+	if rs == nil {
+		rs = NewDefaultResourceData()
+	}
+	overlay = &OverlayData{ContextData: d, Environment: env}
+	for _, app := range d.Apps {
+		o, errO := d.overlayApp(app, env, rs)
+		if errO != nil {
+			return nil, errO
+		}
+		overlay.Overlays = append(overlay.Overlays, o)
+	}
+	return
+}
+
+type kustomizeFile struct {
+	path     string
+	template string
+	data     interface{}
+}
+
+// NewKustomize writes the kustomize base with the K8S manifest and an overlay
+// for each environment of the context in the folder, by default the one of
+// KubeVela. The environments without namespace are in the KubeVela one and
+// the overlays of other environments are removed.
+func NewKustomize(data *ContextData, dir string, truncate bool, dirs ...string) (err error) {
+	base := filepath.Join(dir, "base")
+	if _, errS := os.Stat(base); errS == nil && !truncate {
+		err = fmt.Errorf("Kustomize folder '%s' already exists", dir)
+		return
+	}
+	m, err := NewGenerator(nil, dirs...)
+	if err != nil {
+		return
+	}
+	files := []kustomizeFile{
+		{path: filepath.Join(base, K8S.Filename()), template: K8S.Filename() + ".tmpl", data: data},
+		{path: filepath.Join(base, "kustomization.yaml"), template: "kustomization.yaml.tmpl", data: data},
+	}
+	envs := data.Environments
+	if len(envs) == 0 && data.Kubevela != nil && data.Kubevela.Environment != "" {
+		envs = []*EnvironmentData{{Name: data.Kubevela.Environment}}
+	}
+	for _, env := range envs {
+		if env.Name == "" {
+			err = fmt.Errorf("Kustomize overlay of an environment without name")
+			return
+		}
+		e := *env
+		if e.NameSpace == "" && data.Kubevela != nil {
+			e.NameSpace = data.Kubevela.NameSpace
+		}
+		overlay, errO := data.Overlay(&e)
+		if errO != nil {
+			return errO
+		}
+		files = append(files, kustomizeFile{
+			path:     filepath.Join(dir, "overlays", e.Name, "kustomization.yaml"),
+			template: "overlay.yaml.tmpl",
+			data:     overlay,
+		})
+	}
+	if err = removeOverlays(filepath.Join(dir, "overlays"), envs); err != nil {
+		return
+	}
+	for _, f := range files {
+		var output bytes.Buffer
+		if err = m.templates.ExecuteTemplate(&output, f.template, f.data); err != nil {
+			return
+		}
+		if err = os.MkdirAll(filepath.Dir(f.path), 0755); err != nil {
+			err = fmt.Errorf("Unable to create kustomize folder '%s': %s", filepath.Dir(f.path), err.Error())
+			return
+		}
+		if err = ioutil.WriteFile(f.path, output.Bytes(), 0644); err != nil {
+			err = fmt.Errorf("Unable to write kustomize file '%s': %s", f.path, err.Error())
+			return
+		}
+	}
+	return
+}
+
+// removeOverlays removes the overlays of the folder which are not of the
+// environments, they would still deploy the removed environments
+func removeOverlays(dir string, envs []*EnvironmentData) (err error) {
+	entries, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		err = fmt.Errorf("Unable to read kustomize overlays '%s': %s", dir, err.Error())
+		return
+	}
+	names := make(map[string]bool)
+	for _, env := range envs {
+		names[env.Name] = true
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() || names[entry.Name()] {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		if err = os.RemoveAll(path); err != nil {
+			err = fmt.Errorf("Unable to remove kustomize overlay '%s': %s", path, err.Error())
+			return
+		}
+	}
+	return
+}
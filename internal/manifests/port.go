@@ -0,0 +1,81 @@
+package manifests
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// PortData is a listening port of an application, the first port is exposed
+// in the default port of the service and the rest in their own port
+type PortData struct {
+	Port        int
+	ServicePort int
+	Protocol    string
+}
+
+// Name returns the name of the port in the container and the service
+func (p *PortData) Name() string {
+	if p.Protocol == ProtocolHTTP2 {
+		return "http2-" + strconv.Itoa(p.Port)
+	}
+	return "http-" + strconv.Itoa(p.Port)
+}
+
+// HTTP2 returns true if the port receives HTTP/2 routes
+func (p *PortData) HTTP2() bool {
+	return p.Protocol == ProtocolHTTP2
+}
+
+// newPorts returns the ports of an application, without ports the default one
+func newPorts(name string, ports []int, defaultPort int) (appPorts []*PortData, err error) {
+	if len(ports) == 0 {
+		ports = []int{defaultPort}
+	}
+	for i, port := range ports {
+		if port <= 0 || port > 65535 {
+			err = fmt.Errorf("Invalid port %d of application '%s'", port, name)
+			return nil, err
+		}
+		for _, p := range appPorts {
+			if p.Port == port {
+				err = fmt.Errorf("Duplicated port %d of application '%s'", port, name)
+				return nil, err
+			}
+		}
+		servicePort := port
+		if i == 0 {
+			servicePort = DefaultServicePort
+		} else if port == DefaultServicePort {
+			err = fmt.Errorf("Port %d of application '%s' collides with the service port of its first port, list it first", port, name)
+			return nil, err
+		}
+		appPorts = append(appPorts, &PortData{
+			Port:        port,
+			ServicePort: servicePort,
+			Protocol:    ProtocolHTTP1,
+		})
+	}
+	return
+}
+
+// GetPort returns the listening port of the application, nil if it is not one
+// of its ports
+func (a *AppData) GetPort(port int) *PortData {
+	for _, p := range a.Ports {
+		if p.Port == port {
+			return p
+		}
+	}
+	return nil
+}
+
+// PortList returns the listening ports of the application separated by comma,
+// the format of APP_PORTS in the runtime
+func (a *AppData) PortList() string {
+	ports := make([]string, len(a.Ports))
+	for i, p := range a.Ports {
+		ports[i] = strconv.Itoa(p.Port)
+	}
+	return strings.Join(ports, ",")
+}
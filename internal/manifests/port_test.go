@@ -0,0 +1,78 @@
+package manifests
+
+import (
+	"testing"
+)
+
+func TestNewPorts(t *testing.T) {
+	cases := []struct {
+		name         string
+		ports        []int
+		servicePorts []int
+		err          bool
+	}{
+		{name: "default port", servicePorts: []int{80}},
+		{name: "several ports", ports: []int{8080, 9090}, servicePorts: []int{80, 9090}},
+		{name: "port 80 first", ports: []int{80, 8080}, servicePorts: []int{80, 8080}},
+		{name: "port 80 collides with the first port", ports: []int{8080, 80}, err: true},
+		{name: "duplicated port", ports: []int{8080, 8080}, err: true},
+		{name: "invalid port", ports: []int{70000}, err: true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ports, err := newPorts("app", c.ports, 8080)
+			if c.err {
+				if err == nil {
+					t.Fatalf("Expected error, got ports %v", ports)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(ports) != len(c.servicePorts) {
+				t.Fatalf("Expected service ports %v, got %d ports", c.servicePorts, len(ports))
+			}
+			for i, p := range ports {
+				if p.ServicePort != c.servicePorts[i] {
+					t.Errorf("Expected service port %d of port %d, got %d", c.servicePorts[i], p.Port, p.ServicePort)
+				}
+			}
+		})
+	}
+}
+
+func TestAppRoutesTCPCollision(t *testing.T) {
+	cases := []struct {
+		name   string
+		ports  []int
+		routes []CfRoute
+		err    bool
+	}{
+		{name: "own port", ports: []int{5432}, routes: []CfRoute{{Route: "db.apps.example.com:5432"}}},
+		{name: "default service port", ports: []int{8080}, routes: []CfRoute{{Route: "db.apps.example.com:80"}}, err: true},
+		{name: "service port of other port", ports: []int{8080, 9090}, routes: []CfRoute{{Route: "db.apps.example.com:9090"}}, err: true},
+		{
+			name:   "same port in two TCP routes",
+			ports:  []int{5432},
+			routes: []CfRoute{{Route: "db.apps.example.com:5432"}, {Route: "replica.apps.example.com:5432"}},
+			err:    true,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ports, err := newPorts("app", c.ports, 8080)
+			if err != nil {
+				t.Fatal(err)
+			}
+			app := &AppData{Name: "app", Port: ports[0].Port, Ports: ports}
+			d := &ContextData{}
+			routes, err := d.appRoutes(app, c.routes, &ResourceData{Domain: "apps.example.com"})
+			if c.err && err == nil {
+				t.Errorf("Expected error, got routes %v", routes)
+			} else if !c.err && err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package manifests
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// CF sizes, the units are always powers of 1024: 256M, 1.5GB, 1T
+var cfSizeRegex = regexp.MustCompile(`^(\d+(?:\.\d+)?) ?([bkmgt]?)(?:i?b)?$`)
+
+var cfSizeUnits = map[string]int64{
+	"":  1,
+	"b": 1,
+	"k": 1 << 10,
+	"m": 1 << 20,
+	"g": 1 << 30,
+	"t": 1 << 40,
+}
+
+// ParseSize returns the quantity of a memory or disk size with the CF units
+// (M, MB, G, GB, T) or with the Kubernetes ones (Mi, Gi). The quantities are
+// binary to render them as Mi or Gi.
+func ParseSize(size string) (q resource.Quantity, err error) {
+	size = strings.TrimSpace(size)
+	if matches := cfSizeRegex.FindStringSubmatch(strings.ToLower(size)); matches != nil {
+		value, errP := strconv.ParseFloat(matches[1], 64)
+		if errP != nil {
+			err = fmt.Errorf("Invalid size '%s': %s", size, errP.Error())
+			return
+		}
+		bytes := int64(math.Round(value * float64(cfSizeUnits[matches[2]])))
+		q = *resource.NewQuantity(bytes, resource.BinarySI)
+	} else if q, err = resource.ParseQuantity(size); err != nil {
+		err = fmt.Errorf("Invalid size '%s': %s", size, err.Error())
+		return
+	}
+	if q.Sign() <= 0 {
+		err = fmt.Errorf("Invalid size '%s', it must be greater than zero", size)
+	}
+	return
+}
+
+// ParseCPU returns the quantity of cpu cores: 1, 0.5 or 500m
+func ParseCPU(cpu string) (q resource.Quantity, err error) {
+	if q, err = resource.ParseQuantity(strings.TrimSpace(cpu)); err != nil {
+		err = fmt.Errorf("Invalid cpu '%s': %s", cpu, err.Error())
+		return
+	}
+	if q.Sign() <= 0 {
+		err = fmt.Errorf("Invalid cpu '%s', it must be greater than zero", cpu)
+	}
+	return
+}
+
+// ResourceList is the cpu, memory and disk (ephemeral storage) of an instance
+type ResourceList struct {
+	CPU  resource.Quantity
+	Mem  resource.Quantity
+	Disk resource.Quantity
+}
+
+// ResourcesData are the resources requested by the instances of an
+// application and their limits
+type ResourcesData struct {
+	Requests ResourceList
+	Limits   ResourceList
+}
+
+// ratio returns the quantity multiplied by the ratio of requests
+func ratio(q resource.Quantity, r float64) resource.Quantity {
+	if r <= 0 || r >= 1 {
+		return q.DeepCopy()
+	}
+	if q.Format == resource.BinarySI {
+		// Rounded to Mi
+		mi := int64(math.Ceil(float64(q.Value()) * r / float64(1<<20)))
+		return *resource.NewQuantity(mi<<20, resource.BinarySI)
+	}
+	return *resource.NewMilliQuantity(int64(math.Ceil(float64(q.MilliValue())*r)), q.Format)
+}
+
+// GetResources returns the resources of an application with the memory and
+// the disk, the defaults are used when they are empty. With memory per cpu,
+// the cpu is proportional to the memory instead of the default one.
+func (rs *ResourceData) GetResources(mem, disk string) (resources *ResourcesData, err error) {
+	if mem == "" {
+		mem = rs.Mem
+	}
+	if disk == "" {
+		disk = rs.Disk
+	}
+	limits := ResourceList{}
+	if limits.Mem, err = ParseSize(mem); err != nil {
+		return
+	}
+	if limits.Disk, err = ParseSize(disk); err != nil {
+		return
+	}
+	if rs.MemPerCPU != "" {
+		memPerCPU, errM := ParseSize(rs.MemPerCPU)
+		if errM != nil {
+			err = fmt.Errorf("Invalid memory per cpu: %s", errM.Error())
+			return
+		}
+		millis := int64(math.Ceil(float64(limits.Mem.Value()) * 1000 / float64(memPerCPU.Value())))
+		limits.CPU = *resource.NewMilliQuantity(millis, resource.DecimalSI)
+	} else if limits.CPU, err = ParseCPU(rs.CPU); err != nil {
+		return
+	}
+	for _, r := range []float64{rs.CPURequests, rs.MemRequests} {
+		if r < 0 || r > 1 {
+			err = fmt.Errorf("Invalid ratio of requests %v, it must be between 0 and 1", r)
+			return
+		}
+	}
+	resources = &ResourcesData{
+		Limits: limits,
+		Requests: ResourceList{
+			CPU:  ratio(limits.CPU, rs.CPURequests),
+			Mem:  ratio(limits.Mem, rs.MemRequests),
+			Disk: limits.Disk.DeepCopy(),
+		},
+	}
+	return
+}
@@ -0,0 +1,141 @@
+package manifests
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Protocols of the routes
+const (
+	ProtocolHTTP1 string = "http1"
+	ProtocolHTTP2 string = "http2"
+	ProtocolTCP   string = "tcp"
+)
+
+// RouteData is a route of an application with the CF format
+// [host.]domain[:port][/path], only the TCP routes have port
+type RouteData struct {
+	Host     string
+	Domain   string
+	Path     string
+	Port     int
+	Protocol string
+	// Ports of the application and the service receiving the traffic
+	AppPort     int
+	ServicePort int
+}
+
+// ParseRoute parses a CF route, the host is split from the domain with the
+// default domain or the first label of the routes with three or more labels
+func ParseRoute(route, protocol, domain string, appPort int) (r *RouteData, err error) {
+	r = &RouteData{
+		Protocol: strings.ToLower(protocol),
+		AppPort:  appPort,
+	}
+	route = strings.TrimSpace(route)
+	hostname := strings.ToLower(route)
+	if i := strings.Index(hostname, "/"); i >= 0 {
+		hostname, r.Path = hostname[:i], strings.TrimSuffix(route[i:], "/")
+	}
+	if i := strings.LastIndex(hostname, ":"); i >= 0 {
+		if r.Port, err = strconv.Atoi(hostname[i+1:]); err != nil || r.Port <= 0 || r.Port > 65535 {
+			err = fmt.Errorf("Invalid port in route '%s'", route)
+			return nil, err
+		}
+		hostname = hostname[:i]
+	}
+	if hostname == "" {
+		err = fmt.Errorf("Invalid route '%s' without domain", route)
+		return nil, err
+	}
+	switch r.Protocol {
+	case "":
+		r.Protocol = ProtocolHTTP1
+		if r.Port > 0 {
+			r.Protocol = ProtocolTCP
+		}
+	case ProtocolHTTP1, ProtocolHTTP2:
+		if r.Port > 0 {
+			err = fmt.Errorf("Invalid route '%s', only the TCP routes have port", route)
+			return nil, err
+		}
+	case ProtocolTCP:
+		if r.Port == 0 {
+			err = fmt.Errorf("Invalid TCP route '%s' without port", route)
+			return nil, err
+		}
+	default:
+		err = fmt.Errorf("Unknown protocol '%s' of route '%s', use '%s', '%s' or '%s'", protocol, route, ProtocolHTTP1, ProtocolHTTP2, ProtocolTCP)
+		return nil, err
+	}
+	if r.Protocol == ProtocolTCP && r.Path != "" {
+		err = fmt.Errorf("Invalid TCP route '%s' with path", route)
+		return nil, err
+	}
+	domain = strings.ToLower(domain)
+	switch {
+	case r.Protocol == ProtocolTCP || hostname == domain:
+		r.Domain = hostname
+	case domain != "" && strings.HasSuffix(hostname, "."+domain):
+		r.Host, r.Domain = strings.TrimSuffix(hostname, "."+domain), domain
+	case strings.Count(hostname, ".") >= 2:
+		i := strings.Index(hostname, ".")
+		r.Host, r.Domain = hostname[:i], hostname[i+1:]
+	default:
+		r.Domain = hostname
+	}
+	return r, nil
+}
+
+// Hostname returns the host and the domain of the route
+func (r *RouteData) Hostname() string {
+	if r.Host == "" {
+		return r.Domain
+	}
+	return r.Host + "." + r.Domain
+}
+
+// TCP returns true for the TCP routes
+func (r *RouteData) TCP() bool {
+	return r.Protocol == ProtocolTCP
+}
+
+// String returns the route in the CF format
+func (r *RouteData) String() string {
+	route := r.Hostname()
+	if r.Port > 0 {
+		route += ":" + strconv.Itoa(r.Port)
+	}
+	return route + r.Path
+}
+
+// HTTPRoutes returns the HTTP routes of the application, the ones with
+// longer paths first to take precedence in the path prefix matches
+func (a *AppData) HTTPRoutes() (routes []*RouteData) {
+	for _, r := range a.Routes {
+		if !r.TCP() {
+			routes = append(routes, r)
+		}
+	}
+	sort.SliceStable(routes, func(i, j int) bool {
+		return len(routes[i].Path) > len(routes[j].Path)
+	})
+	return
+}
+
+// Hostnames returns the hostnames of the HTTP routes of the application
+func (a *AppData) Hostnames() []string {
+	return hostnames(a.HTTPRoutes())
+}
+
+// TCPRoutes returns the TCP routes of the application
+func (a *AppData) TCPRoutes() (routes []*RouteData) {
+	for _, r := range a.Routes {
+		if r.TCP() {
+			routes = append(routes, r)
+		}
+	}
+	return
+}
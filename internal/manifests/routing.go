@@ -0,0 +1,395 @@
+package manifests
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Providers of the routing of the external traffic
+const (
+	RoutingIstio   string = "istio"
+	RoutingIngress string = "ingress"
+	RoutingGateway string = "gateway"
+)
+
+var (
+	DefaultIstioGateways     = []string{"istio-system/private", "istio-system/public", "mesh"}
+	DefaultServicePort   int = 80
+)
+
+type RoutingData struct {
+	Provider     string
+	Gateways     []string
+	IngressClass string
+	TLSSecret    string
+}
+
+// RoutingObject is a Kubernetes object of a routing provider, the templates
+// render the metadata and the spec
+type RoutingObject struct {
+	APIVersion string      `yaml:"apiVersion"`
+	Kind       string      `yaml:"kind"`
+	Name       string      `yaml:"name"`
+	Spec       interface{} `yaml:"spec"`
+}
+
+// RoutingProvider returns the objects routing the external traffic to the
+// service of an application
+type RoutingProvider interface {
+	Name() string
+	Objects(app *AppData) ([]RoutingObject, error)
+}
+
+// NewRoutingProvider returns the provider of the routing settings
+func NewRoutingProvider(r *RoutingData) (RoutingProvider, error) {
+	switch strings.ToLower(r.Provider) {
+	case "", RoutingIstio:
+		gateways := r.Gateways
+		if len(gateways) == 0 {
+			gateways = DefaultIstioGateways
+		}
+		return &istioRouting{gateways: gateways}, nil
+	case RoutingIngress:
+		return &ingressRouting{class: r.IngressClass, tlsSecret: r.TLSSecret}, nil
+	case RoutingGateway:
+		if len(r.Gateways) == 0 {
+			return nil, fmt.Errorf("Gateway API routing needs the gateways (namespace/name)")
+		}
+		return &gatewayRouting{gateways: r.Gateways}, nil
+	}
+	return nil, fmt.Errorf("Unknown routing provider '%s', use '%s', '%s' or '%s'", r.Provider, RoutingIstio, RoutingIngress, RoutingGateway)
+}
+
+// RoutingObjects returns the routing objects of the application with the
+// provider of the context, Istio by default
+func (d *ContextData) RoutingObjects(app *AppData) ([]RoutingObject, error) {
+	routing := d.Routing
+	if routing == nil {
+		routing = &RoutingData{Provider: RoutingIstio}
+	}
+	provider, err := NewRoutingProvider(routing)
+	if err != nil {
+		return nil, err
+	}
+	return provider.Objects(app)
+}
+
+// RoutingSettings returns the routing of the context with the defaults of
+// the provider, Istio with its default gateways by default
+func (d *ContextData) RoutingSettings() RoutingData {
+	routing := RoutingData{Provider: RoutingIstio}
+	if d.Routing != nil {
+		routing = *d.Routing
+		routing.Provider = strings.ToLower(routing.Provider)
+		if routing.Provider == "" {
+			routing.Provider = RoutingIstio
+		}
+	}
+	if routing.Provider == RoutingIstio && len(routing.Gateways) == 0 {
+		routing.Gateways = DefaultIstioGateways
+	}
+	return routing
+}
+
+// hostnames returns the hostnames of the routes without duplicates
+func hostnames(routes []*RouteData) (hosts []string) {
+	found := make(map[string]bool)
+	for _, r := range routes {
+		if hostname := r.Hostname(); !found[hostname] {
+			found[hostname] = true
+			hosts = append(hosts, hostname)
+		}
+	}
+	return
+}
+
+type istioRouting struct {
+	gateways []string
+}
+
+type istioPort struct {
+	Number int `yaml:"number"`
+}
+
+type istioDestination struct {
+	Host string    `yaml:"host"`
+	Port istioPort `yaml:"port"`
+}
+
+type istioRoute struct {
+	Destination istioDestination `yaml:"destination"`
+}
+
+type istioStringMatch struct {
+	Exact  string `yaml:"exact,omitempty"`
+	Prefix string `yaml:"prefix,omitempty"`
+}
+
+type istioHTTPMatch struct {
+	Authority *istioStringMatch `yaml:"authority,omitempty"`
+	URI       *istioStringMatch `yaml:"uri,omitempty"`
+}
+
+type istioHTTPRoute struct {
+	Match []istioHTTPMatch `yaml:"match,omitempty"`
+	Route []istioRoute     `yaml:"route"`
+}
+
+type istioTCPMatch struct {
+	Port int `yaml:"port"`
+}
+
+type istioTCPRoute struct {
+	Match []istioTCPMatch `yaml:"match"`
+	Route []istioRoute    `yaml:"route"`
+}
+
+type istioVirtualServiceSpec struct {
+	Gateways []string         `yaml:"gateways"`
+	Hosts    []string         `yaml:"hosts"`
+	HTTP     []istioHTTPRoute `yaml:"http,omitempty"`
+	TCP      []istioTCPRoute  `yaml:"tcp,omitempty"`
+}
+
+func (r *istioRouting) Name() string {
+	return RoutingIstio
+}
+
+func (r *istioRouting) Objects(app *AppData) (objects []RoutingObject, err error) {
+	httpRoutes, tcpRoutes := app.HTTPRoutes(), app.TCPRoutes()
+	if len(httpRoutes) == 0 && len(tcpRoutes) == 0 {
+		return
+	}
+	spec := istioVirtualServiceSpec{
+		Gateways: r.gateways,
+		Hosts:    hostnames(append(append([]*RouteData{}, httpRoutes...), tcpRoutes...)),
+	}
+	for _, route := range httpRoutes {
+		match := istioHTTPMatch{Authority: &istioStringMatch{Exact: route.Hostname()}}
+		if route.Path != "" {
+			match.URI = &istioStringMatch{Prefix: route.Path}
+		}
+		spec.HTTP = append(spec.HTTP, istioHTTPRoute{
+			Match: []istioHTTPMatch{match},
+			Route: []istioRoute{{
+				Destination: istioDestination{Host: app.Name, Port: istioPort{Number: route.ServicePort}},
+			}},
+		})
+	}
+	for _, route := range tcpRoutes {
+		spec.TCP = append(spec.TCP, istioTCPRoute{
+			Match: []istioTCPMatch{{Port: route.Port}},
+			Route: []istioRoute{{
+				Destination: istioDestination{Host: app.Name, Port: istioPort{Number: route.ServicePort}},
+			}},
+		})
+	}
+	objects = append(objects, RoutingObject{
+		APIVersion: "networking.istio.io/v1beta1",
+		Kind:       "VirtualService",
+		Name:       app.Name,
+		Spec:       spec,
+	})
+	return
+}
+
+type ingressRouting struct {
+	class     string
+	tlsSecret string
+}
+
+type ingressServicePort struct {
+	Number int `yaml:"number"`
+}
+
+type ingressService struct {
+	Name string             `yaml:"name"`
+	Port ingressServicePort `yaml:"port"`
+}
+
+type ingressBackend struct {
+	Service ingressService `yaml:"service"`
+}
+
+type ingressPath struct {
+	Path     string         `yaml:"path"`
+	PathType string         `yaml:"pathType"`
+	Backend  ingressBackend `yaml:"backend"`
+}
+
+type ingressHTTP struct {
+	Paths []ingressPath `yaml:"paths"`
+}
+
+type ingressRule struct {
+	Host string      `yaml:"host"`
+	HTTP ingressHTTP `yaml:"http"`
+}
+
+type ingressTLS struct {
+	Hosts      []string `yaml:"hosts"`
+	SecretName string   `yaml:"secretName"`
+}
+
+type ingressSpec struct {
+	IngressClassName string        `yaml:"ingressClassName,omitempty"`
+	TLS              []ingressTLS  `yaml:"tls,omitempty"`
+	Rules            []ingressRule `yaml:"rules"`
+}
+
+func (r *ingressRouting) Name() string {
+	return RoutingIngress
+}
+
+func (r *ingressRouting) Objects(app *AppData) (objects []RoutingObject, err error) {
+	if tcpRoutes := app.TCPRoutes(); len(tcpRoutes) > 0 {
+		err = fmt.Errorf("Ingress routing does not support the TCP route '%s'", tcpRoutes[0])
+		return
+	}
+	httpRoutes := app.HTTPRoutes()
+	if len(httpRoutes) == 0 {
+		return
+	}
+	hosts := hostnames(httpRoutes)
+	spec := ingressSpec{IngressClassName: r.class}
+	for _, host := range hosts {
+		rule := ingressRule{Host: host}
+		for _, route := range httpRoutes {
+			if route.Hostname() != host {
+				continue
+			}
+			path := route.Path
+			if path == "" {
+				path = "/"
+			}
+			rule.HTTP.Paths = append(rule.HTTP.Paths, ingressPath{
+				Path:     path,
+				PathType: "Prefix",
+				Backend: ingressBackend{
+					Service: ingressService{
+						Name: app.Name,
+						Port: ingressServicePort{Number: route.ServicePort},
+					},
+				},
+			})
+		}
+		spec.Rules = append(spec.Rules, rule)
+	}
+	if r.tlsSecret != "" {
+		spec.TLS = []ingressTLS{{Hosts: hosts, SecretName: r.tlsSecret}}
+	}
+	objects = append(objects, RoutingObject{
+		APIVersion: "networking.k8s.io/v1",
+		Kind:       "Ingress",
+		Name:       app.Name,
+		Spec:       spec,
+	})
+	return
+}
+
+type gatewayRouting struct {
+	gateways []string
+}
+
+type gatewayParentRef struct {
+	Name      string `yaml:"name"`
+	Namespace string `yaml:"namespace,omitempty"`
+	Port      int    `yaml:"port,omitempty"`
+}
+
+type gatewayBackendRef struct {
+	Name string `yaml:"name"`
+	Port int    `yaml:"port"`
+}
+
+type gatewayPathMatch struct {
+	Type  string `yaml:"type"`
+	Value string `yaml:"value"`
+}
+
+type gatewayMatch struct {
+	Path gatewayPathMatch `yaml:"path"`
+}
+
+type gatewayRule struct {
+	Matches     []gatewayMatch      `yaml:"matches,omitempty"`
+	BackendRefs []gatewayBackendRef `yaml:"backendRefs"`
+}
+
+type httpRouteSpec struct {
+	ParentRefs []gatewayParentRef `yaml:"parentRefs"`
+	Hostnames  []string           `yaml:"hostnames"`
+	Rules      []gatewayRule      `yaml:"rules"`
+}
+
+type tcpRouteSpec struct {
+	ParentRefs []gatewayParentRef `yaml:"parentRefs"`
+	Rules      []gatewayRule      `yaml:"rules"`
+}
+
+func (r *gatewayRouting) Name() string {
+	return RoutingGateway
+}
+
+// parentRefs returns the references to the gateways, with the port of the
+// listener for the TCP routes
+func (r *gatewayRouting) parentRefs(port int) (refs []gatewayParentRef) {
+	for _, gateway := range r.gateways {
+		ref := gatewayParentRef{Name: gateway, Port: port}
+		if i := strings.Index(gateway, "/"); i >= 0 {
+			ref.Namespace, ref.Name = gateway[:i], gateway[i+1:]
+		}
+		refs = append(refs, ref)
+	}
+	return
+}
+
+// Objects returns a HTTPRoute for each hostname, the HTTPRoutes match all the
+// paths of their hostnames, and a TCPRoute for each TCP route
+func (r *gatewayRouting) Objects(app *AppData) (objects []RoutingObject, err error) {
+	httpRoutes := app.HTTPRoutes()
+	hosts := hostnames(httpRoutes)
+	for i, host := range hosts {
+		spec := httpRouteSpec{
+			ParentRefs: r.parentRefs(0),
+			Hostnames:  []string{host},
+		}
+		for _, route := range httpRoutes {
+			if route.Hostname() != host {
+				continue
+			}
+			path := route.Path
+			if path == "" {
+				path = "/"
+			}
+			spec.Rules = append(spec.Rules, gatewayRule{
+				Matches:     []gatewayMatch{{Path: gatewayPathMatch{Type: "PathPrefix", Value: path}}},
+				BackendRefs: []gatewayBackendRef{{Name: app.Name, Port: route.ServicePort}},
+			})
+		}
+		name := app.Name
+		if len(hosts) > 1 {
+			name = fmt.Sprintf("%s-%d", app.Name, i)
+		}
+		objects = append(objects, RoutingObject{
+			APIVersion: "gateway.networking.k8s.io/v1",
+			Kind:       "HTTPRoute",
+			Name:       name,
+			Spec:       spec,
+		})
+	}
+	for _, route := range app.TCPRoutes() {
+		objects = append(objects, RoutingObject{
+			APIVersion: "gateway.networking.k8s.io/v1alpha2",
+			Kind:       "TCPRoute",
+			Name:       fmt.Sprintf("%s-tcp-%d", app.Name, route.Port),
+			Spec: tcpRouteSpec{
+				ParentRefs: r.parentRefs(route.Port),
+				Rules: []gatewayRule{{
+					BackendRefs: []gatewayBackendRef{{Name: app.Name, Port: route.ServicePort}},
+				}},
+			},
+		})
+	}
+	return
+}
@@ -0,0 +1,172 @@
+package manifests
+
+const (
+	// Nodes (taint and label dedicated) of the instances without scheduling
+	DefaultDedicatedNodes = "katee-default"
+)
+
+type TolerationData struct {
+	Key      string `yaml:"key,omitempty"`
+	Operator string `yaml:"operator,omitempty"`
+	Value    string `yaml:"value,omitempty"`
+	Effect   string `yaml:"effect,omitempty"`
+}
+
+// AffinityData is a node affinity expression, required without weight and
+// preferred with weight
+type AffinityData struct {
+	Key      string
+	Operator string
+	Values   []string
+	Weight   int
+}
+
+// PodAffinityData is a pod (anti) affinity term with the pods with the
+// labels (by default the instances of the application) in the topology
+// domain, required without weight and preferred with weight
+type PodAffinityData struct {
+	TopologyKey string            `yaml:"topologyKey"`
+	Labels      map[string]string `yaml:"matchLabels,omitempty"`
+	Weight      int               `yaml:"weight,omitempty"`
+}
+
+type TopologySpreadData struct {
+	MaxSkew           int    `yaml:"maxSkew"`
+	TopologyKey       string `yaml:"topologyKey"`
+	WhenUnsatisfiable string `yaml:"whenUnsatisfiable"`
+}
+
+// SchedulingData defines where the instances of the applications run
+type SchedulingData struct {
+	Tolerations       []TolerationData
+	NodeSelector      map[string]string
+	Affinity          []AffinityData
+	PodAffinity       []PodAffinityData
+	PodAntiAffinity   []PodAffinityData
+	TopologySpread    []TopologySpreadData
+	PriorityClassName string
+}
+
+// NewDefaultSchedulingData returns the scheduling used when it is not
+// configured, the toleration and node affinity of the dedicated nodes
+func NewDefaultSchedulingData() *SchedulingData {
+	return &SchedulingData{
+		Tolerations: []TolerationData{
+			{Key: "dedicated", Operator: "Equal", Value: DefaultDedicatedNodes, Effect: "NoSchedule"},
+		},
+		NodeSelector: make(map[string]string),
+		Affinity: []AffinityData{
+			{Key: "dedicated", Operator: "In", Values: []string{DefaultDedicatedNodes}},
+		},
+	}
+}
+
+// NodeAffinity returns the node affinity in the Kubernetes format, nil
+// without affinity expressions
+func (s *SchedulingData) NodeAffinity() map[string]interface{} {
+	var required, preferred []interface{}
+	for _, a := range s.Affinity {
+		expression := map[string]interface{}{
+			"key":      a.Key,
+			"operator": a.Operator,
+		}
+		if len(a.Values) > 0 {
+			expression["values"] = a.Values
+		}
+		if a.Weight > 0 {
+			preferred = append(preferred, map[string]interface{}{
+				"weight": a.Weight,
+				"preference": map[string]interface{}{
+					"matchExpressions": []interface{}{expression},
+				},
+			})
+		} else {
+			required = append(required, expression)
+		}
+	}
+	if len(required) == 0 && len(preferred) == 0 {
+		return nil
+	}
+	affinity := make(map[string]interface{})
+	if len(required) > 0 {
+		affinity["requiredDuringSchedulingIgnoredDuringExecution"] = map[string]interface{}{
+			"nodeSelectorTerms": []interface{}{
+				map[string]interface{}{"matchExpressions": required},
+			},
+		}
+	}
+	if len(preferred) > 0 {
+		affinity["preferredDuringSchedulingIgnoredDuringExecution"] = preferred
+	}
+	return affinity
+}
+
+// Affinities returns the node, pod and pod anti affinity of the pods with
+// the label app in the Kubernetes format, nil without affinity
+func (s *SchedulingData) Affinities(app string) map[string]interface{} {
+	affinity := make(map[string]interface{})
+	if node := s.NodeAffinity(); node != nil {
+		affinity["nodeAffinity"] = node
+	}
+	if pod := podAffinity(s.PodAffinity, app); pod != nil {
+		affinity["podAffinity"] = pod
+	}
+	if pod := podAffinity(s.PodAntiAffinity, app); pod != nil {
+		affinity["podAntiAffinity"] = pod
+	}
+	if len(affinity) == 0 {
+		return nil
+	}
+	return affinity
+}
+
+func podAffinity(terms []PodAffinityData, app string) map[string]interface{} {
+	var required, preferred []interface{}
+	for _, p := range terms {
+		labels := p.Labels
+		if len(labels) == 0 {
+			labels = map[string]string{"app": app}
+		}
+		term := map[string]interface{}{
+			"topologyKey": p.TopologyKey,
+			"labelSelector": map[string]interface{}{
+				"matchLabels": labels,
+			},
+		}
+		if p.Weight > 0 {
+			preferred = append(preferred, map[string]interface{}{
+				"weight":          p.Weight,
+				"podAffinityTerm": term,
+			})
+		} else {
+			required = append(required, term)
+		}
+	}
+	if len(required) == 0 && len(preferred) == 0 {
+		return nil
+	}
+	affinity := make(map[string]interface{})
+	if len(required) > 0 {
+		affinity["requiredDuringSchedulingIgnoredDuringExecution"] = required
+	}
+	if len(preferred) > 0 {
+		affinity["preferredDuringSchedulingIgnoredDuringExecution"] = preferred
+	}
+	return affinity
+}
+
+// TopologySpreadConstraints returns the topology spread constraints in the
+// Kubernetes format for the pods with the label app
+func (s *SchedulingData) TopologySpreadConstraints(app string) (constraints []interface{}) {
+	for _, t := range s.TopologySpread {
+		constraints = append(constraints, map[string]interface{}{
+			"maxSkew":           t.MaxSkew,
+			"topologyKey":       t.TopologyKey,
+			"whenUnsatisfiable": t.WhenUnsatisfiable,
+			"labelSelector": map[string]interface{}{
+				"matchLabels": map[string]string{"app": app},
+			},
+		})
+	}
+	return
+}
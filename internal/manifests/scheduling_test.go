@@ -0,0 +1,58 @@
+package manifests
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDefaultScheduling(t *testing.T) {
+	s := NewDefaultSchedulingData()
+	expected := []TolerationData{{Key: "dedicated", Operator: "Equal", Value: "katee-default", Effect: "NoSchedule"}}
+	if !reflect.DeepEqual(s.Tolerations, expected) {
+		t.Errorf("Expected tolerations %v, got %v", expected, s.Tolerations)
+	}
+	affinity := s.Affinities("webapp")
+	if len(affinity) != 1 || affinity["nodeAffinity"] == nil {
+		t.Fatalf("Expected only the node affinity, got %v", affinity)
+	}
+	required := affinity["nodeAffinity"].(map[string]interface{})["requiredDuringSchedulingIgnoredDuringExecution"]
+	if required == nil {
+		t.Errorf("Expected the required node affinity with the dedicated nodes, got %v", affinity)
+	}
+}
+
+func TestPodAffinity(t *testing.T) {
+	s := &SchedulingData{
+		PodAntiAffinity: []PodAffinityData{
+			{TopologyKey: "kubernetes.io/hostname"},
+			{TopologyKey: "topology.kubernetes.io/zone", Labels: map[string]string{"tier": "db"}, Weight: 10},
+		},
+	}
+	affinity := s.Affinities("webapp")
+	if _, found := affinity["podAffinity"]; found {
+		t.Errorf("Unexpected pod affinity in %v", affinity)
+	}
+	expected := map[string]interface{}{
+		"requiredDuringSchedulingIgnoredDuringExecution": []interface{}{
+			map[string]interface{}{
+				"topologyKey":   "kubernetes.io/hostname",
+				"labelSelector": map[string]interface{}{"matchLabels": map[string]string{"app": "webapp"}},
+			},
+		},
+		"preferredDuringSchedulingIgnoredDuringExecution": []interface{}{
+			map[string]interface{}{
+				"weight": 10,
+				"podAffinityTerm": map[string]interface{}{
+					"topologyKey":   "topology.kubernetes.io/zone",
+					"labelSelector": map[string]interface{}{"matchLabels": map[string]string{"tier": "db"}},
+				},
+			},
+		},
+	}
+	if !reflect.DeepEqual(affinity["podAntiAffinity"], expected) {
+		t.Errorf("Expected pod anti affinity %v, got %v", expected, affinity["podAntiAffinity"])
+	}
+	if affinity := (&SchedulingData{}).Affinities("webapp"); affinity != nil {
+		t.Errorf("Expected no affinity, got %v", affinity)
+	}
+}
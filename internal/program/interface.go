@@ -5,9 +5,16 @@ type ProgramCLI interface {
 	LoadConfig() error
 	GetJsonConfig() ([]byte, error)
 	GenerateManifest() error
+	ExportManifestTemplates(dir string, force bool) error
 	PushApp() error
-	BuildAppImage() error
-	StageAppImage() error
-	UploadAppImage() error
+	BuildAppImage(force bool, platforms []string, baseImagePolicy, buildpacksPolicy string) error
+	StageAppImage(force bool, platforms []string, baseImagePolicy, buildpacksPolicy string) error
+	UploadAppImage(force bool, platforms []string) error
 	RunAppImage(env map[string]string) error
+	InspectAppImage(format string) error
+	BuildStackImage(image, base string, buildpacks []string, push bool) error
+	VendorBuildpacks(dir string, sources []string, force bool) error
+	ListBuildpacks(dir, format string) error
+	PushBuildpacks(dir, image string) error
+	PullBuildpacks(dir, image string) error
 }
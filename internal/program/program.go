@@ -64,8 +64,27 @@ func (p *Program) GenerateManifest() (err error) {
 	return nil
 }
 
-func (p *Program) BuildAppImage() (err error) {
+func (p *Program) ExportManifestTemplates(dir string, force bool) (err error) {
 	log := p.Configurator.Logger()
+	action, err := kubefoundry.New(p.Config, log)
+	if err != nil {
+		return err
+	}
+	return action.ExportTemplates(dir, force)
+}
+
+func (p *Program) BuildAppImage(force bool, platforms []string, baseImagePolicy, buildpacksPolicy string) (err error) {
+	log := p.Configurator.Logger()
+	p.Config.DockerStaging.Force = p.Config.DockerStaging.Force || force
+	if len(platforms) > 0 {
+		p.Config.DockerStaging.Platforms = platforms
+	}
+	if baseImagePolicy != "" {
+		p.Config.DockerStaging.BaseImagePolicy = baseImagePolicy
+	}
+	if buildpacksPolicy != "" {
+		p.Config.DockerStaging.Buildpacks.Policy = buildpacksPolicy
+	}
 	if action, err := kubefoundry.New(p.Config, log); err == nil {
 		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
 		defer stop()
@@ -74,8 +93,18 @@ func (p *Program) BuildAppImage() (err error) {
 	return nil
 }
 
-func (p *Program) StageAppImage() (err error) {
+func (p *Program) StageAppImage(force bool, platforms []string, baseImagePolicy, buildpacksPolicy string) (err error) {
 	log := p.Configurator.Logger()
+	p.Config.DockerStaging.Force = p.Config.DockerStaging.Force || force
+	if len(platforms) > 0 {
+		p.Config.DockerStaging.Platforms = platforms
+	}
+	if baseImagePolicy != "" {
+		p.Config.DockerStaging.BaseImagePolicy = baseImagePolicy
+	}
+	if buildpacksPolicy != "" {
+		p.Config.DockerStaging.Buildpacks.Policy = buildpacksPolicy
+	}
 	if action, err := kubefoundry.New(p.Config, log); err == nil {
 		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
 		defer stop()
@@ -84,8 +113,12 @@ func (p *Program) StageAppImage() (err error) {
 	return nil
 }
 
-func (p *Program) UploadAppImage() (err error) {
+func (p *Program) UploadAppImage(force bool, platforms []string) (err error) {
 	log := p.Configurator.Logger()
+	p.Config.DockerStaging.Force = p.Config.DockerStaging.Force || force
+	if len(platforms) > 0 {
+		p.Config.DockerStaging.Platforms = platforms
+	}
 	if action, err := kubefoundry.New(p.Config, log); err == nil {
 		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
 		defer stop()
@@ -105,6 +138,100 @@ func (p *Program) RunAppImage(env map[string]string) (err error) {
 	return nil
 }
 
+func (p *Program) InspectAppImage(format string) (err error) {
+	log := p.Configurator.Logger()
+	action, err := kubefoundry.New(p.Config, log)
+	if err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	return action.InspectApp(ctx, format)
+}
+
+func (p *Program) BuildStackImage(image, base string, buildpacks []string, push bool) (err error) {
+	log := p.Configurator.Logger()
+	if image != "" {
+		p.Config.DockerStaging.Stack.Image = image
+	}
+	if base != "" {
+		p.Config.DockerStaging.Stack.BaseImage = base
+	}
+	if len(buildpacks) > 0 {
+		p.Config.DockerStaging.Stack.Buildpacks = buildpacks
+	}
+	action, err := kubefoundry.New(p.Config, log)
+	if err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	return action.BuildStack(ctx, push)
+}
+
+func (p *Program) VendorBuildpacks(dir string, sources []string, force bool) (err error) {
+	log := p.Configurator.Logger()
+	if dir != "" {
+		p.Config.DockerStaging.Buildpacks.Dir = dir
+	}
+	if len(sources) > 0 {
+		p.Config.DockerStaging.Buildpacks.Sources = sources
+	}
+	action, err := kubefoundry.New(p.Config, log)
+	if err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	return action.VendorBuildpacks(ctx, force)
+}
+
+func (p *Program) ListBuildpacks(dir, format string) (err error) {
+	log := p.Configurator.Logger()
+	if dir != "" {
+		p.Config.DockerStaging.Buildpacks.Dir = dir
+	}
+	action, err := kubefoundry.New(p.Config, log)
+	if err != nil {
+		return err
+	}
+	return action.ListBuildpacks(format)
+}
+
+func (p *Program) PushBuildpacks(dir, image string) (err error) {
+	log := p.Configurator.Logger()
+	if dir != "" {
+		p.Config.DockerStaging.Buildpacks.Dir = dir
+	}
+	if image != "" {
+		p.Config.DockerStaging.Buildpacks.Artifact = image
+	}
+	action, err := kubefoundry.New(p.Config, log)
+	if err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	return action.PushBuildpacks(ctx)
+}
+
+func (p *Program) PullBuildpacks(dir, image string) (err error) {
+	log := p.Configurator.Logger()
+	if dir != "" {
+		p.Config.DockerStaging.Buildpacks.Dir = dir
+	}
+	if image != "" {
+		p.Config.DockerStaging.Buildpacks.Artifact = image
+	}
+	action, err := kubefoundry.New(p.Config, log)
+	if err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	return action.PullBuildpacks(ctx)
+}
+
 func (p *Program) PushApp() (err error) {
 	log := p.Configurator.Logger()
 	if action, err := kubefoundry.New(p.Config, log); err == nil {
@@ -0,0 +1,316 @@
+package sbom
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+var (
+	// name (version) in the specs section of a Gemfile.lock
+	gemSpecRegexp = regexp.MustCompile(`^    ([^\s(]+) \(([^)]+)\)$`)
+	// name==version in requirements.txt
+	requirementRegexp = regexp.MustCompile(`^([A-Za-z0-9][A-Za-z0-9._-]*)(\[[^\]]*\])?\s*==\s*([^\s;#]+)`)
+	// name-version.jar
+	jarNameRegexp = regexp.MustCompile(`^(.+?)-(\d[\w.+-]*)\.[jw]ar$`)
+	// Folders with libraries inside of jar/war files
+	nestedJarDirs = []string{"BOOT-INF/lib/", "WEB-INF/lib/", "lib/"}
+)
+
+// purl returns the package URL (https://github.com/package-url/purl-spec)
+func purl(kind, namespace, name, version string) string {
+	escape := func(s string) string {
+		return strings.ReplaceAll(url.PathEscape(s), "@", "%40")
+	}
+	p := "pkg:" + kind + "/"
+	if namespace != "" {
+		p = p + escape(namespace) + "/"
+	}
+	p = p + escape(name)
+	if version != "" {
+		p = p + "@" + escape(version)
+	}
+	return p
+}
+
+// parseBuildpackConfig reads deps/<index>/config.yml written by the
+// buildpacks during the supply phase
+func (b *BOM) parseBuildpackConfig(name string, content []byte) error {
+	config := struct {
+		Name    string                 `yaml:"name"`
+		Version string                 `yaml:"version"`
+		Config  map[string]interface{} `yaml:"config"`
+	}{}
+	if err := yaml.Unmarshal(content, &config); err != nil {
+		return err
+	}
+	if config.Name == "" {
+		return nil
+	}
+	version := config.Version
+	if version == "" {
+		if v, ok := config.Config["version"]; ok {
+			version = fmt.Sprintf("%v", v)
+		}
+	}
+	b.Add(Component{
+		Type:    "framework",
+		Name:    config.Name,
+		Version: version,
+		PURL:    purl("generic", "buildpack", config.Name, version),
+	}, path.Dir(name))
+	return nil
+}
+
+// parseStagingInfo reads the buildpack detected in staging
+func (b *BOM) parseStagingInfo(name string, content []byte) error {
+	info := struct {
+		DetectedBuildpack string `json:"detected_buildpack"`
+	}{}
+	if err := json.Unmarshal(content, &info); err != nil || info.DetectedBuildpack == "" {
+		// Not relevant if it cannot be parsed
+		return nil
+	}
+	b.Add(Component{
+		Type: "framework",
+		Name: info.DetectedBuildpack,
+		PURL: purl("generic", "buildpack", info.DetectedBuildpack, ""),
+	}, name)
+	return nil
+}
+
+// parsePackageLock supports lockfileVersion 1 (dependencies) and 2/3
+// (packages)
+func (b *BOM) parsePackageLock(name string, content []byte) error {
+	type dependency struct {
+		Version      string                `json:"version"`
+		Dev          bool                  `json:"dev"`
+		Dependencies map[string]dependency `json:"dependencies"`
+	}
+	lock := struct {
+		Packages     map[string]dependency `json:"packages"`
+		Dependencies map[string]dependency `json:"dependencies"`
+	}{}
+	if err := json.Unmarshal(content, &lock); err != nil {
+		return err
+	}
+	add := func(pkg, version string) {
+		namespace := ""
+		if strings.HasPrefix(pkg, "@") {
+			if parts := strings.SplitN(pkg, "/", 2); len(parts) == 2 {
+				namespace, pkg = parts[0], parts[1]
+			}
+		}
+		b.Add(Component{
+			Type:    "library",
+			Group:   namespace,
+			Name:    pkg,
+			Version: version,
+			PURL:    purl("npm", namespace, pkg, version),
+		}, name)
+	}
+	if len(lock.Packages) > 0 {
+		for key, dep := range lock.Packages {
+			i := strings.LastIndex(key, "node_modules/")
+			if i < 0 || dep.Dev {
+				continue
+			}
+			add(key[i+len("node_modules/"):], dep.Version)
+		}
+		return nil
+	}
+	var walk func(map[string]dependency)
+	walk = func(deps map[string]dependency) {
+		for pkg, dep := range deps {
+			if !dep.Dev {
+				add(pkg, dep.Version)
+				walk(dep.Dependencies)
+			}
+		}
+	}
+	walk(lock.Dependencies)
+	return nil
+}
+
+// parseGemfileLock reads the gems from the specs of GEM sections
+func (b *BOM) parseGemfileLock(name string, content []byte) error {
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	section := ""
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line != "" && !strings.HasPrefix(line, " ") {
+			section = line
+			continue
+		}
+		if section != "GEM" {
+			continue
+		}
+		if m := gemSpecRegexp.FindStringSubmatch(line); m != nil {
+			version := strings.SplitN(m[2], "-", 2)[0]
+			b.Add(Component{
+				Type:    "library",
+				Name:    m[1],
+				Version: version,
+				PURL:    purl("gem", "", m[1], version),
+			}, name)
+		}
+	}
+	return scanner.Err()
+}
+
+// parseRequirements only takes pinned requirements (name==version)
+func (b *BOM) parseRequirements(name string, content []byte) error {
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if m := requirementRegexp.FindStringSubmatch(line); m != nil {
+			pkg := strings.ToLower(strings.ReplaceAll(m[1], "_", "-"))
+			b.Add(Component{
+				Type:    "library",
+				Name:    pkg,
+				Version: m[3],
+				PURL:    purl("pypi", "", pkg, m[3]),
+			}, name)
+		}
+	}
+	return scanner.Err()
+}
+
+// parseJar gets the maven coordinates of a jar (pom.properties) or the
+// manifest, including the libraries packaged inside (fat jars and wars)
+func (b *BOM) parseJar(name string, content []byte) error {
+	return b.parseJarContent(name, content, true)
+}
+
+func (b *BOM) parseJarContent(name string, content []byte, nested bool) error {
+	zr, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		// Not a valid zip, nothing to report
+		return nil
+	}
+	found := false
+	manifest := map[string]string{}
+	for _, f := range zr.File {
+		switch {
+		case strings.HasPrefix(f.Name, "META-INF/maven/") && strings.HasSuffix(f.Name, "/pom.properties"):
+			props, err := readZipFile(f)
+			if err != nil {
+				continue
+			}
+			p := parseProperties(props)
+			if p["artifactId"] != "" {
+				found = true
+				b.Add(Component{
+					Type:    "library",
+					Group:   p["groupId"],
+					Name:    p["artifactId"],
+					Version: p["version"],
+					PURL:    purl("maven", p["groupId"], p["artifactId"], p["version"]),
+				}, name)
+			}
+		case f.Name == "META-INF/MANIFEST.MF":
+			if data, err := readZipFile(f); err == nil {
+				manifest = parseManifest(data)
+			}
+		case nested && strings.HasSuffix(f.Name, ".jar") && hasPrefix(f.Name, nestedJarDirs):
+			if data, err := readZipFile(f); err == nil {
+				b.parseJarContent(name+"!/"+f.Name, data, false)
+			}
+		}
+	}
+	if found {
+		return nil
+	}
+	// Without maven metadata use the manifest or the file name
+	title := manifest["Implementation-Title"]
+	version := manifest["Implementation-Version"]
+	if title == "" {
+		title = strings.SplitN(manifest["Bundle-SymbolicName"], ";", 2)[0]
+		version = manifest["Bundle-Version"]
+	}
+	if title == "" || version == "" {
+		base := path.Base(name)
+		if m := jarNameRegexp.FindStringSubmatch(base); m != nil {
+			title, version = m[1], m[2]
+		} else if title == "" {
+			title = strings.TrimSuffix(strings.TrimSuffix(base, ".jar"), ".war")
+		}
+	}
+	b.Add(Component{
+		Type:    "library",
+		Name:    title,
+		Version: version,
+		PURL:    purl("maven", "", title, version),
+	}, name)
+	return nil
+}
+
+func hasPrefix(s string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(s, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func readZipFile(f *zip.File) ([]byte, error) {
+	if f.UncompressedSize64 > MaxFileSize {
+		return nil, fmt.Errorf("File too big")
+	}
+	r, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	buf := &bytes.Buffer{}
+	_, err = buf.ReadFrom(r)
+	return buf.Bytes(), err
+}
+
+// parseProperties reads a java properties file (key=value)
+func parseProperties(content []byte) map[string]string {
+	props := map[string]string{}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if kv := strings.SplitN(line, "=", 2); len(kv) == 2 {
+			props[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+		}
+	}
+	return props
+}
+
+// parseManifest reads the main section of a jar MANIFEST.MF
+func parseManifest(content []byte) map[string]string {
+	attrs := map[string]string{}
+	last := ""
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			// End of main section
+			break
+		}
+		if strings.HasPrefix(line, " ") && last != "" {
+			attrs[last] = attrs[last] + line[1:]
+			continue
+		}
+		if kv := strings.SplitN(line, ":", 2); len(kv) == 2 {
+			last = strings.TrimSpace(kv[0])
+			attrs[last] = strings.TrimSpace(kv[1])
+		}
+	}
+	return attrs
+}
@@ -0,0 +1,223 @@
+package sbom
+
+import (
+	"archive/zip"
+	"bytes"
+	"sort"
+	"testing"
+	"time"
+)
+
+// testJar returns a jar with the files, name: content
+func testJar(t *testing.T, files map[string][]byte) []byte {
+	names := []string{}
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	for _, name := range names {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = w.Write(files[name]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestParsers(t *testing.T) {
+	library := testJar(t, map[string][]byte{
+		"META-INF/maven/org.example/library/pom.properties": []byte("# Generated\ngroupId=org.example\nartifactId=library\nversion=1.2.3\n"),
+	})
+	manifestOnly := testJar(t, map[string][]byte{
+		"META-INF/MANIFEST.MF": []byte("Manifest-Version: 1.0\r\nImplementation-Title: plain\r\nImplementation-Version: 0.9\r\n\r\n"),
+	})
+	cases := []struct {
+		name    string
+		file    string
+		content []byte
+		purls   []string
+	}{
+		{
+			name: "package-lock v1",
+			file: "app/package-lock.json",
+			content: []byte(`{
+  "lockfileVersion": 1,
+  "dependencies": {
+    "express": {"version": "4.18.2", "dependencies": {"debug": {"version": "2.6.9"}}},
+    "@babel/core": {"version": "7.22.0"},
+    "mocha": {"version": "10.2.0", "dev": true, "dependencies": {"chai": {"version": "4.3.7"}}}
+  }
+}`),
+			purls: []string{
+				"pkg:npm/%40babel/core@7.22.0",
+				"pkg:npm/debug@2.6.9",
+				"pkg:npm/express@4.18.2",
+			},
+		},
+		{
+			name: "package-lock v2",
+			file: "app/package-lock.json",
+			content: []byte(`{
+  "lockfileVersion": 2,
+  "packages": {
+    "": {"name": "app", "version": "1.0.0"},
+    "node_modules/express": {"version": "4.18.2"},
+    "node_modules/express/node_modules/debug": {"version": "2.6.9"},
+    "node_modules/@types/node": {"version": "20.1.0"},
+    "node_modules/mocha": {"version": "10.2.0", "dev": true}
+  },
+  "dependencies": {
+    "ignored": {"version": "1.0.0"}
+  }
+}`),
+			purls: []string{
+				"pkg:npm/%40types/node@20.1.0",
+				"pkg:npm/debug@2.6.9",
+				"pkg:npm/express@4.18.2",
+			},
+		},
+		{
+			name: "package-lock v3",
+			file: "app/package-lock.json",
+			content: []byte(`{
+  "lockfileVersion": 3,
+  "packages": {
+    "": {"name": "app"},
+    "node_modules/@scope/pkg": {"version": "0.1.0"},
+    "node_modules/jest": {"version": "29.0.0", "dev": true}
+  }
+}`),
+			purls: []string{"pkg:npm/%40scope/pkg@0.1.0"},
+		},
+		{
+			name: "Gemfile.lock",
+			file: "app/Gemfile.lock",
+			content: []byte(`GIT
+  remote: https://github.com/example/private.git
+  specs:
+    private (0.1.0)
+
+GEM
+  remote: https://rubygems.org/
+  specs:
+    nokogiri (1.15.2-x86_64-linux)
+      racc (~> 1.4)
+    rack (2.2.7)
+    racc (1.7.1)
+
+PLATFORMS
+  x86_64-linux
+
+DEPENDENCIES
+  nokogiri
+  rack (~> 2.2)
+
+BUNDLED WITH
+   2.4.10
+`),
+			purls: []string{
+				"pkg:gem/nokogiri@1.15.2",
+				"pkg:gem/racc@1.7.1",
+				"pkg:gem/rack@2.2.7",
+			},
+		},
+		{
+			name: "requirements.txt",
+			file: "app/requirements.txt",
+			content: []byte(`# Pinned
+Flask==2.3.2
+requests[security] == 2.31.0 ; python_version > "3.7"
+typing_extensions==4.7.1  # comment
+gunicorn>=20.0
+-r other.txt
+numpy
+`),
+			purls: []string{
+				"pkg:pypi/flask@2.3.2",
+				"pkg:pypi/requests@2.31.0",
+				"pkg:pypi/typing-extensions@4.7.1",
+			},
+		},
+		{
+			name: "jar with pom.properties",
+			file: "app/lib/library.jar",
+			content: testJar(t, map[string][]byte{
+				"META-INF/maven/org.example/app/pom.properties": []byte("groupId=org.example\nartifactId=app\nversion=2.0.0\n"),
+			}),
+			purls: []string{"pkg:maven/org.example/app@2.0.0"},
+		},
+		{
+			name: "fat jar with BOOT-INF/lib",
+			file: "app/app.jar",
+			content: testJar(t, map[string][]byte{
+				"META-INF/maven/org.example/app/pom.properties": []byte("groupId=org.example\nartifactId=app\nversion=2.0.0\n"),
+				"BOOT-INF/lib/library-1.2.3.jar":                library,
+				"BOOT-INF/lib/plain.jar":                        manifestOnly,
+				"BOOT-INF/lib/commons-io-2.11.0.jar":            testJar(t, map[string][]byte{"README": []byte("no metadata")}),
+				"BOOT-INF/classes/ignored.jar":                  library,
+			}),
+			purls: []string{
+				"pkg:maven/commons-io@2.11.0",
+				"pkg:maven/org.example/app@2.0.0",
+				"pkg:maven/org.example/library@1.2.3",
+				"pkg:maven/plain@0.9",
+			},
+		},
+		{
+			name:    "dependencies in node_modules",
+			file:    "app/node_modules/express/package-lock.json",
+			content: []byte(`{"packages": {"node_modules/debug": {"version": "2.6.9"}}}`),
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			b := New("registry.example.com/app", "1.0", time.Unix(0, 0))
+			parser := b.parser(c.file)
+			if parser == nil && len(c.purls) > 0 {
+				t.Fatalf("No parser for '%s'", c.file)
+			} else if parser != nil {
+				if err := parser(c.file, c.content); err != nil {
+					t.Fatal(err)
+				}
+			}
+			purls := []string{}
+			for _, component := range b.Components {
+				purls = append(purls, component.PURL)
+			}
+			sort.Strings(purls)
+			if len(purls) != len(c.purls) {
+				t.Fatalf("Expected components %v, got %v", c.purls, purls)
+			}
+			for i := range purls {
+				if purls[i] != c.purls[i] {
+					t.Errorf("Expected component %s, got %s", c.purls[i], purls[i])
+				}
+			}
+		})
+	}
+}
+
+func TestSetSerial(t *testing.T) {
+	b := New("app", "1.0", time.Unix(0, 0))
+	random := b.SerialNumber
+	b.SetSerial("sha256:0123")
+	serial := b.SerialNumber
+	if serial == random || len(serial) != len(random) {
+		t.Fatalf("Expected a serial derived from the seed, got '%s'", serial)
+	}
+	b.SetSerial("sha256:0123")
+	if b.SerialNumber != serial {
+		t.Errorf("Expected the same serial '%s' with the same seed, got '%s'", serial, b.SerialNumber)
+	}
+	if serial[23] != '5' {
+		t.Errorf("Expected a version 5 UUID, got '%s'", serial)
+	}
+}
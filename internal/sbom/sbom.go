@@ -0,0 +1,181 @@
+package sbom
+
+import (
+	"archive/tar"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// Media type of CycloneDX JSON documents (used for registry artifacts)
+	MediaType   = "application/vnd.cyclonedx+json"
+	BOMFormat   = "CycloneDX"
+	SpecVersion = "1.4"
+	ToolName    = "kubefoundry"
+	// Property with the path where the component was found
+	LocationProperty = "kubefoundry:location"
+	// Maximum size of the files parsed (lock files, jars)
+	MaxFileSize = 256 * 1024 * 1024
+)
+
+// Property is a name/value pair of a component
+type Property struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Component is a library, framework or application in the BOM
+type Component struct {
+	Type       string     `json:"type"`
+	Group      string     `json:"group,omitempty"`
+	Name       string     `json:"name"`
+	Version    string     `json:"version,omitempty"`
+	PURL       string     `json:"purl,omitempty"`
+	Properties []Property `json:"properties,omitempty"`
+}
+
+type Tool struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+type Metadata struct {
+	Timestamp string     `json:"timestamp"`
+	Tools     []Tool     `json:"tools"`
+	Component *Component `json:"component,omitempty"`
+}
+
+// BOM is a CycloneDX software bill of materials
+type BOM struct {
+	BOMFormat    string      `json:"bomFormat"`
+	SpecVersion  string      `json:"specVersion"`
+	SerialNumber string      `json:"serialNumber"`
+	Version      int         `json:"version"`
+	Metadata     Metadata    `json:"metadata"`
+	Components   []Component `json:"components"`
+	seen         map[string]bool
+}
+
+// New creates an empty BOM for the container image
+func New(image, version string, timestamp time.Time) *BOM {
+	return &BOM{
+		BOMFormat:    BOMFormat,
+		SpecVersion:  SpecVersion,
+		SerialNumber: "urn:uuid:" + uuid(),
+		Version:      1,
+		Metadata: Metadata{
+			Timestamp: timestamp.UTC().Format(time.RFC3339),
+			Tools:     []Tool{{Name: ToolName}},
+			Component: &Component{
+				Type:    "container",
+				Name:    image,
+				Version: version,
+			},
+		},
+		Components: []Component{},
+		seen:       make(map[string]bool),
+	}
+}
+
+// SetSerial replaces the random serial number by a (version 5) UUID derived
+// from the seed, the same seed always generates the same serial number
+func (b *BOM) SetSerial(seed string) {
+	u := sha1.Sum([]byte(seed))
+	u[6] = (u[6] & 0x0f) | 0x50
+	u[8] = (u[8] & 0x3f) | 0x80
+	b.SerialNumber = fmt.Sprintf("urn:uuid:%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:16])
+}
+
+// Add appends a component found in location, duplicates are skipped
+func (b *BOM) Add(c Component, location string) {
+	key := strings.Join([]string{c.Type, c.Group, c.Name, c.Version, location}, "|")
+	if b.seen[key] {
+		return
+	}
+	b.seen[key] = true
+	if location != "" {
+		c.Properties = append(c.Properties, Property{Name: LocationProperty, Value: location})
+	}
+	b.Components = append(b.Components, c)
+}
+
+// JSON returns the BOM document with the components sorted
+func (b *BOM) JSON() ([]byte, error) {
+	sort.SliceStable(b.Components, func(i, j int) bool {
+		if b.Components[i].PURL != b.Components[j].PURL {
+			return b.Components[i].PURL < b.Components[j].PURL
+		}
+		return b.Components[i].Name < b.Components[j].Name
+	})
+	return json.MarshalIndent(b, "", "  ")
+}
+
+// Scan reads a tar stream with the home folder of the staged application
+// (as returned by Docker when copying from a container) and adds the
+// components found: buildpack dependencies and language lock files
+func (b *BOM) Scan(r io.Reader) error {
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("Unable to read staged files: %s", err.Error())
+		}
+		if header.Typeflag != tar.TypeReg || header.Size > MaxFileSize {
+			continue
+		}
+		name := path.Clean(header.Name)
+		parser := b.parser(name)
+		if parser == nil {
+			continue
+		}
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			return fmt.Errorf("Unable to read staged file '%s': %s", name, err.Error())
+		}
+		if err = parser(name, content); err != nil {
+			return fmt.Errorf("Unable to parse '%s': %s", name, err.Error())
+		}
+	}
+}
+
+// parser returns the function to parse the file, nil if it is not relevant
+func (b *BOM) parser(name string) func(string, []byte) error {
+	base := path.Base(name)
+	dir := path.Dir(name)
+	switch {
+	case strings.Contains(name, "/node_modules/"):
+		// Dependencies are taken from the top lock file
+		return nil
+	case base == "config.yml" && path.Base(path.Dir(dir)) == "deps":
+		return b.parseBuildpackConfig
+	case base == "staging_info.yml":
+		return b.parseStagingInfo
+	case base == "package-lock.json":
+		return b.parsePackageLock
+	case base == "Gemfile.lock":
+		return b.parseGemfileLock
+	case base == "requirements.txt":
+		return b.parseRequirements
+	case strings.HasSuffix(base, ".jar") || strings.HasSuffix(base, ".war"):
+		return b.parseJar
+	}
+	return nil
+}
+
+// uuid returns a random (version 4) UUID
+func uuid() string {
+	u := make([]byte, 16)
+	rand.Read(u)
+	u[6] = (u[6] & 0x0f) | 0x40
+	u[8] = (u[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:])
+}
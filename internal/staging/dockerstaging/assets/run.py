@@ -310,16 +310,23 @@ class CFRunner(object):
         return json.dumps(vcap_app)
 
     def get_default_instance_ports(self, name, app_manifest):
-        try:
-            port = int(os.getenv('APP_PORT', '8080'))
-        except:
-            port = 8080
-        instance_ports = [
-            {
-                "external": 80,
+        # APP_PORTS defines all the listening ports, the first one is
+        # exposed in the port 80 and the rest in their own port
+        instance_ports = []
+        for i, p in enumerate(os.getenv('APP_PORTS', os.getenv('APP_PORT', '8080')).split(',')):
+            try:
+                port = int(p)
+            except:
+                continue
+            instance_ports.append({
+                "external": 80 if i == 0 else port,
                 "internal": port,
-            },
-        ]
+            })
+        if not instance_ports:
+            instance_ports.append({
+                "external": 80,
+                "internal": 8080,
+            })
         return json.dumps(instance_ports)
 
     def get_default_running_vars(self, name, app_manifest):
@@ -365,6 +372,21 @@ class CFRunner(object):
             result = af.read()
         return result
 
+    def get_instance_index(self, annotations, labels):
+        # Only the pods of a StatefulSet have a stable index, in the name
+        # <statefulset>-<index>. The pods of a Deployment are all index 0
+        workload = annotations.get("kubefoundry/workload", "statefulset")
+        if workload != "statefulset":
+            self.logger.debug("Instances of a %s do not have a stable index, setting to 0" % workload)
+            return '0'
+        if "apps.kubernetes.io/pod-index" in labels:
+            return labels["apps.kubernetes.io/pod-index"]
+        try:
+            return labels["statefulset.kubernetes.io/pod-name"].rsplit("-", 1)[1]
+        except Exception as e:
+            self.logger.error("Unable calculate instance index: %s. Setting to 0" % e)
+            return '0'
+
     def get_k8s_running_vars(self, name, app_manifest, k8s_cf_env):
         # Exported in k8s_cf_env using downward api
         # https://kubernetes.io/docs/tasks/inject-data-application/downward-api-volume-expose-pod-information/
@@ -389,11 +411,7 @@ class CFRunner(object):
         except Exception as e:
             self.logger.error("Unable to read Downward-api file: %s. Generating random UUID" % e)
             uid = str(uuid.uuid5(uuid.NAMESPACE_DNS, name))
-        try:
-            instance_index = labels["statefulset.kubernetes.io/pod-name"].rsplit("-", 1)[1]
-        except Exception as e:
-            self.logger.error("Unable calculate instance index: %s. Setting to 0" % e)
-            instance_index = '0'
+        instance_index = self.get_instance_index(annotations, labels)
         env_vars = {}
         default_running_vars = dict(
             PORT = os.getenv('APP_PORT', '8080'),
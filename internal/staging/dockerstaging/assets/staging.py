@@ -40,6 +40,9 @@ from subprocess import (Popen, PIPE)
 from collections import OrderedDict
 from urllib.parse import urlsplit
 
+# Folder (in buildpacks dir) with the buildpacks preinstalled in stack images
+STACK_BUILDPACKS = 'stack'
+
 # Ordered list of buildpacks and urls for automatic detection, pointing to master branch
 BUILDPACKS= OrderedDict(
     staticfile_buildpack='https://github.com/cloudfoundry/staticfile-buildpack.git',
@@ -248,15 +251,17 @@ class Git(object):
         if rc != 0:
             raise IOError(" ".join(err))
         if tag:
-            checkout = []
+            checkout = [tag]
             _, out, _ = git.tag(["--sort=-refname", "--list", tag])
             if out:
                 checkout = ["tags/%s" % (out[0])]
-            else:
-                raise ValueError("Not found tag/branch: %s" % tag)
             rc, _, err = git.checkout(checkout)
             if rc != 0:
-                raise ValueError("Checkout repository %s" % " ".join(err))
+                # Not a tag, neither a branch or a commit
+                raise ValueError("Not found tag/branch/commit %s: %s" % (tag, " ".join(err)))
+            rc, _, err = git.submodule(["update", "--init", "--recursive"])
+            if rc != 0:
+                raise ValueError("Updating submodules %s" % " ".join(err))
         if bare:
             git.clean()
 
@@ -418,8 +423,13 @@ class Buildpack(object):
         self.depsdir = depsdir
         self.runner = Runner(dir, env, logger)
 
+    def phase(self, name):
+        # Marker parsed by kubefoundry to report the phase of staging errors
+        print("#--- PHS! %s #%s %s" % (name, self.index, self.name), flush=True)
+
     def detect(self, echo=True, env={}):
         self.logger.debug("Buildpack #%s running detect step ... " % (self.index))
+        self.phase("detect")
         cmd = [os.path.join(self.dir, "bin", "detect"), self.appdir]
         try:
             rc, _, _ = self.runner.run(cmd, env, False, echo, "[STG.det] ")
@@ -431,6 +441,7 @@ class Buildpack(object):
 
     def compile(self, echo=True, env={}):
         self.logger.debug("Buildpack #%s running compile step ... " % (self.index))
+        self.phase("compile")
         try:
             os.makedirs(self.depsdir, mode=0o755, exist_ok=True)
         except OSError as e:
@@ -446,6 +457,7 @@ class Buildpack(object):
 
     def supply(self, echo=True, env={}):
         self.logger.debug("Buildpack #%s running supply step ... " % (self.index))
+        self.phase("supply")
         try:
             path = os.path.join(self.depsdir, str(self.index))
             os.makedirs(path, mode=0o755, exist_ok=True)
@@ -462,6 +474,7 @@ class Buildpack(object):
 
     def finalize(self, echo=True, env={}):
         self.logger.debug("Buildpack #%s running finalize step ... " % (self.index))
+        self.phase("finalize")
         cmd = [os.path.join(self.dir, "bin", "finalize"), self.appdir, self.cachedir, self.depsdir, str(self.index)]
         rc = 1
         try:
@@ -712,6 +725,22 @@ class CFStaging(object):
         self.logger.debug("Buildpack '%s' dowloaded to '%s'" % (name, path))
         return True
 
+    def stack_buildpack_path(self, name):
+        # Preinstalled buildpacks are in <buildpacksdir>/stack/<url>
+        url = self.Buildpacks.get(name, name)
+        return os.path.join(self.buildpacksdir, STACK_BUILDPACKS, re.sub(r'[^A-Za-z0-9._-]', '_', url))
+
+    def preinstall_buildpacks(self, buildpacks=[], force=False):
+        # Download the buildpacks to build a stack image, all by default
+        if not buildpacks:
+            buildpacks = list(self.Buildpacks.keys())
+        for name in buildpacks:
+            path = self.stack_buildpack_path(name)
+            self.download_buildpack(name, path, force)
+            self.logger.info("Buildpack '%s' preinstalled in '%s'" % (name, path))
+        # Preinstalled buildpacks are never cleaned
+        self.cleaning_paths = []
+
     def link_context(self):
         try:
             self.logger.debug("Deleting context directory: %s" % (self.contextdir))
@@ -828,12 +857,14 @@ class CFStaging(object):
             autodetect = False
             env = {}
             startcmd = ""
+            # Buildpacks resolved (pinned) by kubefoundry replace the ones of the manifest
             manifest_buildpacks = extra_buildpacks
-            try:
-                manifest_buildpacks = manifest_buildpacks + app['buildpacks']
-            except KeyError:
-                msg = "Application name '%s' without buildpacks defined in the manifest" % app_name
-                self.logger.info(msg)
+            if not manifest_buildpacks:
+                try:
+                    manifest_buildpacks = app['buildpacks']
+                except KeyError:
+                    msg = "Application name '%s' without buildpacks defined in the manifest" % app_name
+                    self.logger.info(msg)
             if not manifest_buildpacks:
                 msg = "No buildpacks defined for application '%s', trying to autodetect a suitable one ...." % app_name
                 self.logger.info(msg)
@@ -869,9 +900,8 @@ class CFStaging(object):
                             self._recursive_overwrite(os.path.join(temp_path, base_path), self.appdir)
                             shutil.rmtree(temp_path)
                 elif os.path.isdir(appdir):
-                    #dst = os.path.join(self.appdir, app['path'])
-                    # TODO: ignore also .cfignore
-                    #shutil.copytree(appdir, self.appdir, ignore=shutil.ignore_patterns('.git'))
+                    # Files matching .cfignore (and .kfignore) patterns were already
+                    # excluded by kubefoundry when the context was packaged
                     self._recursive_overwrite(appdir, self.appdir)
                 else:
                     raise ValueError("application path not found: %s" % appbits)
@@ -884,9 +914,13 @@ class CFStaging(object):
             buildpacks = []
             try:
                 for i in range(len(manifest_buildpacks)):
-                    path = os.path.join(self.buildpacksdir, app_name, str(i))
                     bp_name = manifest_buildpacks[i]
-                    self.download_buildpack(bp_name, path, force_download)
+                    path = self.stack_buildpack_path(bp_name)
+                    if os.path.isdir(path) and not force_download:
+                        self.logger.debug("Using buildpack '%s' preinstalled in '%s'" % (bp_name, path))
+                    else:
+                        path = os.path.join(self.buildpacksdir, app_name, str(i))
+                        self.download_buildpack(bp_name, path, force_download)
                     buildpacks.append(Buildpack(bp_name, i, path, appdir, self.depsdir, self.cachedir, env, self.logger))
                 app_settings[app_name] = (autodetect, buildpacks, app)
             except Exception:
@@ -949,6 +983,10 @@ class CFStaging(object):
                     self.logger.error("Cannot apply buildpack '%s' to application '%s'" % (buildpack.name, app))
                     raise
                 index += 1
+            if autodetect and final_buildpack == "-":
+                msg = "No buildpack detected for application '%s'" % (app)
+                self.logger.error(msg)
+                raise ValueError(msg)
             self.logger.info("Application '%s' successfully staged/compiled" % (app))
             if startcommand:
                 # Write staging_info.yml with the first startcommand of the list
@@ -1027,7 +1065,7 @@ def main():
     parser = argparse.ArgumentParser(formatter_class=argparse.RawTextHelpFormatter, description=__doc__, epilog=epilog)
     parser.add_argument('-d', '--debug', action='store_true', default=False, help='Enable debug mode')
     parser.add_argument('-f', '--force', action='store_true', default=False, help='Force downloading buildpacks data')
-    parser.add_argument('-b', '--buildpack', action='append', default=[], help='Buildpacks urls for staging the application')
+    parser.add_argument('-b', '--buildpack', action='append', default=[], help='Buildpacks for staging the application instead of the ones of the manifest')
     parser.add_argument('--builddir', default='/buildpacks' , help='Working directory for buildpacks')  
     parser.add_argument('--buildcache', default="/var/local/buildpacks/cache", help='Buildpacks cache directory')
     parser.add_argument('-m', '--manifest', default="manifest.yml", help='CloudFoundry application manifest file')
@@ -1038,7 +1076,8 @@ def main():
     parser.add_argument('--healthcheck', default="/healthcheck.sh", help='File to write the healthchecks')
     parser.add_argument('--link-context', action='store_true', default=False, help='Delete context folder and create a symlink to home/app')
     parser.add_argument('--clean', action='count', default=0, help='Delete the downloaded buildpacks, twice deletes also cache')
-    parser.add_argument('application', default='.', type=str, help='Application zip file or directory')  
+    parser.add_argument('--preinstall', action='store_true', default=False, help='Only download the buildpacks (all if none) to build a stack image')
+    parser.add_argument('application', nargs='?', default='.', type=str, help='Application zip file or directory')  
     args = parser.parse_args()
     if args.debug:
         logger.setLevel(logging.DEBUG)
@@ -1052,6 +1091,9 @@ def main():
         cfmanifest = os.environ.get("CF_MANIFEST", args.manifest)
         cfmanifest_vars = os.environ.get("CF_VARS", args.manifest_vars)
         stage = CFStaging(args.home, args.builddir, args.buildcache, args.appcontext, args.healthcheck, logger)
+        if args.preinstall:
+            stage.preinstall_buildpacks(args.buildpack, args.force)
+            sys.exit(0)
         stage.run(args.application, cfmanifest, args.app, cfmanifest_vars, args.buildpack, args.force)
         if args.clean > 0:
             stage.cleanup_buildpacks((args.clean > 1))
@@ -0,0 +1,83 @@
+package dockerstaging
+
+import (
+	"context"
+	"fmt"
+
+	registry "kubefoundry/pkg/registry"
+)
+
+const (
+	// Use the digest of the lock file, or the current one if not locked
+	BaseImagePolicyPinned = "pinned"
+	// Use the current digest of the tag and update the lock file
+	BaseImagePolicyLatest = "latest"
+)
+
+// resolveBaseImage pins the base image to a digest according to the policy
+// and the lock file. If the digest cannot be found (eg. offline and not
+// locked) the tag is used. The lock file is only written with the latest
+// policy when the digest changed.
+func (ac *DockerAppContainerImage) resolveBaseImage(ctx context.Context) (err error) {
+	image := ac.config.ContainerBaseImage
+	ac.baseImage = image
+	ac.baseDigest = ""
+	ref, err := registry.ParseReference(image)
+	if err != nil {
+		err = fmt.Errorf("Invalid base image '%s': %s", image, err.Error())
+		ac.log.Error(err)
+		return
+	}
+	if ref.Digest != "" {
+		// Already pinned in the configuration
+		ac.baseDigest = ref.Digest
+		return
+	}
+	digest, locked := ac.lock.BaseImage(image)
+	latest := ac.config.BaseImagePolicy == BaseImagePolicyLatest
+	if !locked || latest {
+		current, errD := ac.baseImageDigest(ctx, ref)
+		switch {
+		case errD != nil && !locked:
+			ac.log.Warnf("Unable to pin base image '%s' to a digest, using the tag: %s", image, errD.Error())
+			return
+		case errD != nil:
+			ac.log.Warnf("Unable to get the digest of base image '%s', using the locked one: %s", image, errD.Error())
+		case !latest:
+			// The lock file is only written by the latest policy
+			ac.log.Warnf("Base image '%s' is not locked, using %s. Build with the policy '%s' to lock it", image, current, BaseImagePolicyLatest)
+			digest = current
+		case current != digest:
+			if locked {
+				ac.log.Infof("Base image '%s' updated from %s to %s", image, digest, current)
+			}
+			digest = current
+			ac.lock.SetBaseImage(image, digest)
+			if err = ac.lock.Save(); err != nil {
+				ac.log.Error(err)
+				return
+			}
+		}
+	}
+	ac.baseDigest = digest
+	ac.baseImage = ref.Name() + "@" + digest
+	ac.log.Infof("Using base image '%s' pinned to %s", image, digest)
+	return
+}
+
+// baseImageDigest returns the current digest of the base image in the
+// registry or the digest of the local image
+func (ac *DockerAppContainerImage) baseImageDigest(ctx context.Context, ref registry.Reference) (digest string, err error) {
+	desc, err := ac.registry.HeadManifest(ctx, ref)
+	if err == nil {
+		return desc.Digest.String(), nil
+	}
+	if image, _, errI := ac.cli.ImageInspectWithRaw(ctx, ref.String()); errI == nil {
+		for _, repoDigest := range image.RepoDigests {
+			if local, errR := registry.ParseReference(repoDigest); errR == nil && local.Name() == ref.Name() {
+				return local.Digest, nil
+			}
+		}
+	}
+	return
+}
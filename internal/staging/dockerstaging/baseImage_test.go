@@ -0,0 +1,93 @@
+package dockerstaging
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	lockfile "kubefoundry/internal/lockfile"
+	log "kubefoundry/internal/log"
+	registry "kubefoundry/pkg/registry"
+)
+
+func TestResolveBaseImage(t *testing.T) {
+	lockedDigest := "sha256:" + strings.Repeat("a", 64)
+	currentDigest := "sha256:" + strings.Repeat("b", 64)
+	cases := []struct {
+		name    string
+		policy  string
+		locked  string
+		offline bool
+		digest  string
+		written string
+	}{
+		{name: "pinned not locked", policy: BaseImagePolicyPinned, digest: currentDigest},
+		{name: "pinned locked", policy: BaseImagePolicyPinned, locked: lockedDigest, digest: lockedDigest},
+		{name: "pinned offline", policy: BaseImagePolicyPinned, offline: true},
+		{name: "latest not locked", policy: BaseImagePolicyLatest, digest: currentDigest, written: currentDigest},
+		{name: "latest updated", policy: BaseImagePolicyLatest, locked: lockedDigest, digest: currentDigest, written: currentDigest},
+		{name: "latest unchanged", policy: BaseImagePolicyLatest, locked: currentDigest, digest: currentDigest},
+		{name: "latest offline", policy: BaseImagePolicyLatest, locked: lockedDigest, offline: true, digest: lockedDigest},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if c.offline || r.URL.Path != "/v2/stacks/base/manifests/latest" {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				w.Header().Set("Docker-Content-Digest", currentDigest)
+			}))
+			defer server.Close()
+			image := strings.TrimPrefix(server.URL, "http://") + "/stacks/base:latest"
+			path := lockfile.Path(t.TempDir())
+			// Without the header to find out if the lock file was written
+			content := "version: 1\n"
+			if c.locked != "" {
+				content += "baseImages:\n  " + image + ": " + c.locked + "\n"
+			}
+			if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			lock, err := lockfile.Load(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ac := &DockerAppContainerImage{
+				DockerStaging: &DockerStaging{
+					cli:      testDockerClient(t, nil),
+					registry: registry.New(nil),
+					config:   &DockerStagingConfig{ContainerBaseImage: image, BaseImagePolicy: c.policy},
+					log:      log.StandardLogger(),
+					lock:     lock,
+				},
+			}
+			if err = ac.resolveBaseImage(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if ac.baseDigest != c.digest {
+				t.Errorf("Expected digest '%s', got '%s'", c.digest, ac.baseDigest)
+			}
+			expected := image
+			if c.digest != "" {
+				expected = strings.TrimSuffix(image, ":latest") + "@" + c.digest
+			}
+			if ac.baseImage != expected {
+				t.Errorf("Expected base image '%s', got '%s'", expected, ac.baseImage)
+			}
+			written, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			switch {
+			case c.written == "" && string(written) != content:
+				t.Errorf("Expected the lock file not to be written, got:\n%s", written)
+			case c.written != "" && !strings.Contains(string(written), image+": "+c.written):
+				t.Errorf("Expected the base image locked to %s, got:\n%s", c.written, written)
+			}
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package dockerstaging
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	tar "kubefoundry/pkg/tar"
+
+	units "github.com/docker/go-units"
+)
+
+const (
+	// Amount of bytes between progress reports of the build context
+	DockerContextProgressStep = 8 * 1024 * 1024
+)
+
+// contextProgress counts the bytes of the build context while they are
+// being streamed to Docker and reports the progress
+type contextProgress struct {
+	size   int64
+	next   int64
+	report func(int64)
+	mu     sync.Mutex
+}
+
+func newContextProgress(report func(int64)) *contextProgress {
+	return &contextProgress{
+		next:   DockerContextProgressStep,
+		report: report,
+	}
+}
+
+func (p *contextProgress) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.size += int64(len(b))
+	if p.size >= p.next {
+		p.next = p.size + DockerContextProgressStep
+		if p.report != nil {
+			p.report(p.size)
+		}
+	}
+	return len(b), nil
+}
+
+func (p *contextProgress) Size() int64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.size
+}
+
+// streamContext creates the Docker build context (a tar file) in background.
+// The tar is generated on the fly and it is available in the returned
+// reader, errors packaging the context are returned when reading it. The
+// caller has to close the reader to stop the packaging process, the context
+// stops it when it is cancelled.
+func (ac *DockerAppContainerImage) streamContext(ctx context.Context, appbits os.FileInfo) *io.PipeReader {
+	reader, writer := io.Pipe()
+	go func() {
+		progress := newContextProgress(func(size int64) {
+			msg := fmt.Sprintf("Sending build context to Docker daemon %s", units.HumanSize(float64(size)))
+			ac.log.Debug(msg)
+			ac.print(ac.output, true, msg, "\033[1;36m")
+		})
+		t := tar.NewTar(".", ac.log, writer, progress)
+		if ac.config.Reproducible {
+			t.SetReproducible(ac.contextData.SourceDate)
+			ac.log.Debugf("Reproducible build context with SOURCE_DATE_EPOCH=%s", ac.contextData.SourceDateEpoch())
+		}
+		err := ac.packContext(ctx, t, appbits)
+		if errc := t.Close(); err == nil && errc != nil {
+			err = fmt.Errorf("Unable to finish build context for '%s': %s", ac.name, errc.Error())
+			ac.log.Error(err)
+		}
+		if err == nil {
+			ac.print(ac.output, true, "", "")
+			ac.log.Infof("Build context for '%s' sent to Docker: %s", ac.name, units.HumanSize(float64(progress.Size())))
+		}
+		writer.CloseWithError(err)
+	}()
+	return reader
+}
+
+// contextDigest walks the build context without sending it anywhere and
+// returns the digest of its content: names, modes, sizes, links and the
+// content of the files, without dates nor owners
+func (ac *DockerAppContainerImage) contextDigest(ctx context.Context, appbits os.FileInfo) (digest string, err error) {
+	h := sha256.New()
+	t := tar.NewTar(".", ac.log, ioutil.Discard)
+	t.SetDigest(h)
+	err = ac.packContext(ctx, t, appbits)
+	if errc := t.Close(); err == nil && errc != nil {
+		err = errc
+	}
+	if err != nil {
+		err = fmt.Errorf("Unable to compute the digest of the build context for '%s': %s", ac.name, err.Error())
+		ac.log.Error(err)
+		return
+	}
+	digest = fmt.Sprintf("sha256:%x", h.Sum(nil))
+	ac.log.Infof("Build context digest %s", digest)
+	return
+}
+
+// packContext adds all the resources needed to build the image to the tar
+func (ac *DockerAppContainerImage) packContext(ctx context.Context, t *tar.Tar, appbits os.FileInfo) (err error) {
+	appManifest := filepath.Join(ac.contextData.CF.Manifest.Path, ac.contextData.CF.Manifest.Filename)
+	if appbits.IsDir() {
+		// Skip the same files as CF, the manifest is always added later
+		ignore, errI := LoadCFIgnore(ac.appData.Dir)
+		if errI != nil {
+			err = fmt.Errorf("Unable to package application '%s': %s", ac.appData.Dir, errI.Error())
+			ac.log.Error(err)
+			return
+		}
+		if filepath.Clean(ac.contextData.CF.Manifest.Path) == filepath.Clean(ac.appData.Dir) {
+			ignore.Add("/" + ac.contextData.CF.Manifest.Filename)
+		}
+		ac.log.Debugf("Ignoring application files matching: %s", ignore.String())
+		err = t.Add(ctx, ac.appData.Dir, ac.appContainerDir, tar.IgnoreList(ignore))
+	} else {
+		err = t.Add(ctx, ac.appData.Dir, ac.appContainerDir)
+	}
+	if err != nil {
+		err = fmt.Errorf("Unable to package application '%s': %s", ac.appData.Dir, err.Error())
+		ac.log.Error(err)
+		return
+	}
+	// Add the manifest
+	if _, errM := os.Stat(appManifest); errM == nil {
+		if err = t.Add(ctx, appManifest, ac.appContainerDir); err != nil {
+			err = fmt.Errorf("Unable to package manifest '%s': %s", appManifest, err.Error())
+			ac.log.Error(err)
+			return
+		}
+	} else if !appbits.IsDir() {
+		err = fmt.Errorf("Unable to package manifest '%s': %s", appManifest, errM.Error())
+		ac.log.Error(err)
+		return
+	}
+	// Buildpacks vendored, staging.py does not download them
+	if err = ac.packBuildpacks(ctx, t, ac.stagingBuildpacks()); err != nil {
+		return
+	}
+	if err = IterateEmbedStaging(t.AddFile); err != nil {
+		err = fmt.Errorf("Unable to package staging assets: %s", err.Error())
+		ac.log.Error(err)
+	}
+	return
+}
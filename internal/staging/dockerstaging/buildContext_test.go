@@ -0,0 +1,103 @@
+package dockerstaging
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	buildpacks "kubefoundry/internal/buildpacks"
+	log "kubefoundry/internal/log"
+	cfmanifest "kubefoundry/internal/manifests"
+)
+
+// testContextApp returns an application with its bits in a temporary folder
+func testContextApp(t *testing.T, files map[string]string) *DockerAppContainerImage {
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	l := log.StandardLogger()
+	return &DockerAppContainerImage{
+		DockerStaging: &DockerStaging{
+			config:          &DockerStagingConfig{},
+			log:             l,
+			buildpacks:      buildpacks.NewStore(t.TempDir(), l),
+			appContainerDir: DockerContainerAppDir,
+			bpContainerDir:  DockerConatinerBPDir,
+			contextData: &cfmanifest.ContextData{
+				CF: &cfmanifest.CfData{
+					Manifest: &cfmanifest.CfManifest{
+						Path:     dir,
+						Filename: "manifest.yml",
+						Apps:     []cfmanifest.CfApplication{{Name: "app", Buildpacks: []string{"custom"}}},
+					},
+				},
+			},
+		},
+		appData: &cfmanifest.AppData{Name: "app", Dir: dir},
+		name:    "app",
+		output:  ioutil.Discard,
+	}
+}
+
+func TestContextDigest(t *testing.T) {
+	files := map[string]string{"manifest.yml": "applications:\n- name: app\n", "app.py": "print('hello')\n"}
+	ac := testContextApp(t, files)
+	appbits, err := os.Stat(ac.appData.Dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest, err := ac.contextDigest(context.Background(), appbits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Dates do not change the digest
+	old := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err = os.Chtimes(filepath.Join(ac.appData.Dir, "app.py"), old, old); err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := ac.contextDigest(context.Background(), appbits); again != digest {
+		t.Errorf("Expected the same digest %s after changing the dates, got %s", digest, again)
+	}
+	// The content does
+	if err = ioutil.WriteFile(filepath.Join(ac.appData.Dir, "app.py"), []byte("print('bye')\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if changed, _ := ac.contextDigest(context.Background(), appbits); changed == digest {
+		t.Errorf("Expected other digest than %s after changing the content", digest)
+	}
+}
+
+func TestStreamContext(t *testing.T) {
+	ac := testContextApp(t, map[string]string{"manifest.yml": "applications:\n- name: app\n", "app.py": "print('hello')\n"})
+	appbits, err := os.Stat(ac.appData.Dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader := ac.streamContext(context.Background(), appbits)
+	content, err := ioutil.ReadAll(reader)
+	reader.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(content) == 0 {
+		t.Errorf("Empty build context")
+	}
+	// Cancelled before packaging the application
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	reader = ac.streamContext(ctx, appbits)
+	defer reader.Close()
+	if _, err = ioutil.ReadAll(reader); err == nil {
+		t.Errorf("Expected error reading the context of a cancelled build")
+	}
+}
@@ -0,0 +1,141 @@
+package dockerstaging
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+
+	buildpacks "kubefoundry/internal/buildpacks"
+	tar "kubefoundry/pkg/tar"
+)
+
+const (
+	// Use the versions of the lock file, or lock the vendored ones
+	BuildpacksPolicyPinned = "pinned"
+	// Resolve the current versions of the sources and update the lock file
+	BuildpacksPolicyLatest = "latest"
+)
+
+// PushBuildpacks uploads the vendored buildpacks as an OCI artifact
+func (ds *DockerStaging) PushBuildpacks(ctx context.Context, image string) (digest string, err error) {
+	return ds.buildpacks.Push(ctx, ds.registry, image)
+}
+
+// PullBuildpacks downloads the buildpacks of an OCI artifact to the
+// vendored buildpacks folder
+func (ds *DockerStaging) PullBuildpacks(ctx context.Context, image string) (err error) {
+	return ds.buildpacks.Pull(ctx, ds.registry, image)
+}
+
+// pullBuildpacks updates the vendored buildpacks from the artifact defined
+// in the configuration. Without access to the registry the buildpacks
+// already vendored are used.
+func (ac *DockerAppContainerImage) pullBuildpacks(ctx context.Context) (err error) {
+	if ac.config.BuildpacksArtifact == "" {
+		return
+	}
+	if err = ac.buildpacks.Pull(ctx, ac.registry, ac.config.BuildpacksArtifact); err != nil {
+		if bps, errL := ac.buildpacks.List(); errL == nil && len(bps) > 0 {
+			ac.log.Warnf("Unable to update buildpacks from '%s', using the vendored ones: %s", ac.config.BuildpacksArtifact, err.Error())
+			err = nil
+		}
+	}
+	return
+}
+
+// resolveBuildpacks pins the buildpacks of the manifest according to the
+// policy and the lock file. They are passed to staging.py instead of the
+// ones of the manifest. Buildpacks are not pinned when staging.py has to
+// detect them.
+func (ac *DockerAppContainerImage) resolveBuildpacks(ctx context.Context) (err error) {
+	ac.resolvedBuildpacks = nil
+	names := ac.manifestBuildpacks()
+	if len(names) == 0 {
+		ac.log.Infof("Buildpacks of '%s' not defined in the manifest, they will be detected without pinning them", ac.appData.Name)
+		return
+	}
+	latest := ac.config.BuildpacksPolicy == BuildpacksPolicyLatest
+	for _, name := range names {
+		resolved, locked := ac.lock.Buildpack(name)
+		if locked && !latest && ac.lockedSource(name, resolved) {
+			ac.resolvedBuildpacks = append(ac.resolvedBuildpacks, resolved)
+			continue
+		}
+		current, errR := ac.resolver.Resolve(ctx, name, latest)
+		switch {
+		case errR != nil && locked:
+			ac.log.Warnf("Unable to resolve buildpack '%s', using the locked version: %s", name, errR.Error())
+		case errR != nil:
+			ac.log.Warnf("Unable to pin buildpack '%s', staging will use the manifest one: %s", name, errR.Error())
+			resolved = name
+		default:
+			if locked && current != resolved {
+				ac.log.Infof("Buildpack '%s' updated from %s to %s", name, resolved, current)
+			}
+			resolved = current
+			ac.lock.SetBuildpack(name, resolved)
+		}
+		ac.resolvedBuildpacks = append(ac.resolvedBuildpacks, resolved)
+	}
+	if err = ac.lock.Save(); err != nil {
+		ac.log.Error(err)
+		return
+	}
+	ac.log.Infof("Using buildpacks for '%s': %s", ac.appData.Name, strings.Join(ac.resolvedBuildpacks, ", "))
+	return
+}
+
+// lockedSource checks if the locked version comes from the current source
+// of the buildpack, the aliases can change it
+func (ac *DockerAppContainerImage) lockedSource(name, resolved string) bool {
+	source, err := ac.resolver.Source(name)
+	if err != nil {
+		return false
+	}
+	locked, err := buildpacks.ParseSource(resolved)
+	return err == nil && locked.URL == source.URL
+}
+
+// manifestBuildpacks returns the buildpacks of the application in the manifest
+func (ac *DockerAppContainerImage) manifestBuildpacks() (names []string) {
+	if app, err := ac.contextData.CF.Manifest.GetApplication(ac.appData.Name); err == nil {
+		names, _ = app.GetBuildpacks()
+	}
+	return
+}
+
+// stagingBuildpacks returns the buildpacks used by staging.py: the resolved
+// ones, the ones of the manifest or all the known ones to detect them. Stack
+// images are already pinned by digest, their buildpacks are used.
+func (ac *DockerAppContainerImage) stagingBuildpacks() (names []string) {
+	if len(ac.resolvedBuildpacks) > 0 && ac.runBase == "" {
+		return ac.resolvedBuildpacks
+	}
+	if names = ac.manifestBuildpacks(); len(names) == 0 {
+		names = buildpacks.Names()
+	}
+	return
+}
+
+// packBuildpacks adds the vendored buildpacks in the folder where staging.py
+// looks for the preinstalled ones. The buildpacks folder is always added
+// because the Dockerfile copies it.
+func (ac *DockerAppContainerImage) packBuildpacks(ctx context.Context, t *tar.Tar, names []string) (err error) {
+	if err = t.AddDir(ac.bpContainerDir, 0755); err != nil {
+		return
+	}
+	for _, name := range names {
+		path, found := ac.buildpacks.Lookup(name)
+		if !found {
+			ac.log.Debugf("Buildpack '%s' not vendored in '%s', staging will download it", name, ac.buildpacks.Dir)
+			continue
+		}
+		dst := filepath.Join(ac.bpContainerDir, buildpacks.StackDir, buildpacks.Slug(name))
+		if err = t.Add(ctx, path, dst); err != nil {
+			ac.log.Errorf("Unable to package vendored buildpack '%s': %s", name, err.Error())
+			return
+		}
+		ac.log.Debugf("Added vendored buildpack '%s' to the build context", name)
+	}
+	return
+}
@@ -0,0 +1,153 @@
+package dockerstaging
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	buildpacks "kubefoundry/internal/buildpacks"
+	lockfile "kubefoundry/internal/lockfile"
+	log "kubefoundry/internal/log"
+	cfmanifest "kubefoundry/internal/manifests"
+)
+
+// testBuildpackZip writes a zip buildpack in the folder and returns its
+// path and digest
+func testBuildpackZip(t *testing.T, dir, name string) (path, digest string) {
+	path = filepath.Join(dir, name+".zip")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(file)
+	w, err := zw.Create("bin/detect")
+	if err == nil {
+		_, err = w.Write([]byte("#!/bin/sh\necho " + name + "\n"))
+	}
+	if err == nil {
+		err = zw.Close()
+	}
+	if errC := file.Close(); err == nil {
+		err = errC
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path, fmt.Sprintf("sha256:%x", sha256.Sum256(content))
+}
+
+func TestResolveBuildpacks(t *testing.T) {
+	dir := t.TempDir()
+	zipPath, zipDigest := testBuildpackZip(t, dir, "custom")
+	otherPath, otherDigest := testBuildpackZip(t, dir, "other")
+	missing := filepath.Join(dir, "missing.zip")
+	cases := []struct {
+		name     string
+		policy   string
+		alias    string
+		locked   string
+		resolved string
+		lock     string
+	}{
+		{
+			name:     "not locked",
+			policy:   BuildpacksPolicyPinned,
+			alias:    zipPath,
+			resolved: zipPath + "#" + zipDigest,
+			lock:     zipPath + "#" + zipDigest,
+		},
+		{
+			name:     "locked",
+			policy:   BuildpacksPolicyPinned,
+			alias:    zipPath,
+			locked:   zipPath + "#sha256:locked",
+			resolved: zipPath + "#sha256:locked",
+			lock:     zipPath + "#sha256:locked",
+		},
+		{
+			name:     "latest updates the lock",
+			policy:   BuildpacksPolicyLatest,
+			alias:    zipPath,
+			locked:   zipPath + "#sha256:locked",
+			resolved: zipPath + "#" + zipDigest,
+			lock:     zipPath + "#" + zipDigest,
+		},
+		{
+			name:     "alias changed",
+			policy:   BuildpacksPolicyPinned,
+			alias:    otherPath,
+			locked:   zipPath + "#" + zipDigest,
+			resolved: otherPath + "#" + otherDigest,
+			lock:     otherPath + "#" + otherDigest,
+		},
+		{
+			name:     "latest unavailable keeps the lock",
+			policy:   BuildpacksPolicyLatest,
+			alias:    missing,
+			locked:   missing + "#sha256:locked",
+			resolved: missing + "#sha256:locked",
+			lock:     missing + "#sha256:locked",
+		},
+		{
+			name:     "unavailable and not locked",
+			policy:   BuildpacksPolicyPinned,
+			alias:    missing,
+			resolved: "custom",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			l := log.StandardLogger()
+			lockPath := lockfile.Path(t.TempDir())
+			lock, err := lockfile.Load(lockPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.locked != "" {
+				lock.SetBuildpack("custom", c.locked)
+			}
+			resolver, err := buildpacks.NewResolver([]string{"custom=" + c.alias}, buildpacks.NewStore(t.TempDir(), l), l)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ac := &DockerAppContainerImage{
+				DockerStaging: &DockerStaging{
+					config:   &DockerStagingConfig{BuildpacksPolicy: c.policy},
+					log:      l,
+					lock:     lock,
+					resolver: resolver,
+					contextData: &cfmanifest.ContextData{
+						CF: &cfmanifest.CfData{
+							Manifest: &cfmanifest.CfManifest{
+								Apps: []cfmanifest.CfApplication{{Name: "app", Buildpacks: []string{"custom"}}},
+							},
+						},
+					},
+				},
+				appData: &cfmanifest.AppData{Name: "app"},
+			}
+			if err = ac.resolveBuildpacks(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if len(ac.resolvedBuildpacks) != 1 || ac.resolvedBuildpacks[0] != c.resolved {
+				t.Errorf("Expected buildpack %s, got %v", c.resolved, ac.resolvedBuildpacks)
+			}
+			saved, err := lockfile.Load(lockPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if locked, _ := saved.Buildpack("custom"); locked != c.lock {
+				t.Errorf("Expected %s in the lock file, got '%s'", c.lock, locked)
+			}
+		})
+	}
+}
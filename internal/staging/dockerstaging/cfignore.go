@@ -0,0 +1,56 @@
+package dockerstaging
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	glob "kubefoundry/pkg/glob"
+)
+
+var (
+	// Files and folders never uploaded by CloudFoundry when pushing an app,
+	// plus the ignore file of Kubefoundry
+	DefaultCFIgnore = []string{
+		".cfignore",
+		"_darcs",
+		".DS_Store",
+		".git",
+		".gitignore",
+		".hg",
+		"/manifest.yml",
+		".svn",
+		".kfignore",
+	}
+	// Files with ignore patterns in the application folder, in order. The
+	// patterns of .kfignore are only used by Kubefoundry.
+	CFIgnoreFiles = []string{
+		".cfignore",
+		".kfignore",
+	}
+)
+
+// LoadCFIgnore returns the list of patterns to skip when packaging the
+// application folder: CF defaults plus the ones defined in the ignore files
+func LoadCFIgnore(dir string) (*glob.List, error) {
+	ignore, err := glob.NewList(DefaultCFIgnore...)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range CFIgnoreFiles {
+		path := filepath.Join(dir, name)
+		f, err := os.Open(path)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("Unable to read ignore file '%s': %s", path, err.Error())
+		}
+		patterns, err := glob.ReadList(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("Unable to parse ignore file '%s': %s", path, err.Error())
+		}
+		ignore.Append(patterns)
+	}
+	return ignore, nil
+}
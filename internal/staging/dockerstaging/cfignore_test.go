@@ -0,0 +1,42 @@
+package dockerstaging
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadCFIgnore(t *testing.T) {
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, ".cfignore"), []byte("*.log\ntmp/\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, ".kfignore"), []byte("!keep.log\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ignore, err := LoadCFIgnore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		path    string
+		dir     bool
+		ignored bool
+	}{
+		{path: ".cfignore", ignored: true},
+		{path: ".kfignore", ignored: true},
+		{path: ".git", dir: true, ignored: true},
+		{path: "manifest.yml", ignored: true},
+		{path: "config/manifest.yml"},
+		{path: "app.log", ignored: true},
+		{path: "keep.log"},
+		{path: "tmp", dir: true, ignored: true},
+		{path: "tmp"},
+		{path: "app.py"},
+	}
+	for _, c := range cases {
+		if ignored := ignore.Match(c.path, c.dir); ignored != c.ignored {
+			t.Errorf("Expected ignored %v for '%s' (dir %v), got %v", c.ignored, c.path, c.dir, ignored)
+		}
+	}
+}
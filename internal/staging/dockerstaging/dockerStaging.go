@@ -1,9 +1,7 @@
 package dockerstaging
 
 import (
-	"bytes"
 	"context"
-	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -14,11 +12,15 @@ import (
 	"strconv"
 	"strings"
 
+	buildpacks "kubefoundry/internal/buildpacks"
 	config "kubefoundry/internal/config"
+	events "kubefoundry/internal/events"
+	lockfile "kubefoundry/internal/lockfile"
 	log "kubefoundry/internal/log"
 	cfmanifest "kubefoundry/internal/manifests"
 	staging "kubefoundry/internal/staging"
-	tar "kubefoundry/pkg/tar"
+	cosign "kubefoundry/pkg/cosign"
+	registry "kubefoundry/pkg/registry"
 
 	dockertypes "github.com/docker/docker/api/types"
 	dockertypescontainer "github.com/docker/docker/api/types/container"
@@ -46,18 +48,29 @@ const (
 
 type DockerStagingConfig struct {
 	Registry                      string
-	Username                      string
-	Password                      string
 	RemoveBeforeBuild             bool
 	ContainerPersistentMountpoint string
 	ContainerRestartPolicy        string // "unless-stopped", "no"
 	ContainerDynamicPorts         bool
 	ContainerBaseImage            string
 	BPCacheDir                    string
+	Reproducible                  bool
+	Force                         bool
+	SigningKey                    string
+	Attest                        bool
+	SBOM                          string
+	Labels                        []string
+	Platforms                     []ocispec.Platform
+	BaseImagePolicy               string
+	BuildpacksArtifact            string
+	BuildpacksPolicy              string
 }
 
 type DockerStaging struct {
 	cli                 *docker.Client
+	registry            *registry.Client
+	keychain            *registry.Keychain
+	signer              *cosign.Signer
 	config              *DockerStagingConfig
 	appContainerDir     string
 	bpContainerDir      string
@@ -65,18 +78,57 @@ type DockerStaging struct {
 	dockerfile          string
 	log                 log.Logger
 	contextData         *cfmanifest.ContextData
+	lock                *lockfile.LockFile
+	buildpacks          *buildpacks.Store
+	resolver            *buildpacks.Resolver
+	events              events.Sink
 }
 
 func (ds *DockerStaging) New(c *config.Config, l log.Logger) (staging.AppStaging, error) {
 	dockerStgConfig := &DockerStagingConfig{
 		Registry:                      c.Docker.Registry,
-		Username:                      c.Docker.Username,
-		Password:                      c.Docker.Password,
 		RemoveBeforeBuild:             c.DockerStaging.RemoveBeforeBuild,
 		ContainerPersistentMountpoint: DockerConatinerPersistDir,
 		ContainerRestartPolicy:        c.DockerStaging.RestartPolicy,
 		ContainerDynamicPorts:         c.DockerStaging.DynamicPorts,
 		ContainerBaseImage:            c.DockerStaging.BaseImage,
+		Reproducible:                  c.DockerStaging.Reproducible,
+		Force:                         c.DockerStaging.Force,
+		SigningKey:                    c.DockerStaging.SigningKey,
+		Attest:                        c.DockerStaging.Attest,
+		SBOM:                          c.DockerStaging.SBOM,
+		Labels:                        c.DockerStaging.Labels,
+		BaseImagePolicy:               c.DockerStaging.BaseImagePolicy,
+		BPCacheDir:                    c.DockerStaging.Buildpacks.Dir,
+		BuildpacksArtifact:            c.DockerStaging.Buildpacks.Artifact,
+		BuildpacksPolicy:              c.DockerStaging.Buildpacks.Policy,
+	}
+	switch dockerStgConfig.BaseImagePolicy {
+	case "", BaseImagePolicyPinned, BaseImagePolicyLatest:
+	default:
+		err := fmt.Errorf("Unknown base image policy '%s', use '%s' or '%s'", dockerStgConfig.BaseImagePolicy, BaseImagePolicyPinned, BaseImagePolicyLatest)
+		l.Error(err)
+		return nil, err
+	}
+	switch dockerStgConfig.BuildpacksPolicy {
+	case "", BuildpacksPolicyPinned, BuildpacksPolicyLatest:
+	default:
+		err := fmt.Errorf("Unknown buildpacks policy '%s', use '%s' or '%s'", dockerStgConfig.BuildpacksPolicy, BuildpacksPolicyPinned, BuildpacksPolicyLatest)
+		l.Error(err)
+		return nil, err
+	}
+	store := buildpacks.NewStore(dockerStgConfig.BPCacheDir, l)
+	resolver, err := buildpacks.NewResolver(c.DockerStaging.Buildpacks.Aliases, store, l)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range c.DockerStaging.Platforms {
+		platform, err := registry.ParsePlatform(p)
+		if err != nil {
+			l.Error(err)
+			return nil, err
+		}
+		dockerStgConfig.Platforms = append(dockerStgConfig.Platforms, platform)
 	}
 	cli, err := docker.NewClientWithOpts(docker.FromEnv, docker.WithAPIVersionNegotiation())
 	if err != nil {
@@ -85,25 +137,56 @@ func (ds *DockerStaging) New(c *config.Config, l log.Logger) (staging.AppStaging
 		return nil, err
 	}
 	l.Debugf("Connected with Docker server at '%s' running version %s", cli.DaemonHost(), cli.ClientVersion())
+	keychain, err := newKeychain(&c.Docker)
+	if err != nil {
+		err = fmt.Errorf("Unable to load registry credentials: %s", err.Error())
+		l.Error(err)
+		return nil, err
+	}
+	registryClient := registry.New(keychain.Resolve)
+	var signer *cosign.Signer
+	if dockerStgConfig.SigningKey != "" {
+		key, err := cosign.LoadPrivateKey(expandHome(dockerStgConfig.SigningKey), []byte(os.Getenv(cosign.PasswordEnv)))
+		if err != nil {
+			l.Error(err)
+			return nil, err
+		}
+		signer = cosign.NewSigner(key, registryClient)
+	}
 	dc := &DockerStaging{
 		cli:                 cli,
+		keychain:            keychain,
+		registry:            registryClient,
+		signer:              signer,
 		config:              dockerStgConfig,
 		appContainerDir:     DockerContainerAppDir,
 		bpContainerDir:      DockerConatinerBPDir,
 		persistContainerDir: DockerConatinerPersistDir,
 		dockerfile:          DockerContainerDockerFile,
 		log:                 l,
+		buildpacks:          store,
+		resolver:            resolver,
+		events:              events.Discard,
 	}
 	return dc, nil
 }
 
+// SetEvents defines the sink of the progress events
+func (ds *DockerStaging) SetEvents(sink events.Sink) {
+	ds.events = sink
+}
+
 type DockerAppContainerImage struct {
 	*DockerStaging
-	baseImage string
-	appData   *cfmanifest.AppData
-	name      string
-	output    io.Writer
-	tags      []string
+	baseImage          string
+	baseDigest         string
+	runBase            string
+	resolvedBuildpacks []string
+	appData            *cfmanifest.AppData
+	name               string
+	output             io.Writer
+	tags               []string
+	sbom               []byte
 }
 
 func (ds *DockerStaging) Stager(data *cfmanifest.ContextData, output io.Writer) (appPackages []staging.AppPackage, err error) {
@@ -114,6 +197,10 @@ func (ds *DockerStaging) Stager(data *cfmanifest.ContextData, output io.Writer)
 	if data.CF.Manifest == nil {
 		panic("Not initialized context.Data.CF")
 	}
+	if ds.lock, err = lockfile.Load(lockfile.Path(data.Dir)); err != nil {
+		ds.log.Error(err)
+		return
+	}
 	err = fmt.Errorf("")
 	errors := false
 	for _, app := range data.Apps {
@@ -151,6 +238,7 @@ func (ds *DockerStaging) Finish(ctx context.Context, appPackages []staging.AppPa
 func (ds *DockerStaging) NewDockerAppContainerImage(appData *cfmanifest.AppData, output io.Writer) (appPackage *DockerAppContainerImage) {
 	appPackage = &DockerAppContainerImage{
 		DockerStaging: ds,
+		baseImage:     ds.config.ContainerBaseImage,
 		appData:       appData,
 		output:        output,
 		name:          appData.Name,
@@ -159,39 +247,49 @@ func (ds *DockerStaging) NewDockerAppContainerImage(appData *cfmanifest.AppData,
 	return
 }
 
+// Name returns the name of the application, stack images do not have it
+func (ac *DockerAppContainerImage) Name() string {
+	if ac.appData == nil {
+		return ""
+	}
+	return ac.appData.Name
+}
+
 // Pull images
 func (ac *DockerAppContainerImage) Pull(ctx context.Context, baseImage bool) (err error) {
 	image := ac.appData.Image
 	if baseImage {
-		image = ac.config.ContainerBaseImage
-	}
-	ac.log.Infof("Pulling Docker image '%s' ...", image)
-	registryAuth := ""
-	imageRegistry := strings.SplitN(image, "/", 2)
-	if strings.Contains(ac.config.Registry, imageRegistry[0]) {
-		authConfig := dockertypes.AuthConfig{
-			Username:      ac.config.Username,
-			Password:      ac.config.Password,
-			ServerAddress: ac.config.Registry,
-		}
-		authConfigBytes, _ := json.Marshal(authConfig)
-		registryAuth = base64.URLEncoding.EncodeToString(authConfigBytes)
+		image = ac.baseImage
 	}
+	return ac.pull(ctx, image, ac.name, nil)
+}
+
+// pull gets the image for the platform (the one of the Docker server if it
+// is nil) and tags it
+func (ac *DockerAppContainerImage) pull(ctx context.Context, image, tag string, platform *ocispec.Platform) (err error) {
 	pullOpts := dockertypes.ImagePullOptions{
-		RegistryAuth: registryAuth,
-		All:          false,
+		All: false,
 	}
-	if pullResponse, err := ac.cli.ImagePull(ctx, image, pullOpts); err != nil {
-		err = fmt.Errorf("Unable to pull image '%s': %s", image, err.Error())
+	if platform != nil {
+		pullOpts.Platform = registry.PlatformString(*platform)
+		ac.log.Infof("Pulling Docker image '%s' (%s) ...", image, pullOpts.Platform)
+	} else {
+		ac.log.Infof("Pulling Docker image '%s' ...", image)
+	}
+	if pullOpts.RegistryAuth, err = ac.registryAuth(image); err != nil {
+		return
+	}
+	if pullResponse, errp := ac.cli.ImagePull(ctx, image, pullOpts); errp != nil {
+		err = fmt.Errorf("Unable to pull image '%s': %s", image, errp.Error())
 		ac.log.Error(err)
 	} else {
 		defer pullResponse.Close()
-		err = ac.displayJSONMessagesStream(pullResponse, ac.output)
-		if err == nil {
+		_, err = ac.displayJSONMessagesStream(pullResponse, ac.output, nil)
+		if err == nil && tag != "" {
 			// Tag the image
-			err = ac.cli.ImageTag(ctx, image, ac.name)
+			err = ac.cli.ImageTag(ctx, image, tag)
 			if err != nil {
-				err = fmt.Errorf("Unable to tag image '%s' with '%s': %s", image, ac.name, err.Error())
+				err = fmt.Errorf("Unable to tag image '%s' with '%s': %s", image, tag, err.Error())
 				ac.log.Error(err)
 			}
 		}
@@ -207,93 +305,167 @@ func (ac *DockerAppContainerImage) Build(ctx context.Context) (id string, err er
 		ac.log.Error(err)
 		return
 	}
-	// Pull base image
-	err = ac.Pull(ctx, true)
-	if err != nil {
+	if err = ac.resolveBaseImage(ctx); err != nil {
+		return
+	}
+	if err = ac.pullBuildpacks(ctx); err != nil {
+		return
+	}
+	if err = ac.resolveBuildpacks(ctx); err != nil {
 		return
 	}
-	// Create a tar with the app for docker context folder
-	app_bits := ac.appContainerDir
 	if appbits.IsDir() {
 		ac.log.Infof("Packaging application context dir '%s' ...", ac.appData.Dir)
-		app_bits = "."
 	} else {
 		ac.log.Infof("Packaging application context file '%s' ...", ac.appData.Dir)
-		app_bits = filepath.Base(ac.appData.Dir)
 	}
-	tarcontext := bytes.NewBuffer(nil)
-	tar := tar.NewTar(".", ac.log, tarcontext)
-	tar.Add(ctx, ac.appData.Dir, ac.appContainerDir)
-	if !appbits.IsDir() {
-		// Add the manifest
-		appManifest := filepath.Join(ac.contextData.CF.Manifest.Path, ac.contextData.CF.Manifest.Filename)
-		tar.Add(ctx, appManifest, ac.appContainerDir)
-	}
-	// Caching mechanism and way to provide buildpacks directly to the container
-	if ac.config.BPCacheDir != "" {
-		if _, err = os.Stat(ac.config.BPCacheDir); !os.IsNotExist(err) {
-			tar.Add(ctx, ac.config.BPCacheDir, ac.bpContainerDir)
-		} else if err != nil {
-			tar.Close()
-			return
+	if len(ac.config.Platforms) > 0 {
+		id, err = ac.buildPlatforms(ctx, appbits)
+	} else if err = ac.Pull(ctx, true); err != nil {
+		err = ac.stagingFailed(staging.PhasePull, err)
+	} else {
+		// Base image pulled
+		id, err = ac.build(ctx, appbits, nil, ac.name)
+	}
+	if err == nil {
+		err = ac.generateSBOM(ctx)
+	}
+	return
+}
+
+// build runs the staging in Docker for the platform (the one of the Docker
+// server if it is nil) and tags the image with name
+func (ac *DockerAppContainerImage) build(ctx context.Context, appbits os.FileInfo, platform *ocispec.Platform, name string) (id string, err error) {
+	// Docker build options
+	ac.log.Infof("Building Docker container image '%s' (%s:%s) ...", name, ac.appData.Name, ac.appData.Version)
+	baseImageID := ""
+	if base, _, errI := ac.cli.ImageInspectWithRaw(ctx, ac.baseImage); errI == nil {
+		baseImageID = base.ID
+		if base.Config != nil {
+			ac.detectStack(base.Config.Labels)
 		}
 	}
-	err = IterateEmbedStaging(tar.AddFile)
-	tar.Close()
+	buildArgs := ac.buildArgs(appbits)
+	// The digest of the build context is calculated in a walk of the files
+	// before streaming them, to skip the build if there is an image with the
+	// same source
+	digest, err := ac.contextDigest(ctx, appbits)
 	if err != nil {
+		err = ac.stagingFailed(staging.PhaseContext, err)
 		return
 	}
-	// Docker build options
-	buildArgs := make(map[string]*string)
-	for key, value := range ac.contextData.Args {
-		buildArgs[key] = &value
+	hash := ac.sourceHash(digest, baseImageID, buildArgs, platform)
+	if !ac.config.Force {
+		if imageID, found := ac.findImage(ctx, hash, name, platform); found {
+			id = imageID
+			ac.events.Emit(events.Event{
+				Type:    events.ImageBuilt,
+				App:     ac.Name(),
+				Image:   name,
+				Digest:  id,
+				Message: "Image up to date with the source",
+			})
+			return
+		}
 	}
-	ac.log.Infof("Building Docker container image '%s' (%s:%s) ...", ac.name, ac.appData.Name, ac.appData.Version)
-	buildArgs["BASE"] = &ac.config.ContainerBaseImage
-	buildArgs["CONTEXT_DIR"] = &ac.appContainerDir
-	buildArgs["BUILDPACKS_DIR"] = &ac.bpContainerDir
-	buildArgs["APP_BITS"] = &app_bits
-	buildArgs["APP_NAME"] = &ac.appData.Name
-	buildArgs["APP_CREATED"] = &ac.contextData.DateHuman
-	buildArgs["APP_VERSION"] = &ac.appData.Version
-	app_port := strconv.Itoa(ac.appData.Port)
-	buildArgs["APP_PORT"] = &app_port
-	buildArgs["CF_MANIFEST"] = &ac.contextData.CF.Manifest.Filename
-	buildArgs["CF_API"] = &ac.contextData.CF.Api
-	buildArgs["CF_ORG"] = &ac.contextData.CF.Org
-	buildArgs["CF_SPACE"] = &ac.contextData.CF.Space
+	labels, err := ac.labels(ac.created())
+	if err != nil {
+		return
+	}
+	labels[DockerLabelSourceHash] = hash
+	// The build context is streamed to Docker while it is being packaged
+	tarcontext := ac.streamContext(ctx, appbits)
+	defer tarcontext.Close()
 	imageBuildOptions := dockertypes.ImageBuildOptions{
 		Remove:         ac.config.RemoveBeforeBuild,
 		ForceRemove:    true,
 		PullParent:     true,
 		SuppressOutput: false,
-		Dockerfile:     ac.dockerfile,
-		Tags:           ac.tags,
+		Dockerfile:     ac.stagingDockerfile(),
+		Tags:           []string{name},
 		BuildArgs:      buildArgs,
 		Squash:         false,
+		Labels:         labels,
+	}
+	if platform != nil {
+		imageBuildOptions.Platform = registry.PlatformString(*platform)
 	}
-	if buildResponse, err := ac.cli.ImageBuild(ctx, tarcontext, imageBuildOptions); err != nil {
-		err = fmt.Errorf("Unable to run CF staging for '%s': %s", ac.name, err.Error())
+	if buildResponse, errb := ac.cli.ImageBuild(ctx, tarcontext, imageBuildOptions); errb != nil {
+		err = ac.stagingFailed(staging.PhaseContext, fmt.Errorf("Unable to run CF staging for '%s': %s", name, errb.Error()))
 		ac.log.Error(err)
+		tarcontext.CloseWithError(err)
 	} else {
 		defer buildResponse.Body.Close()
-		if err = ac.displayJSONMessagesStream(buildResponse.Body, ac.output); err != nil {
+		if _, err = ac.displayJSONMessagesStream(buildResponse.Body, ac.output, newStagingProgress(ac.appData.Name, ac.events)); err != nil {
+			var stagingErr *staging.StagingError
+			if errors.As(err, &stagingErr) {
+				fmt.Fprint(ac.output, stagingErr.Summary())
+				return id, err
+			}
 			err = fmt.Errorf("Doker CF staging error: %s", err.Error())
 			ac.log.Error(err)
 			return id, err
 		}
 		// Get image details - this will check if image build was successful
-		image, _, err := ac.cli.ImageInspectWithRaw(ctx, ac.name)
+		image, _, err := ac.cli.ImageInspectWithRaw(ctx, name)
 		if err != nil {
 			err = fmt.Errorf("Staging process build not completed: %s", err.Error())
 			ac.log.Error(err)
 			return id, err
 		}
 		id = image.ID
+		ac.events.Emit(events.Event{
+			Type:   events.ImageBuilt,
+			App:    ac.Name(),
+			Image:  name,
+			Digest: id,
+		})
 	}
 	return
 }
 
+// buildArgs returns the arguments for the Dockerfile
+func (ac *DockerAppContainerImage) buildArgs(appbits os.FileInfo) map[string]*string {
+	buildArgs := make(map[string]*string)
+	for key, value := range ac.contextData.Args {
+		value := value
+		buildArgs[key] = &value
+	}
+	// Location of the app in the docker context folder
+	app_bits := "."
+	if !appbits.IsDir() {
+		app_bits = filepath.Base(ac.appData.Dir)
+	}
+	buildArgs["BASE"] = &ac.baseImage
+	if ac.runBase != "" {
+		buildArgs["RUN_BASE"] = &ac.runBase
+	}
+	buildArgs["CONTEXT_DIR"] = &ac.appContainerDir
+	buildArgs["BUILDPACKS_DIR"] = &ac.bpContainerDir
+	if len(ac.resolvedBuildpacks) > 0 && ac.runBase == "" {
+		args := []string{}
+		for _, bp := range ac.resolvedBuildpacks {
+			args = append(args, "-b", bp)
+		}
+		buildpacksArg := strings.Join(args, " ")
+		buildArgs["BUILDPACKS"] = &buildpacksArg
+	}
+	buildArgs["APP_BITS"] = &app_bits
+	buildArgs["APP_NAME"] = &ac.appData.Name
+	created := ac.created()
+	buildArgs["APP_CREATED"] = &created
+	sourceDateEpoch := ac.contextData.SourceDateEpoch()
+	buildArgs["SOURCE_DATE_EPOCH"] = &sourceDateEpoch
+	buildArgs["APP_VERSION"] = &ac.appData.Version
+	app_port := strconv.Itoa(ac.appData.Port)
+	buildArgs["APP_PORT"] = &app_port
+	buildArgs["CF_MANIFEST"] = &ac.contextData.CF.Manifest.Filename
+	buildArgs["CF_API"] = &ac.contextData.CF.Api
+	buildArgs["CF_ORG"] = &ac.contextData.CF.Org
+	buildArgs["CF_SPACE"] = &ac.contextData.CF.Space
+	return buildArgs
+}
+
 func (ac *DockerAppContainerImage) Destroy(ctx context.Context, all bool) (err error) {
 	ac.log.Info("Stopping and cleaning resources for '%s' (%s) ...", ac.name, strconv.FormatBool(all))
 	rmOptions := dockertypes.ContainerRemoveOptions{
@@ -320,6 +492,13 @@ func (ac *DockerAppContainerImage) Destroy(ctx context.Context, all bool) (err e
 			ac.log.Error(err)
 		}
 	}
+	for _, platform := range ac.config.Platforms {
+		name := ac.platformName(platform)
+		if _, errR := ac.cli.ImageRemove(ctx, name, dockertypes.ImageRemoveOptions{Force: true}); errR != nil && !dockererrors.IsNotFound(errR) {
+			err = fmt.Errorf("Unable to remove image '%s': %s", name, errR.Error())
+			ac.log.Error(err)
+		}
+	}
 	return
 }
 
@@ -340,61 +519,133 @@ func (ac *DockerAppContainerImage) Info(ctx context.Context) (info map[string]in
 		info["size"] = image.Size
 		info["architecture"] = image.Architecture
 		info["os"] = image.Os
+		info["digest"] = ""
+		if len(image.RepoDigests) > 0 {
+			info["digest"] = image.RepoDigests[0]
+		}
+		labels := map[string]string{}
+		if image.Config != nil && image.Config.Labels != nil {
+			labels = image.Config.Labels
+		}
+		info["labels"] = labels
+		info["commit"] = labels[DockerLabelRevision]
+		info["git"] = labels[DockerLabelSource]
+		info["date"] = labels[DockerLabelCreated]
+		info["team"] = labels[DockerLabelTeam]
+		info["sourcehash"] = labels[DockerLabelSourceHash]
+		info["baseimage"] = labels[DockerLabelBaseName]
+		info["basedigest"] = labels[DockerLabelBaseDigest]
+		platforms := []string{}
+		for _, platform := range ac.config.Platforms {
+			if _, _, errP := ac.cli.ImageInspectWithRaw(ctx, ac.platformName(platform)); errP == nil {
+				platforms = append(platforms, registry.PlatformString(platform))
+			}
+		}
+		info["platforms"] = platforms
+		if stagingInfo, errS := ac.stagingInfo(ctx, image.ID); errS == nil {
+			info["buildpack"] = stagingInfo.DetectedBuildpack
+			info["startcommand"] = stagingInfo.StartCommand
+		} else {
+			ac.log.Warnf("Unable to get staging information of image '%s': %s", ac.name, errS.Error())
+		}
 	}
 	return
 }
 
 func (ac *DockerAppContainerImage) Push(ctx context.Context) (err error) {
+	if len(ac.config.Platforms) > 0 {
+		return ac.pushPlatforms(ctx)
+	}
+	if !ac.config.Force {
+		if hash, _ := ac.localSourceHash(ctx, ac.name); hash != "" && ac.remoteSourceHash(ctx, ac.appData.Image, nil) == hash {
+			ac.log.Infof("Image '%s' in the registry is up to date with the source (%s), skipping push", ac.appData.Image, hash)
+			ac.tags = append(ac.tags, ac.appData.Image)
+			if err = ac.attachSBOM(ctx); err == nil {
+				err = ac.signImage(ctx, false)
+			}
+			return
+		}
+	}
+	if err = ac.push(ctx, ac.name, ac.appData.Image); err == nil {
+		ac.tags = append(ac.tags, ac.appData.Image)
+		if err = ac.attachSBOM(ctx); err == nil {
+			err = ac.signImage(ctx, true)
+		}
+	}
+	return
+}
+
+// push tags the local image name with image and pushes it to the registry
+func (ac *DockerAppContainerImage) push(ctx context.Context, name, image string) (err error) {
 	// Tag the image
-	ac.log.Infof("Pushing image '%s' to '%s' ...", ac.name, ac.appData.Image)
-	err = ac.cli.ImageTag(ctx, ac.name, ac.appData.Image)
+	ac.log.Infof("Pushing image '%s' to '%s' ...", name, image)
+	err = ac.cli.ImageTag(ctx, name, image)
 	if err != nil {
-		err = fmt.Errorf("Unable to tag image '%s' with '%s': %s", ac.name, ac.appData.Image, err.Error())
+		err = fmt.Errorf("Unable to tag image '%s' with '%s': %s", name, image, err.Error())
 		ac.log.Error(err)
 		return
 	}
 	// Push to the registry
-	registryAuth := ""
-	imageRegistry := strings.SplitN(ac.appData.Image, "/", 2)
-	if strings.Contains(ac.config.Registry, imageRegistry[0]) {
-		authConfig := dockertypes.AuthConfig{
-			Username:      ac.config.Username,
-			Password:      ac.config.Password,
-			ServerAddress: ac.config.Registry,
-		}
-		authConfigBytes, _ := json.Marshal(authConfig)
-		registryAuth = base64.URLEncoding.EncodeToString(authConfigBytes)
+	registryAuth, err := ac.registryAuth(image)
+	if err != nil {
+		return
 	}
 	pushOpts := dockertypes.ImagePushOptions{
 		RegistryAuth: registryAuth,
 	}
-	if pushResponse, errp := ac.cli.ImagePush(ctx, ac.appData.Image, pushOpts); errp != nil {
-		err = fmt.Errorf("Unable to push image '%s' to '%s': %s", ac.appData.Image, ac.config.Registry, errp.Error())
+	if pushResponse, errp := ac.cli.ImagePush(ctx, image, pushOpts); errp != nil {
+		err = fmt.Errorf("Unable to push image '%s': %s", image, errp.Error())
 		ac.log.Error(err)
 	} else {
 		defer pushResponse.Close()
-		if errd := ac.displayJSONMessagesStream(pushResponse, ac.output); errd != nil {
+		digest, errd := ac.displayJSONMessagesStream(pushResponse, ac.output, nil)
+		if errd != nil {
 			err = fmt.Errorf("Push error message: %s", errd.Error())
 			ac.log.Error(err)
-		} else {
-			ac.tags = append(ac.tags, ac.appData.Image)
+			return
 		}
+		ac.events.Emit(events.Event{
+			Type:   events.ImagePushed,
+			App:    ac.Name(),
+			Image:  image,
+			Digest: digest,
+		})
 	}
 	return
 }
 
 func (ac *DockerAppContainerImage) Run(ctx context.Context, dataDir string, env map[string]string, output bool) (err error) {
-	image, _, erri := ac.cli.ImageInspectWithRaw(ctx, ac.name)
+	host, err := ac.hostPlatform(ctx)
+	if err != nil {
+		return
+	}
+	// Image built for the platform of the Docker server
+	name := ac.name
+	for _, platform := range ac.config.Platforms {
+		if registry.MatchPlatform(platform, host) {
+			name = ac.platformName(platform)
+			break
+		}
+	}
+	image, _, erri := ac.cli.ImageInspectWithRaw(ctx, name)
 	if erri != nil {
-		err = fmt.Errorf("Unknown image '%s': %s", ac.name, erri.Error())
+		err = fmt.Errorf("Unknown image '%s': %s", name, erri.Error())
 		ac.log.Error(err)
 		return
 	}
 	containerhost := ac.name
-	ac.log.Infof("Running image '%s' tailing output, in container '%s' ...", ac.name, containerhost)
+	ac.log.Infof("Running image '%s' tailing output, in container '%s' ...", name, containerhost)
 	portMap := dockernat.PortMap{}
+	exposedPorts := []string{}
 	for p := range image.Config.ExposedPorts {
-		newport, err := dockernat.NewPort("tcp", p.Port())
+		exposedPorts = append(exposedPorts, p.Port())
+	}
+	// The image only exposes the first port of the application
+	for _, p := range ac.appData.Ports {
+		exposedPorts = append(exposedPorts, strconv.Itoa(p.Port))
+	}
+	for _, p := range exposedPorts {
+		newport, err := dockernat.NewPort("tcp", p)
 		if err != nil {
 			err = fmt.Errorf("Unable to setup docker networking for container '%s' : %s", containerhost, err.Error())
 			ac.log.Error(err)
@@ -403,7 +654,7 @@ func (ac *DockerAppContainerImage) Run(ctx context.Context, dataDir string, env
 		//portDef := dockernat.PortBinding{HostIP: "0.0.0.0"}
 		portDef := dockernat.PortBinding{}
 		if !ac.config.ContainerDynamicPorts {
-			portDef.HostPort = p.Port()
+			portDef.HostPort = p
 		}
 		portMap[newport] = []dockernat.PortBinding{portDef}
 	}
@@ -412,18 +663,10 @@ func (ac *DockerAppContainerImage) Run(ctx context.Context, dataDir string, env
 		volumeBindings = append(volumeBindings, dataDir+":"+ac.persistContainerDir)
 	}
 	resources := dockertypescontainer.Resources{}
-	cpuResources, errC := strconv.ParseFloat(ac.appData.Resources.CPU, 64)
-	if errC != nil {
-		ac.log.Warnf("Unable to apply cpu limits: %s", errC.Error())
-	}
-	memoryResources, errM := strconv.ParseInt(ac.appData.Resources.Mem, 10, 64)
-	if errM != nil {
-		ac.log.Warnf("Unable to apply memory limits: %s", errM.Error())
-	}
-	if errM == nil && errC == nil {
+	if rs := ac.appData.Resources; rs != nil {
 		resources = dockertypescontainer.Resources{
-			Memory:   memoryResources,
-			NanoCPUs: int64(cpuResources * (math.Pow(10, 9))),
+			Memory:   rs.Limits.Mem.Value(),
+			NanoCPUs: rs.Limits.CPU.MilliValue() * int64(math.Pow(10, 6)),
 		}
 	}
 	hostConfig := dockertypescontainer.HostConfig{
@@ -438,15 +681,20 @@ func (ac *DockerAppContainerImage) Run(ctx context.Context, dataDir string, env
 		},
 	}
 	networkConfig := dockertypesnetwork.NetworkingConfig{}
-	specs := ocispec.Platform{
-		Architecture: image.Architecture,
-		OS:           image.Os,
+	specs := host
+	if image.Os != host.OS || image.Architecture != host.Architecture {
+		ac.log.Warnf("Image '%s' is for platform %s/%s, not %s, it will run with emulation", name, image.Os, image.Architecture, registry.PlatformString(host))
+		specs = ocispec.Platform{
+			Architecture: image.Architecture,
+			OS:           image.Os,
+		}
 	}
 	kubefoundryEnv := map[string]string{
 		"APP_NAME":    ac.appData.Name,
 		"APP_CREATED": ac.contextData.DateHuman,
 		"APP_VERSION": ac.appData.Version,
 		"APP_PORT":    strconv.Itoa(ac.appData.Port),
+		"APP_PORTS":   ac.appData.PortList(),
 		"CF_MANIFEST": ac.contextData.CF.Manifest.Filename,
 		"CF_API":      ac.contextData.CF.Api,
 		"CF_ORG":      ac.contextData.CF.Org,
@@ -580,34 +828,50 @@ func (ac *DockerAppContainerImage) print(out io.Writer, info bool, msg, color st
 
 // DisplayJSONMessagesStream displays a json message stream from `in` to `out`, `isTerminal`
 // describes if `out` is a terminal. If this is the case, it will print `\n` at the end of
-// each line and move the cursor while displaying.
-func (ac *DockerAppContainerImage) displayJSONMessagesStream(in io.Reader, out io.Writer) error {
+// each line and move the cursor while displaying. With `phases` the phases of the
+// staging are tracked and the errors are returned as staging.StagingError. It returns
+// the digest of the image pushed or the id of the image built.
+func (ac *DockerAppContainerImage) displayJSONMessagesStream(in io.Reader, out io.Writer, phases *stagingProgress) (digest string, err error) {
 	_, isTerminal := term.GetFdInfo(out)
 	dec := json.NewDecoder(in)
 	stgrunning := false
 	status := ""
 	progress := false
+	layers := newLayerProgress(ac.Name(), ac.events)
 	for {
 		var jm jsonmessage.JSONMessage
-		if err := dec.Decode(&jm); err != nil {
+		if err = dec.Decode(&jm); err != nil {
 			if err == io.EOF {
 				break
 			}
 			err = fmt.Errorf("Error decoding Docker API message: %s", err.Error())
 			ac.log.Error(err)
-			return err
+			if phases != nil {
+				phases.finish(err)
+			}
+			return
 		}
 		if jm.Error != nil {
 			if jm.Error.Code == 401 {
-				err := fmt.Errorf("Docker API authentication error: %s", jm.ErrorMessage)
+				err = fmt.Errorf("Docker API authentication error: %s", jm.ErrorMessage)
+				ac.log.Error(err)
+				if phases != nil {
+					phases.finish(err)
+				}
+				return
+			}
+			if phases != nil {
+				err = phases.fail(jm.Error)
 				ac.log.Error(err)
-				return err
+				return
 			}
 			ac.log.Error(jm.Error)
-			return jm.Error
+			return "", jm.Error
 		} else {
 			stream := strings.TrimSpace(jm.Stream)
-			if stream != "" {
+			if stream != "" && phases != nil && !phases.update(stream) {
+				ac.log.Debug(stream)
+			} else if stream != "" {
 				if !stgrunning {
 					if strings.HasPrefix(stream, "#--- MSG!") {
 						ac.print(out, true, "", "")
@@ -656,17 +920,33 @@ func (ac *DockerAppContainerImage) displayJSONMessagesStream(in io.Reader, out i
 					//ac.print(out, false, jm.Status+"\n", "")
 				}
 				status = jm.Status
+				layers.update(jm)
 			}
 			if jm.Aux != nil {
-				var result dockertypes.BuildResult
-				if err := json.Unmarshal(*jm.Aux, &result); err != nil {
+				var result struct {
+					ID     string
+					Digest string
+				}
+				if err = json.Unmarshal(*jm.Aux, &result); err != nil {
 					err = fmt.Errorf("Failed to parse AUX message: %s", err.Error())
 					ac.log.Error(err)
-					return err
+					if phases != nil {
+						phases.finish(err)
+					}
+					return
+				}
+				if result.Digest != "" {
+					digest = result.Digest
+					ac.log.Debug("Image digest " + result.Digest)
+				} else if result.ID != "" {
+					digest = result.ID
+					ac.log.Debug("Image checksum " + result.ID)
 				}
-				ac.log.Debug("Image checksum " + string(result.ID))
 			}
 		}
 	}
-	return nil
+	if phases != nil {
+		phases.finish(nil)
+	}
+	return digest, nil
 }
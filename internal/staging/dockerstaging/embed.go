@@ -23,8 +23,11 @@ func IterateEmbedStaging(fn CallBackEmbedStaging) error {
 		if err != nil {
 			panic(err)
 		}
-		fn(entry, f.Name(), os.FileMode(0755))
+		err = fn(entry, f.Name(), os.FileMode(0755))
 		entry.Close()
+		if err != nil {
+			return err
+		}
 	}
 	return nil
 }
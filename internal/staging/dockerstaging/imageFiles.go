@@ -0,0 +1,84 @@
+package dockerstaging
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+
+	dockertypes "github.com/docker/docker/api/types"
+	dockertypescontainer "github.com/docker/docker/api/types/container"
+)
+
+const (
+	// File with the buildpack and command written by the staging process
+	DockerContainerStagingInfo = DockerContainerHomeDir + "/staging_info.yml"
+)
+
+// StagingInfo is the content of staging_info.yml (JSON, as in CF)
+type StagingInfo struct {
+	DetectedBuildpack string `json:"detected_buildpack"`
+	StartCommand      string `json:"start_command"`
+}
+
+// copyFromImage returns a tar stream with the path of the image, it creates
+// a container (not started) which is removed when the stream is closed
+func (ac *DockerAppContainerImage) copyFromImage(ctx context.Context, image, src string) (io.ReadCloser, error) {
+	config := dockertypescontainer.Config{
+		Image:      image,
+		Entrypoint: []string{"/bin/true"},
+	}
+	container, err := ac.cli.ContainerCreate(ctx, &config, nil, nil, nil, "")
+	if err != nil {
+		return nil, fmt.Errorf("Unable to create container from image '%s': %s", image, err.Error())
+	}
+	remove := func() {
+		ac.cli.ContainerRemove(context.Background(), container.ID, dockertypes.ContainerRemoveOptions{Force: true})
+	}
+	content, _, err := ac.cli.CopyFromContainer(ctx, container.ID, src)
+	if err != nil {
+		remove()
+		return nil, fmt.Errorf("Unable to read '%s' from image '%s': %s", src, image, err.Error())
+	}
+	return &containerFiles{ReadCloser: content, remove: remove}, nil
+}
+
+// containerFiles removes the container after reading the files
+type containerFiles struct {
+	io.ReadCloser
+	remove func()
+}
+
+func (c *containerFiles) Close() error {
+	err := c.ReadCloser.Close()
+	c.remove()
+	return err
+}
+
+// stagingInfo reads the staging_info.yml file from the image
+func (ac *DockerAppContainerImage) stagingInfo(ctx context.Context, image string) (info *StagingInfo, err error) {
+	content, err := ac.copyFromImage(ctx, image, DockerContainerStagingInfo)
+	if err != nil {
+		return
+	}
+	defer content.Close()
+	tr := tar.NewReader(content)
+	for {
+		header, errT := tr.Next()
+		if errT == io.EOF {
+			break
+		} else if errT != nil {
+			err = errT
+			return
+		}
+		if header.Typeflag == tar.TypeReg && path.Base(header.Name) == path.Base(DockerContainerStagingInfo) {
+			info = &StagingInfo{}
+			err = json.NewDecoder(tr).Decode(info)
+			return
+		}
+	}
+	err = fmt.Errorf("File '%s' not found", DockerContainerStagingInfo)
+	return
+}
@@ -0,0 +1,114 @@
+package dockerstaging
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const (
+	DockerLabelPrefix     = "com.springernature.kubefoundry."
+	DockerLabelTeam       = DockerLabelPrefix + "team"
+	DockerLabelAppName    = DockerLabelPrefix + "application.name"
+	DockerLabelAppVersion = DockerLabelPrefix + "application.version"
+	DockerLabelAppDate    = DockerLabelPrefix + "application.date"
+	DockerLabelCreated    = "org.opencontainers.image.created"
+	DockerLabelSource     = "org.opencontainers.image.source"
+	DockerLabelRevision   = "org.opencontainers.image.revision"
+	DockerLabelBaseName   = "org.opencontainers.image.base.name"
+	DockerLabelBaseDigest = "org.opencontainers.image.base.digest"
+)
+
+var (
+	// Default labels of the images, 'key=value' where value is a template
+	DockerDefaultLabels = []string{
+		"org.opencontainers.image.ref.name={{ .App }}",
+		DockerLabelCreated + "={{ .Created }}",
+		"org.opencontainers.image.version={{ .Version }}",
+		"org.opencontainers.image.description=CloudFoundry staging process in Docker",
+		DockerLabelSource + "={{ .Git }}",
+		DockerLabelRevision + "={{ .Commit }}",
+		DockerLabelBaseName + "={{ .BaseImage }}",
+		DockerLabelBaseDigest + "={{ .BaseDigest }}",
+		DockerLabelPrefix + "org={{ .Org }}",
+		DockerLabelPrefix + "space={{ .Space }}",
+		DockerLabelTeam + "={{ .Team }}",
+		DockerLabelAppName + "={{ .App }}",
+		DockerLabelAppVersion + "={{ .Version }}",
+		DockerLabelAppDate + "={{ .Created }}",
+	}
+)
+
+// labelData is the data available in the templates of the labels
+type labelData struct {
+	App        string
+	Version    string
+	Created    string
+	Team       string
+	Org        string
+	Space      string
+	Git        string
+	Commit     string
+	Ref        string
+	Image      string
+	BaseImage  string
+	BaseDigest string
+}
+
+// labels returns the labels of the image: the default ones plus the ones
+// defined in the configuration (DockerStaging.Labels) with the format
+// 'key=value'. The value is a Go template, an empty value removes the label.
+func (ac *DockerAppContainerImage) labels(created string) (labels map[string]string, err error) {
+	data := labelData{
+		App:        ac.appData.Name,
+		Version:    ac.appData.Version,
+		Created:    created,
+		Team:       ac.contextData.Team,
+		Org:        ac.contextData.CF.Org,
+		Space:      ac.contextData.CF.Space,
+		Git:        ac.contextData.Git,
+		Commit:     ac.contextData.Commit,
+		Ref:        ac.contextData.Ref,
+		Image:      ac.appData.Image,
+		BaseImage:  ac.config.ContainerBaseImage,
+		BaseDigest: ac.baseDigest,
+	}
+	labels = make(map[string]string)
+	for _, label := range append(DockerDefaultLabels, ac.config.Labels...) {
+		pair := strings.SplitN(label, "=", 2)
+		key := strings.TrimSpace(pair[0])
+		if len(pair) != 2 || key == "" {
+			err = fmt.Errorf("Invalid image label '%s', the format is 'key=value'", label)
+			ac.log.Error(err)
+			return
+		}
+		tpl, errT := template.New(key).Option("missingkey=error").Parse(pair[1])
+		if errT != nil {
+			err = fmt.Errorf("Invalid template in image label '%s': %s", key, errT.Error())
+			ac.log.Error(err)
+			return
+		}
+		value := &bytes.Buffer{}
+		if errT = tpl.Execute(value, data); errT != nil {
+			err = fmt.Errorf("Unable to render image label '%s': %s", key, errT.Error())
+			ac.log.Error(err)
+			return
+		}
+		if value.Len() == 0 {
+			delete(labels, key)
+		} else {
+			labels[key] = value.String()
+		}
+	}
+	return
+}
+
+// created returns the creation date for the metadata of the image
+func (ac *DockerAppContainerImage) created() string {
+	if ac.config.Reproducible {
+		return ac.contextData.SourceDate.Format(time.RFC3339)
+	}
+	return ac.contextData.DateHuman
+}
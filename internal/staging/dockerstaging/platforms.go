@@ -0,0 +1,144 @@
+package dockerstaging
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	events "kubefoundry/internal/events"
+	staging "kubefoundry/internal/staging"
+	registry "kubefoundry/pkg/registry"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// hostPlatform returns the platform of the Docker server
+func (ac *DockerAppContainerImage) hostPlatform(ctx context.Context) (platform ocispec.Platform, err error) {
+	version, err := ac.cli.ServerVersion(ctx)
+	if err != nil {
+		err = fmt.Errorf("Unable to get Docker server platform: %s", err.Error())
+		ac.log.Error(err)
+		return
+	}
+	platform.OS = version.Os
+	platform.Architecture = version.Arch
+	return
+}
+
+// platformName is the local name of the image of a platform: <name>:<os>-<arch>
+func (ac *DockerAppContainerImage) platformName(platform ocispec.Platform) string {
+	return ac.name + ":" + strings.ReplaceAll(registry.PlatformString(platform), "/", "-")
+}
+
+// platformReference is the reference of the image of a platform in the
+// registry, the tag of the app image with the platform as suffix
+func (ac *DockerAppContainerImage) platformReference(ref registry.Reference, platform ocispec.Platform) registry.Reference {
+	tag := ref.Tag
+	if tag == "" {
+		tag = "latest"
+	}
+	return ref.WithTag(tag + "-" + strings.ReplaceAll(registry.PlatformString(platform), "/", "-"))
+}
+
+// buildPlatforms builds one image per platform. The image of the platform
+// of the Docker server (or the first one) is also tagged with the name of
+// the app, to run it and to generate the SBOM.
+func (ac *DockerAppContainerImage) buildPlatforms(ctx context.Context, appbits os.FileInfo) (id string, err error) {
+	host, err := ac.hostPlatform(ctx)
+	if err != nil {
+		return
+	}
+	main := ""
+	hostFound := false
+	for _, platform := range ac.config.Platforms {
+		platform := platform
+		name := ac.platformName(platform)
+		// The base image must be available for the platform
+		if err = ac.pull(ctx, ac.baseImage, "", &platform); err != nil {
+			err = ac.stagingFailed(staging.PhasePull, err)
+			return
+		}
+		imageID, errB := ac.build(ctx, appbits, &platform, name)
+		if errB != nil {
+			err = errB
+			return
+		}
+		if main == "" || (!hostFound && registry.MatchPlatform(platform, host)) {
+			main, id = name, imageID
+			hostFound = registry.MatchPlatform(platform, host)
+		}
+	}
+	if err = ac.cli.ImageTag(ctx, main, ac.name); err != nil {
+		err = fmt.Errorf("Unable to tag image '%s' with '%s': %s", main, ac.name, err.Error())
+		ac.log.Error(err)
+	}
+	return
+}
+
+// pushPlatforms pushes the image of each platform with the tag
+// <tag>-<os>-<arch> and an index with all of them with the tag of the app
+func (ac *DockerAppContainerImage) pushPlatforms(ctx context.Context) (err error) {
+	ref, err := registry.ParseReference(ac.appData.Image)
+	if err != nil {
+		ac.log.Error(err)
+		return
+	}
+	if !ac.config.Force && ac.platformsUpToDate(ctx) {
+		ac.log.Infof("Image '%s' in the registry is up to date with the source for all platforms, skipping push", ac.appData.Image)
+		ac.tags = append(ac.tags, ac.appData.Image)
+		if err = ac.attachSBOM(ctx); err == nil {
+			err = ac.signImage(ctx, false)
+		}
+		return
+	}
+	manifests := []ocispec.Descriptor{}
+	platforms := []string{}
+	for _, platform := range ac.config.Platforms {
+		platform := platform
+		platformRef := ac.platformReference(ref, platform)
+		if err = ac.push(ctx, ac.platformName(platform), platformRef.String()); err != nil {
+			return
+		}
+		desc, errH := ac.registry.HeadManifest(ctx, platformRef)
+		if errH != nil {
+			err = fmt.Errorf("Unable to get digest of image '%s': %s", platformRef.String(), errH.Error())
+			ac.log.Error(err)
+			return
+		}
+		desc.Platform = &platform
+		manifests = append(manifests, desc)
+		platforms = append(platforms, registry.PlatformString(platform))
+	}
+	desc, err := ac.registry.PushIndex(ctx, ref, manifests)
+	if err != nil {
+		err = fmt.Errorf("Unable to push image index '%s': %s", ac.appData.Image, err.Error())
+		ac.log.Error(err)
+		return
+	}
+	ac.log.Infof("Image index '%s@%s' pushed with platforms: %s", ref.Name(), desc.Digest, strings.Join(platforms, ", "))
+	ac.events.Emit(events.Event{
+		Type:   events.ImagePushed,
+		App:    ac.Name(),
+		Image:  ac.appData.Image,
+		Digest: desc.Digest.String(),
+	})
+	ac.tags = append(ac.tags, ac.appData.Image)
+	if err = ac.attachSBOM(ctx); err == nil {
+		err = ac.signImage(ctx, true)
+	}
+	return
+}
+
+// platformsUpToDate checks if the images of all platforms in the registry
+// have the same source hash as the local ones
+func (ac *DockerAppContainerImage) platformsUpToDate(ctx context.Context) bool {
+	for _, platform := range ac.config.Platforms {
+		platform := platform
+		hash, _ := ac.localSourceHash(ctx, ac.platformName(platform))
+		if hash == "" || ac.remoteSourceHash(ctx, ac.appData.Image, &platform) != hash {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,117 @@
+package dockerstaging
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+
+	config "kubefoundry/internal/config"
+	registry "kubefoundry/pkg/registry"
+
+	dockertypes "github.com/docker/docker/api/types"
+)
+
+// newKeychain returns the credentials for registries defined in the
+// configuration (main registry and the list of registries), falling back to
+// the Docker client config (config.json and credential helpers). Passwords
+// are read when the registry is used, not all commands need them.
+func newKeychain(c *config.Docker) (*registry.Keychain, error) {
+	dockerConfig, err := registry.LoadDockerConfig(expandHome(c.Config))
+	if err != nil {
+		return nil, err
+	}
+	keychain := registry.NewKeychain(dockerConfig)
+	auths := []config.RegistryAuth{}
+	if c.Registry != "" {
+		auths = append(auths, config.RegistryAuth{
+			Registry:     c.Registry,
+			Username:     c.Username,
+			Password:     c.Password,
+			PasswordFile: c.PasswordFile,
+			PasswordEnv:  c.PasswordEnv,
+		})
+	}
+	auths = append(auths, c.Registries...)
+	for _, auth := range auths {
+		if auth.Registry == "" || auth.Username == "" {
+			// Without username credentials come from Docker config
+			continue
+		}
+		auth := auth
+		keychain.AddFunc(auth.Registry, func() (registry.Credential, error) {
+			password, err := registryPassword(auth)
+			if err != nil {
+				return registry.Credential{}, err
+			}
+			return registry.Credential{
+				Username: auth.Username,
+				Password: password,
+			}, nil
+		})
+	}
+	return keychain, nil
+}
+
+// registryPassword gets the password from the file, the environment
+// variable or the configuration, in this order
+func registryPassword(auth config.RegistryAuth) (string, error) {
+	if auth.PasswordFile != "" {
+		content, err := ioutil.ReadFile(expandHome(auth.PasswordFile))
+		if err != nil {
+			return "", fmt.Errorf("Unable to read password file for registry '%s': %s", auth.Registry, err.Error())
+		}
+		return strings.TrimRight(string(content), "\r\n"), nil
+	}
+	if auth.PasswordEnv != "" {
+		password, ok := os.LookupEnv(auth.PasswordEnv)
+		if !ok {
+			return "", fmt.Errorf("Environment variable '%s' with the password for registry '%s' not defined", auth.PasswordEnv, auth.Registry)
+		}
+		return password, nil
+	}
+	return auth.Password, nil
+}
+
+func expandHome(path string) string {
+	if strings.HasPrefix(path, "~/") {
+		if usr, err := user.Current(); err == nil {
+			return filepath.Join(usr.HomeDir, path[2:])
+		}
+	}
+	return path
+}
+
+// registryAuth returns the encoded credentials for the Docker API to pull
+// or push the image, empty for anonymous access
+func (ac *DockerAppContainerImage) registryAuth(image string) (auth string, err error) {
+	ref, err := registry.ParseReference(image)
+	if err != nil {
+		ac.log.Error(err)
+		return
+	}
+	cred, err := ac.keychain.Resolve(ref.Host())
+	if err != nil {
+		err = fmt.Errorf("Unable to get credentials for registry '%s': %s", ref.Registry, err.Error())
+		ac.log.Error(err)
+		return
+	}
+	if cred.Empty() {
+		ac.log.Debugf("No credentials for registry '%s'", ref.Registry)
+		return
+	}
+	ac.log.Debugf("Using credentials of '%s' for registry '%s'", cred.Username, ref.Registry)
+	authConfig := dockertypes.AuthConfig{
+		Username:      cred.Username,
+		Password:      cred.Password,
+		IdentityToken: cred.IdentityToken,
+		ServerAddress: ref.Registry,
+	}
+	authConfigBytes, _ := json.Marshal(authConfig)
+	auth = base64.URLEncoding.EncodeToString(authConfigBytes)
+	return
+}
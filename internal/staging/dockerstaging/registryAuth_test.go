@@ -0,0 +1,133 @@
+package dockerstaging
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	config "kubefoundry/internal/config"
+	log "kubefoundry/internal/log"
+	registry "kubefoundry/pkg/registry"
+
+	dockertypes "github.com/docker/docker/api/types"
+)
+
+func TestNewKeychain(t *testing.T) {
+	dir := t.TempDir()
+	dockerConfig := filepath.Join(dir, "config.json")
+	content := `{"auths": {
+  "registry.example.com": {"username": "docker", "password": "docker"},
+  "other.example.com:5000": {"username": "docker", "password": "docker"}
+}}`
+	if err := ioutil.WriteFile(dockerConfig, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	passwordFile := filepath.Join(dir, "password")
+	if err := ioutil.WriteFile(passwordFile, []byte("from-file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TEST_REGISTRY_PASSWORD", "from-env")
+	cases := []struct {
+		name     string
+		auth     config.RegistryAuth
+		host     string
+		username string
+		password string
+		err      bool
+	}{
+		{
+			name:     "password file first",
+			auth:     config.RegistryAuth{Username: "user", Password: "password", PasswordFile: passwordFile, PasswordEnv: "TEST_REGISTRY_PASSWORD"},
+			username: "user",
+			password: "from-file",
+		},
+		{
+			name:     "environment variable before password",
+			auth:     config.RegistryAuth{Username: "user", Password: "password", PasswordEnv: "TEST_REGISTRY_PASSWORD"},
+			username: "user",
+			password: "from-env",
+		},
+		{
+			name:     "password",
+			auth:     config.RegistryAuth{Username: "user", Password: "password"},
+			username: "user",
+			password: "password",
+		},
+		{
+			name:     "without username from Docker config",
+			auth:     config.RegistryAuth{Password: "password"},
+			username: "docker",
+			password: "docker",
+		},
+		{
+			name: "missing password file",
+			auth: config.RegistryAuth{Username: "user", PasswordFile: filepath.Join(dir, "missing")},
+			err:  true,
+		},
+		{
+			name: "undefined environment variable",
+			auth: config.RegistryAuth{Username: "user", PasswordEnv: "TEST_REGISTRY_UNDEFINED"},
+			err:  true,
+		},
+		{
+			name:     "other registry from Docker config",
+			auth:     config.RegistryAuth{Username: "user", Password: "password"},
+			host:     "other.example.com:5000",
+			username: "docker",
+			password: "docker",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			auth := c.auth
+			auth.Registry = "https://registry.example.com"
+			keychain, err := newKeychain(&config.Docker{Config: dockerConfig, Registries: []config.RegistryAuth{auth}})
+			if err != nil {
+				t.Fatalf("Credentials resolved before using the registry: %s", err.Error())
+			}
+			host := c.host
+			if host == "" {
+				host = "registry.example.com"
+			}
+			cred, err := keychain.Resolve(host)
+			if c.err {
+				if err == nil {
+					t.Errorf("Expected error, got %+v", cred)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cred.Username != c.username || cred.Password != c.password {
+				t.Errorf("Expected credentials %s:%s, got %s:%s", c.username, c.password, cred.Username, cred.Password)
+			}
+		})
+	}
+}
+
+func TestRegistryAuth(t *testing.T) {
+	keychain := registry.NewKeychain(nil)
+	keychain.Add("registry.example.com", registry.Credential{Username: "user", Password: "password"})
+	ac := &DockerAppContainerImage{DockerStaging: &DockerStaging{keychain: keychain, log: log.StandardLogger()}}
+	auth, err := ac.registryAuth("registry.example.com/team/app:1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := base64.URLEncoding.DecodeString(auth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authConfig := dockertypes.AuthConfig{}
+	if err = json.Unmarshal(decoded, &authConfig); err != nil {
+		t.Fatal(err)
+	}
+	if authConfig.Username != "user" || authConfig.Password != "password" || authConfig.ServerAddress != "registry.example.com" {
+		t.Errorf("Unexpected credentials for the Docker API %+v", authConfig)
+	}
+	if auth, err = ac.registryAuth("team/app:1.0"); err != nil || auth != "" {
+		t.Errorf("Expected anonymous access to Docker Hub, got '%s' (%v)", auth, err)
+	}
+}
@@ -0,0 +1,105 @@
+package dockerstaging
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"time"
+
+	sbom "kubefoundry/internal/sbom"
+	cosign "kubefoundry/pkg/cosign"
+	registry "kubefoundry/pkg/registry"
+)
+
+const (
+	// Folder with the staged application and the dependencies (deps)
+	DockerContainerHomeDir = "/home/vcap"
+	// Extension of the SBOM files written next to the manifests
+	SBOMFileExtension = ".cdx.json"
+)
+
+// generateSBOM creates the CycloneDX SBOM of the staged image reading the
+// home folder of a (not started) container. In reproducible mode the date is
+// SOURCE_DATE_EPOCH and the serial number is derived from the image ID.
+func (ac *DockerAppContainerImage) generateSBOM(ctx context.Context) (err error) {
+	if ac.config.SBOM == "" || ac.config.SBOM == "none" {
+		return
+	}
+	ac.log.Infof("Generating SBOM of image '%s' ...", ac.name)
+	content, err := ac.copyFromImage(ctx, ac.name, DockerContainerHomeDir)
+	if err != nil {
+		err = fmt.Errorf("Unable to read staged files of '%s': %s", ac.name, err.Error())
+		ac.log.Error(err)
+		return
+	}
+	defer content.Close()
+	timestamp := time.Now()
+	if ac.config.Reproducible {
+		timestamp = ac.contextData.SourceDate
+	}
+	bom := sbom.New(ac.appData.Image, ac.appData.Version, timestamp)
+	if ac.config.Reproducible {
+		image, _, errI := ac.cli.ImageInspectWithRaw(ctx, ac.name)
+		if errI != nil {
+			err = fmt.Errorf("Unable to get ID of image '%s': %s", ac.name, errI.Error())
+			ac.log.Error(err)
+			return
+		}
+		bom.SetSerial(image.ID)
+	}
+	if err = bom.Scan(content); err != nil {
+		err = fmt.Errorf("Unable to generate SBOM of '%s': %s", ac.name, err.Error())
+		ac.log.Error(err)
+		return
+	}
+	if ac.sbom, err = bom.JSON(); err != nil {
+		err = fmt.Errorf("Unable to generate SBOM of '%s': %s", ac.name, err.Error())
+		ac.log.Error(err)
+		return
+	}
+	ac.log.Infof("SBOM of image '%s' has %d components", ac.name, len(bom.Components))
+	if ac.config.SBOM == "file" || ac.config.SBOM == "all" {
+		path := filepath.Join(ac.contextData.Dir, ac.appData.Name+SBOMFileExtension)
+		if err = ioutil.WriteFile(path, ac.sbom, 0644); err != nil {
+			err = fmt.Errorf("Unable to write SBOM file '%s': %s", path, err.Error())
+			ac.log.Error(err)
+			return
+		}
+		ac.log.Infof("SBOM of image '%s' written to '%s'", ac.name, path)
+	}
+	return
+}
+
+// attachSBOM stores the SBOM in the registry as an artifact of the image
+// digest, in the same way as 'cosign attach sbom'
+func (ac *DockerAppContainerImage) attachSBOM(ctx context.Context) (err error) {
+	if ac.config.SBOM != "registry" && ac.config.SBOM != "all" {
+		return
+	}
+	if ac.sbom == nil {
+		// Only pushing, the image was built before
+		if err = ac.generateSBOM(ctx); err != nil {
+			return
+		}
+	}
+	ref, err := registry.ParseReference(ac.appData.Image)
+	if err != nil {
+		ac.log.Error(err)
+		return
+	}
+	desc, err := ac.registry.HeadManifest(ctx, ref)
+	if err != nil {
+		err = fmt.Errorf("Unable to get digest of image '%s': %s", ac.appData.Image, err.Error())
+		ac.log.Error(err)
+		return
+	}
+	sbomRef := ref.WithTag(cosign.Tag(desc.Digest.String(), cosign.SBOMTagSuffix))
+	if err = cosign.Attach(ctx, ac.registry, sbomRef, sbom.MediaType, ac.sbom, nil, true); err != nil {
+		err = fmt.Errorf("Unable to attach SBOM to image '%s': %s", ac.appData.Image, err.Error())
+		ac.log.Error(err)
+		return
+	}
+	ac.log.Infof("SBOM of image '%s@%s' attached: %s", ref.Name(), desc.Digest, sbomRef.String())
+	return
+}
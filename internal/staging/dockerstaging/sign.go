@@ -0,0 +1,168 @@
+package dockerstaging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	buildpacks "kubefoundry/internal/buildpacks"
+	config "kubefoundry/internal/config"
+	cosign "kubefoundry/pkg/cosign"
+	registry "kubefoundry/pkg/registry"
+)
+
+const (
+	// Identifiers of the builder and type of build in the provenance
+	ProvenanceBuilderID = config.ConfigUserAgent + "/DockerStaging"
+	ProvenanceBuildType = config.ConfigUserAgent + "/DockerStaging@v1"
+)
+
+// signImage signs the image in the registry and attaches the provenance
+// attestation. If the image was not pushed (up to date), it is only signed
+// when there is no signature.
+func (ac *DockerAppContainerImage) signImage(ctx context.Context, pushed bool) (err error) {
+	if ac.signer == nil {
+		return
+	}
+	ref, err := registry.ParseReference(ac.appData.Image)
+	if err != nil {
+		ac.log.Error(err)
+		return
+	}
+	desc, err := ac.registry.HeadManifest(ctx, ref)
+	if err != nil {
+		err = fmt.Errorf("Unable to get digest of image '%s': %s", ac.appData.Image, err.Error())
+		ac.log.Error(err)
+		return
+	}
+	dgst := desc.Digest.String()
+	if !pushed {
+		sigRef := ref.WithTag(cosign.Tag(dgst, cosign.SignatureTagSuffix))
+		if _, errH := ac.registry.HeadManifest(ctx, sigRef); errH == nil {
+			ac.log.Infof("Image '%s@%s' already signed", ref.Name(), dgst)
+			return
+		} else if !errors.Is(errH, registry.ErrNotFound) {
+			ac.log.Warnf("Unable to check signature of '%s': %s", ac.appData.Image, errH.Error())
+		}
+	}
+	annotations := map[string]interface{}{
+		"app":     ac.appData.Name,
+		"version": ac.appData.Version,
+	}
+	if ac.contextData.Commit != "" {
+		annotations["commit"] = ac.contextData.Commit
+	}
+	sigRef, err := ac.signer.Sign(ctx, ref, dgst, annotations)
+	if err != nil {
+		err = fmt.Errorf("Unable to sign image '%s@%s': %s", ref.Name(), dgst, err.Error())
+		ac.log.Error(err)
+		return
+	}
+	ac.log.Infof("Image '%s@%s' signed: %s", ref.Name(), dgst, sigRef.String())
+	if ac.config.Attest {
+		statement := cosign.NewProvenanceStatement(ref.Name(), dgst, ac.provenance(ctx))
+		attRef, errA := ac.signer.Attest(ctx, ref, dgst, statement)
+		if errA != nil {
+			err = fmt.Errorf("Unable to attach provenance to image '%s@%s': %s", ref.Name(), dgst, errA.Error())
+			ac.log.Error(err)
+			return
+		}
+		ac.log.Infof("Provenance of image '%s@%s' attached: %s", ref.Name(), dgst, attRef.String())
+	}
+	return
+}
+
+// provenance returns the SLSA provenance of the image with the git commit,
+// base image, buildpacks and build arguments
+func (ac *DockerAppContainerImage) provenance(ctx context.Context) *cosign.Provenance {
+	provenance := &cosign.Provenance{
+		Builder:   cosign.Builder{ID: ProvenanceBuilderID},
+		BuildType: ProvenanceBuildType,
+		Metadata: &cosign.Metadata{
+			Reproducible: ac.config.Reproducible,
+			Completeness: cosign.Completeness{
+				Parameters: true,
+			},
+		},
+	}
+	if ac.contextData.Git != "" {
+		source := cosign.ConfigSource{
+			URI:        "git+" + ac.contextData.Git,
+			EntryPoint: ac.contextData.CF.Manifest.Filename,
+		}
+		if ac.contextData.Commit != "" {
+			source.Digest = map[string]string{"sha1": ac.contextData.Commit}
+		}
+		provenance.Invocation.ConfigSource = source
+		provenance.Materials = append(provenance.Materials, cosign.Material{
+			URI:    source.URI,
+			Digest: source.Digest,
+		})
+	}
+	if appbits, err := os.Stat(ac.appData.Dir); err == nil {
+		provenance.Invocation.Parameters = make(map[string]string)
+		for key, value := range ac.buildArgs(appbits) {
+			provenance.Invocation.Parameters[key] = *value
+		}
+	}
+	// Base image resolved (pinned with the lock file) and used in the build,
+	// for stack images also the image running the application
+	provenance.Materials = append(provenance.Materials, ac.imageMaterial(ctx, ac.baseImage, ac.baseDigest))
+	if ac.runBase != "" && ac.runBase != ac.baseImage {
+		provenance.Materials = append(provenance.Materials, ac.imageMaterial(ctx, ac.runBase, ""))
+	}
+	for _, bp := range ac.manifestBuildpacks() {
+		material := cosign.Material{URI: bp}
+		// Version pinned in the lock file
+		if resolved, locked := ac.lock.Buildpack(bp); locked {
+			if source, err := buildpacks.ParseSource(resolved); err == nil && source.Pinned() {
+				if source.Zip {
+					material.URI = source.URL
+					if algorithm := strings.SplitN(source.Digest, ":", 2); len(algorithm) == 2 {
+						material.Digest = map[string]string{algorithm[0]: algorithm[1]}
+					}
+				} else {
+					material.URI = "git+" + source.URL
+					material.Digest = map[string]string{"sha1": source.Ref}
+				}
+			}
+		}
+		provenance.Materials = append(provenance.Materials, material)
+	}
+	if image, _, err := ac.cli.ImageInspectWithRaw(ctx, ac.name); err == nil {
+		if created, errT := time.Parse(time.RFC3339Nano, image.Created); errT == nil {
+			provenance.Metadata.BuildFinishedOn = &created
+		}
+	}
+	return provenance
+}
+
+// imageMaterial returns the material of an image with its digest, the one
+// of the reference or the local image if it is not defined
+func (ac *DockerAppContainerImage) imageMaterial(ctx context.Context, image, digest string) cosign.Material {
+	material := cosign.Material{URI: "docker://" + image}
+	ref, err := registry.ParseReference(image)
+	if err != nil {
+		return material
+	}
+	if digest == "" {
+		digest = ref.Digest
+	}
+	if digest == "" {
+		if info, _, errI := ac.cli.ImageInspectWithRaw(ctx, image); errI == nil {
+			for _, repoDigest := range info.RepoDigests {
+				if local, errR := registry.ParseReference(repoDigest); errR == nil && local.Name() == ref.Name() {
+					digest = local.Digest
+					break
+				}
+			}
+		}
+	}
+	if algorithm := strings.SplitN(digest, ":", 2); len(algorithm) == 2 {
+		material.Digest = map[string]string{algorithm[0]: algorithm[1]}
+	}
+	return material
+}
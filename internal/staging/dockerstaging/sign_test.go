@@ -0,0 +1,126 @@
+package dockerstaging
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	lockfile "kubefoundry/internal/lockfile"
+	log "kubefoundry/internal/log"
+	cfmanifest "kubefoundry/internal/manifests"
+
+	dockertypes "github.com/docker/docker/api/types"
+	docker "github.com/docker/docker/client"
+)
+
+// testDockerClient returns a client of a fake Docker daemon with the local
+// images, name: repo digests
+func testDockerClient(t *testing.T, images map[string][]string) *docker.Client {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.Path
+		if i := strings.Index(path, "/images/"); i >= 0 && strings.HasSuffix(path, "/json") {
+			name := strings.TrimSuffix(path[i+len("/images/"):], "/json")
+			if digests, ok := images[name]; ok {
+				json.NewEncoder(w).Encode(dockertypes.ImageInspect{ID: "sha256:local", RepoDigests: digests})
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"message": "not found"}`))
+	}))
+	t.Cleanup(server.Close)
+	cli, err := docker.NewClientWithOpts(docker.WithHost("tcp://"+strings.TrimPrefix(server.URL, "http://")), docker.WithVersion("1.41"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cli
+}
+
+func TestProvenanceBaseImage(t *testing.T) {
+	stackDigest := "sha256:" + strings.Repeat("a", 64)
+	runDigest := "sha256:" + strings.Repeat("b", 64)
+	cases := []struct {
+		name       string
+		baseImage  string
+		baseDigest string
+		runBase    string
+		images     map[string][]string
+		materials  map[string]string
+	}{
+		{
+			name:       "pinned base image",
+			baseImage:  "registry.example.com/stacks/cflinuxfs3@" + stackDigest,
+			baseDigest: stackDigest,
+			materials:  map[string]string{"docker://registry.example.com/stacks/cflinuxfs3@" + stackDigest: stackDigest},
+		},
+		{
+			name:      "not pinned with local digest",
+			baseImage: "registry.example.com/stacks/cflinuxfs3:latest",
+			images:    map[string][]string{"registry.example.com/stacks/cflinuxfs3:latest": {"registry.example.com/stacks/cflinuxfs3@" + stackDigest}},
+			materials: map[string]string{"docker://registry.example.com/stacks/cflinuxfs3:latest": stackDigest},
+		},
+		{
+			name:      "not pinned without digest",
+			baseImage: "registry.example.com/stacks/cflinuxfs3:latest",
+			materials: map[string]string{"docker://registry.example.com/stacks/cflinuxfs3:latest": ""},
+		},
+		{
+			name:       "stack image",
+			baseImage:  "registry.example.com/stacks/python@" + stackDigest,
+			baseDigest: stackDigest,
+			runBase:    "cloudfoundry/cflinuxfs3@" + runDigest,
+			materials: map[string]string{
+				"docker://registry.example.com/stacks/python@" + stackDigest: stackDigest,
+				"docker://cloudfoundry/cflinuxfs3@" + runDigest:              runDigest,
+			},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			lock, err := lockfile.Load(lockfile.Path(t.TempDir()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			ac := &DockerAppContainerImage{
+				DockerStaging: &DockerStaging{
+					cli: testDockerClient(t, c.images),
+					// The configured image is not the one used
+					config: &DockerStagingConfig{ContainerBaseImage: "cloudfoundry/cflinuxfs3"},
+					log:    log.StandardLogger(),
+					lock:   lock,
+					contextData: &cfmanifest.ContextData{
+						CF: &cfmanifest.CfData{
+							Manifest: &cfmanifest.CfManifest{Apps: []cfmanifest.CfApplication{{Name: "app"}}},
+						},
+					},
+				},
+				appData:    &cfmanifest.AppData{Name: "app", Dir: "/nonexistent"},
+				name:       "registry.example.com/team/app:1.0",
+				baseImage:  c.baseImage,
+				baseDigest: c.baseDigest,
+				runBase:    c.runBase,
+			}
+			provenance := ac.provenance(context.Background())
+			if len(provenance.Materials) != len(c.materials) {
+				t.Fatalf("Expected materials %v, got %+v", c.materials, provenance.Materials)
+			}
+			for _, m := range provenance.Materials {
+				digest, ok := c.materials[m.URI]
+				if !ok {
+					t.Errorf("Unexpected material %s", m.URI)
+					continue
+				}
+				got := ""
+				for algorithm, hex := range m.Digest {
+					got = algorithm + ":" + hex
+				}
+				if got != digest {
+					t.Errorf("Expected digest '%s' of %s, got '%s'", digest, m.URI, got)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package dockerstaging
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"sort"
+
+	registry "kubefoundry/pkg/registry"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+const (
+	// Label with the hash of all the inputs used to build the image
+	DockerLabelSourceHash = "com.springernature.kubefoundry.source.hash"
+)
+
+var (
+	// Build args which do not change the result of the staging
+	sourceHashIgnoredArgs = map[string]bool{
+		"APP_CREATED": true,
+	}
+)
+
+// sourceHash returns a hash of everything used to build the image: the
+// digest of the build context (application bits, manifest, staging assets
+// and vendored buildpacks), the base image, the platform, the build
+// arguments and the labels defined in the configuration. The digest of the
+// context does not include dates, the hash only changes with the content.
+func (ac *DockerAppContainerImage) sourceHash(contextDigest, baseImageID string, buildArgs map[string]*string, platform *ocispec.Platform) (hash string) {
+	h := sha256.New()
+	fmt.Fprintf(h, "context=%s\n", contextDigest)
+	fmt.Fprintf(h, "base=%s\n", baseImageID)
+	if platform != nil {
+		fmt.Fprintf(h, "platform=%s\n", registry.PlatformString(*platform))
+	}
+	if app, errA := ac.contextData.CF.Manifest.GetApplication(ac.appData.Name); errA == nil {
+		bps, _ := app.GetBuildpacks()
+		for _, bp := range bps {
+			fmt.Fprintf(h, "buildpack=%s\n", bp)
+		}
+	}
+	args := []string{}
+	for key := range buildArgs {
+		if !sourceHashIgnoredArgs[key] {
+			args = append(args, key)
+		}
+	}
+	sort.Strings(args)
+	for _, key := range args {
+		fmt.Fprintf(h, "arg=%s=%s\n", key, *buildArgs[key])
+	}
+	// The labels as configured, the rendered ones change with the date
+	for _, label := range ac.config.Labels {
+		fmt.Fprintf(h, "label=%s\n", label)
+	}
+	hash = fmt.Sprintf("sha256:%x", h.Sum(nil))
+	ac.log.Debugf("Source hash of '%s': %s", ac.name, hash)
+	return
+}
+
+// localSourceHash returns the source hash label of a local image
+func (ac *DockerAppContainerImage) localSourceHash(ctx context.Context, image string) (hash, id string) {
+	if info, _, err := ac.cli.ImageInspectWithRaw(ctx, image); err == nil && info.Config != nil {
+		hash = info.Config.Labels[DockerLabelSourceHash]
+		id = info.ID
+	}
+	return
+}
+
+// remoteSourceHash returns the source hash label of the image in the
+// registry for the platform, errors are not fatal, the image will be built
+// and pushed
+func (ac *DockerAppContainerImage) remoteSourceHash(ctx context.Context, image string, platform *ocispec.Platform) (hash string) {
+	ref, err := registry.ParseReference(image)
+	if err != nil {
+		ac.log.Warnf("Unable to check image '%s' in the registry: %s", image, err.Error())
+		return
+	}
+	config, err := ac.registry.ImageConfig(ctx, ref, platform)
+	if errors.Is(err, registry.ErrNotFound) {
+		ac.log.Debugf("Image '%s' not found in the registry", image)
+	} else if err != nil {
+		ac.log.Warnf("Unable to check image '%s' in the registry: %s", image, err.Error())
+	} else {
+		hash = config.Config.Labels[DockerLabelSourceHash]
+	}
+	return
+}
+
+// findImage looks for an image built from the same source, locally or in
+// the registry (pulling it for the platform), and tags it with name
+func (ac *DockerAppContainerImage) findImage(ctx context.Context, hash, name string, platform *ocispec.Platform) (id string, found bool) {
+	images := []string{name}
+	if platform == nil {
+		images = append(images, ac.appData.Image)
+	}
+	for _, image := range images {
+		if local, imageID := ac.localSourceHash(ctx, image); local == hash {
+			ac.log.Infof("Image '%s' is up to date with the source (%s), skipping build", image, hash)
+			if image != name {
+				if err := ac.cli.ImageTag(ctx, image, name); err != nil {
+					ac.log.Warnf("Unable to tag image '%s' with '%s': %s", image, name, err.Error())
+					return
+				}
+			}
+			return imageID, true
+		}
+	}
+	if ac.remoteSourceHash(ctx, ac.appData.Image, platform) == hash {
+		ac.log.Infof("Image '%s' in the registry is up to date with the source (%s), skipping build", ac.appData.Image, hash)
+		if err := ac.pull(ctx, ac.appData.Image, name, platform); err != nil {
+			return
+		}
+		if _, imageID := ac.localSourceHash(ctx, name); imageID != "" {
+			return imageID, true
+		}
+	}
+	return
+}
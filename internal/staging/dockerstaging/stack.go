@@ -0,0 +1,192 @@
+package dockerstaging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	kfbuildpacks "kubefoundry/internal/buildpacks"
+	events "kubefoundry/internal/events"
+	cfmanifest "kubefoundry/internal/manifests"
+	registry "kubefoundry/pkg/registry"
+	tar "kubefoundry/pkg/tar"
+
+	dockertypes "github.com/docker/docker/api/types"
+	units "github.com/docker/go-units"
+)
+
+const (
+	// Dockerfile to build the applications on top of a stack image
+	DockerContainerDockerFileStack = "Dockerfile-stack"
+	// Dockerfile to build stack images
+	DockerContainerDockerFileStackImage = "Dockerfile-stack-image"
+	// Labels of the stack images, the name, the base image used to run the
+	// applications and the buildpacks preinstalled
+	DockerLabelStack           = DockerLabelPrefix + "stack"
+	DockerLabelStackBase       = DockerLabelPrefix + "stack.base"
+	DockerLabelStackBuildpacks = DockerLabelPrefix + "stack.buildpacks"
+)
+
+// BuildStack builds a stack image from the base image with the staging
+// assets and the buildpacks (all the known ones if empty) preinstalled.
+// The context data gives the date of the image.
+func (ds *DockerStaging) BuildStack(ctx context.Context, data *cfmanifest.ContextData, image, base string, buildpacks []string, push bool, output io.Writer) (id string, err error) {
+	ds.contextData = data
+	// The stack is built like an image without application
+	stack := &DockerAppContainerImage{
+		DockerStaging: ds,
+		baseImage:     base,
+		name:          image,
+		output:        output,
+	}
+	if _, err = registry.ParseReference(image); err != nil {
+		err = fmt.Errorf("Invalid stack image name '%s': %s", image, err.Error())
+		ds.log.Error(err)
+		return
+	}
+	ref, err := registry.ParseReference(base)
+	if err != nil {
+		err = fmt.Errorf("Invalid base image '%s': %s", base, err.Error())
+		ds.log.Error(err)
+		return
+	}
+	// Pin the base image, the applications will run on it
+	if ref.Digest == "" {
+		if digest, errD := stack.baseImageDigest(ctx, ref); errD == nil {
+			stack.baseImage = ref.Name() + "@" + digest
+			stack.baseDigest = digest
+		} else {
+			ds.log.Warnf("Unable to pin base image '%s' to a digest, using the tag: %s", base, errD.Error())
+		}
+	} else {
+		stack.baseDigest = ref.Digest
+	}
+	if err = stack.pull(ctx, stack.baseImage, "", nil); err != nil {
+		return
+	}
+	ds.log.Infof("Building stack image '%s' from '%s' ...", image, stack.baseImage)
+	args := []string{}
+	for _, bp := range buildpacks {
+		args = append(args, "-b", bp)
+	}
+	buildpacksArg := strings.Join(args, " ")
+	sourceDateEpoch := data.SourceDateEpoch()
+	buildArgs := map[string]*string{
+		"BASE":              &stack.baseImage,
+		"BUILDPACKS":        &buildpacksArg,
+		"BUILDPACKS_DIR":    &ds.bpContainerDir,
+		"SOURCE_DATE_EPOCH": &sourceDateEpoch,
+	}
+	labels := map[string]string{
+		DockerLabelCreated:         stack.created(),
+		DockerLabelBaseName:        base,
+		DockerLabelStack:           image,
+		DockerLabelStackBase:       stack.baseImage,
+		DockerLabelStackBuildpacks: strings.Join(buildpacks, ","),
+	}
+	if stack.baseDigest != "" {
+		labels[DockerLabelBaseDigest] = stack.baseDigest
+	}
+	imageBuildOptions := dockertypes.ImageBuildOptions{
+		Remove:      true,
+		ForceRemove: true,
+		Dockerfile:  DockerContainerDockerFileStackImage,
+		Tags:        []string{image},
+		BuildArgs:   buildArgs,
+		Labels:      labels,
+	}
+	buildContext := stack.streamStackContext(ctx, buildpacks)
+	defer buildContext.Close()
+	buildResponse, err := ds.cli.ImageBuild(ctx, buildContext, imageBuildOptions)
+	if err != nil {
+		buildContext.CloseWithError(err)
+		err = fmt.Errorf("Unable to build stack image '%s': %s", image, err.Error())
+		ds.log.Error(err)
+		return
+	}
+	defer buildResponse.Body.Close()
+	if _, err = stack.displayJSONMessagesStream(buildResponse.Body, output, nil); err != nil {
+		err = fmt.Errorf("Docker stack build error: %s", err.Error())
+		ds.log.Error(err)
+		return
+	}
+	info, _, err := ds.cli.ImageInspectWithRaw(ctx, image)
+	if err != nil {
+		err = fmt.Errorf("Stack image build not completed: %s", err.Error())
+		ds.log.Error(err)
+		return
+	}
+	id = info.ID
+	ds.log.Infof("Stack image '%s' built: %s", image, id)
+	ds.events.Emit(events.Event{
+		Type:   events.ImageBuilt,
+		Image:  image,
+		Digest: id,
+	})
+	if push {
+		err = stack.push(ctx, image, image)
+	}
+	return
+}
+
+// streamStackContext creates the build context of the stack image (the
+// staging assets and the vendored buildpacks) in background, like the
+// context of the applications
+func (ac *DockerAppContainerImage) streamStackContext(ctx context.Context, buildpacks []string) *io.PipeReader {
+	reader, writer := io.Pipe()
+	go func() {
+		progress := newContextProgress(func(size int64) {
+			msg := fmt.Sprintf("Sending build context to Docker daemon %s", units.HumanSize(float64(size)))
+			ac.log.Debug(msg)
+			ac.print(ac.output, true, msg, "\033[1;36m")
+		})
+		t := tar.NewTar(".", ac.log, writer, progress)
+		if ac.config.Reproducible {
+			t.SetReproducible(ac.contextData.SourceDate)
+			ac.log.Debugf("Reproducible build context with SOURCE_DATE_EPOCH=%s", ac.contextData.SourceDateEpoch())
+		}
+		err := IterateEmbedStaging(t.AddFile)
+		if err == nil {
+			// Vendored buildpacks are preinstalled without downloading them
+			names := buildpacks
+			if len(names) == 0 {
+				names = kfbuildpacks.Names()
+			}
+			err = ac.packBuildpacks(ctx, t, names)
+		}
+		if errc := t.Close(); err == nil && errc != nil {
+			err = errc
+		}
+		if err != nil {
+			err = fmt.Errorf("Unable to package staging assets: %s", err.Error())
+			ac.log.Error(err)
+		} else {
+			ac.print(ac.output, true, "", "")
+			ac.log.Infof("Build context for '%s' sent to Docker: %s", ac.name, units.HumanSize(float64(progress.Size())))
+		}
+		writer.CloseWithError(err)
+	}()
+	return reader
+}
+
+// detectStack checks if the base image is a stack image (by the labels) to
+// build the application with the stack Dockerfile
+func (ac *DockerAppContainerImage) detectStack(labels map[string]string) {
+	ac.runBase = ""
+	if _, isStack := labels[DockerLabelStack]; isStack {
+		ac.runBase = labels[DockerLabelStackBase]
+		if ac.runBase == "" {
+			ac.runBase = ac.baseImage
+		}
+		ac.log.Infof("Base image '%s' is a stack with buildpacks [%s], running the application on '%s'", ac.baseImage, labels[DockerLabelStackBuildpacks], ac.runBase)
+	}
+}
+
+// stagingDockerfile returns the Dockerfile used to build the application
+func (ac *DockerAppContainerImage) stagingDockerfile() string {
+	if ac.runBase != "" {
+		return DockerContainerDockerFileStack
+	}
+	return ac.dockerfile
+}
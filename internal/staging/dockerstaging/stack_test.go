@@ -0,0 +1,84 @@
+package dockerstaging
+
+import (
+	"archive/tar"
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDetectStack(t *testing.T) {
+	cases := []struct {
+		name       string
+		labels     map[string]string
+		runBase    string
+		dockerfile string
+	}{
+		{
+			name:       "not a stack",
+			labels:     map[string]string{DockerLabelBaseName: "cloudfoundry/cflinuxfs3"},
+			dockerfile: DockerContainerDockerFile,
+		},
+		{
+			name: "stack",
+			labels: map[string]string{
+				DockerLabelStack:           "registry.example.com/stacks/python",
+				DockerLabelStackBase:       "cloudfoundry/cflinuxfs3@sha256:aaaa",
+				DockerLabelStackBuildpacks: "python_buildpack",
+			},
+			runBase:    "cloudfoundry/cflinuxfs3@sha256:aaaa",
+			dockerfile: DockerContainerDockerFileStack,
+		},
+		{
+			name:       "stack without run base",
+			labels:     map[string]string{DockerLabelStack: "registry.example.com/stacks/python"},
+			runBase:    "registry.example.com/stacks/python@sha256:bbbb",
+			dockerfile: DockerContainerDockerFileStack,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ac := testContextApp(t, nil)
+			ac.dockerfile = DockerContainerDockerFile
+			ac.baseImage = "registry.example.com/stacks/python@sha256:bbbb"
+			// A previous stack does not remain
+			ac.runBase = "previous"
+			ac.detectStack(c.labels)
+			if ac.runBase != c.runBase {
+				t.Errorf("Expected run base '%s', got '%s'", c.runBase, ac.runBase)
+			}
+			if dockerfile := ac.stagingDockerfile(); dockerfile != c.dockerfile {
+				t.Errorf("Expected Dockerfile '%s', got '%s'", c.dockerfile, dockerfile)
+			}
+		})
+	}
+}
+
+func TestStreamStackContext(t *testing.T) {
+	ac := testContextApp(t, nil)
+	ac.config.Reproducible = true
+	ac.contextData.SourceDate = time.Unix(1600000000, 0).UTC()
+	reader := ac.streamStackContext(context.Background(), nil)
+	defer reader.Close()
+	entries := make(map[string]bool)
+	tr := tar.NewReader(reader)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		entries[strings.TrimSuffix(header.Name, "/")] = true
+		if !header.ModTime.Equal(ac.contextData.SourceDate) {
+			t.Errorf("Expected date %s of '%s', got %s", ac.contextData.SourceDate, header.Name, header.ModTime)
+		}
+	}
+	for _, name := range []string{DockerContainerDockerFileStackImage, strings.TrimPrefix(ac.bpContainerDir, "/")} {
+		if !entries[name] {
+			t.Errorf("Expected '%s' in the stack context, got %v", name, entries)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package dockerstaging
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	events "kubefoundry/internal/events"
+	staging "kubefoundry/internal/staging"
+
+	jsonmessage "github.com/moby/moby/pkg/jsonmessage"
+)
+
+const (
+	// Lines of output attached to the staging errors
+	stagingErrorLines = 20
+)
+
+var stepRegexp = regexp.MustCompile(`^Step \d+/\d+ : (\w+)`)
+
+// stagingProgress follows the phases of a staging build with the markers
+// written by the Dockerfile and staging.py in the output: "#--- SRT!" when
+// staging.py starts, "#--- PHS! <phase> #<index> <buildpack>" before each
+// step of a buildpack and "#--- END!" when staging.py finishes. The start
+// and end of each phase are emitted as events.
+type stagingProgress struct {
+	app       string
+	phase     staging.Phase
+	buildpack string
+	started   bool
+	lines     []string
+	events    events.Sink
+	end       func(err error)
+}
+
+func newStagingProgress(app string, sink events.Sink) *stagingProgress {
+	p := &stagingProgress{
+		app:    app,
+		events: sink,
+	}
+	p.set(staging.PhaseContext, "")
+	return p
+}
+
+// set changes the phase and the buildpack running
+func (p *stagingProgress) set(phase staging.Phase, buildpack string) {
+	if p.end != nil && phase == p.phase && buildpack == p.buildpack {
+		return
+	}
+	if p.end != nil {
+		p.end(nil)
+	}
+	p.phase, p.buildpack = phase, buildpack
+	p.end = events.Phase(p.events, events.Event{
+		App:       p.app,
+		Phase:     string(phase),
+		Buildpack: buildpack,
+	})
+}
+
+// update tracks the phase with a line of output, it returns false for the
+// phase markers, which are not displayed
+func (p *stagingProgress) update(line string) bool {
+	switch {
+	case strings.HasPrefix(line, "#--- PHS!"):
+		fields := strings.Fields(line[9:])
+		phase, buildpack := p.phase, p.buildpack
+		if len(fields) > 0 {
+			phase = staging.Phase(fields[0])
+		}
+		if len(fields) > 2 {
+			buildpack = strings.Join(fields[2:], " ")
+		}
+		p.set(phase, buildpack)
+		return false
+	case strings.HasPrefix(line, "#--- SRT!"):
+		p.started = true
+		p.set(staging.PhaseDetect, "")
+	case strings.HasPrefix(line, "#--- END!"):
+		p.set(staging.PhaseExport, "")
+	case !p.started:
+		// Before staging the steps pull the base image or copy the context
+		if m := stepRegexp.FindStringSubmatch(line); m != nil {
+			if strings.EqualFold(m[1], "FROM") {
+				p.set(staging.PhasePull, "")
+			} else {
+				p.set(staging.PhaseContext, "")
+			}
+		}
+	}
+	p.lines = append(p.lines, line)
+	if len(p.lines) > stagingErrorLines {
+		p.lines = p.lines[len(p.lines)-stagingErrorLines:]
+	}
+	return true
+}
+
+// finish emits the end of the current phase
+func (p *stagingProgress) finish(err error) {
+	if p.end != nil {
+		p.end(err)
+		p.end = nil
+	}
+}
+
+// fail returns the staging error with the current phase for err
+func (p *stagingProgress) fail(err error) *staging.StagingError {
+	e := &staging.StagingError{
+		App:       p.app,
+		Phase:     p.phase,
+		Buildpack: p.buildpack,
+		Lines:     append([]string(nil), p.lines...),
+		Err:       err,
+	}
+	e.Hint = p.hint()
+	p.finish(err)
+	return e
+}
+
+// hint returns a suggestion to fix the error of the phase using the output
+func (p *stagingProgress) hint() string {
+	output := strings.ToLower(strings.Join(p.lines, "\n"))
+	switch {
+	case strings.Contains(output, "no space left on device"):
+		return "the Docker server is out of disk space, remove unused images with 'docker system prune'"
+	case strings.Contains(output, "no buildpack detected"):
+		return "no buildpack detected, define the buildpacks of the application in the manifest or check the application files"
+	case p.phase == staging.PhaseDetect && strings.Contains(output, "unknown buildpack"):
+		return "the buildpack is not known, use a git repository, a zip file or one of the names of 'kubefoundry buildpacks list'"
+	case p.phase == staging.PhaseDetect && strings.Contains(output, "downloading buildpack"):
+		return "unable to download the buildpack, check the network access or vendor it with 'kubefoundry buildpacks vendor'"
+	}
+	switch p.phase {
+	case staging.PhaseContext:
+		return "check the application path and the .cfignore patterns"
+	case staging.PhasePull:
+		return "check the base image name and the credentials of the registry"
+	case staging.PhaseDetect:
+		return "check the buildpacks of the application in the manifest"
+	case staging.PhaseSupply, staging.PhaseCompile, staging.PhaseFinalize:
+		return fmt.Sprintf("buildpack '%s' could not build the application, check its output and the versions required by the application", p.buildpack)
+	case staging.PhaseExport:
+		return "the application was staged but the image could not be created, check the start command in the manifest"
+	}
+	return ""
+}
+
+// stagingFailed returns the staging error of a phase without build output
+// and displays its summary
+func (ac *DockerAppContainerImage) stagingFailed(phase staging.Phase, err error) *staging.StagingError {
+	p := &stagingProgress{
+		app:   ac.appData.Name,
+		phase: phase,
+	}
+	stagingErr := p.fail(err)
+	fmt.Fprint(ac.output, stagingErr.Summary())
+	return stagingErr
+}
+
+// layerProgress emits the progress of the layers pulled or pushed, when
+// their status changes or every 10% of their size
+type layerProgress struct {
+	app     string
+	events  events.Sink
+	status  map[string]string
+	percent map[string]int64
+}
+
+func newLayerProgress(app string, sink events.Sink) *layerProgress {
+	return &layerProgress{
+		app:     app,
+		events:  sink,
+		status:  make(map[string]string),
+		percent: make(map[string]int64),
+	}
+}
+
+func (l *layerProgress) update(jm jsonmessage.JSONMessage) {
+	if jm.ID == "" {
+		return
+	}
+	var current, total, percent int64
+	if jm.Progress != nil {
+		current, total = jm.Progress.Current, jm.Progress.Total
+		if total > 0 {
+			percent = current * 100 / total / 10 * 10
+		}
+	}
+	if l.status[jm.ID] == jm.Status && percent <= l.percent[jm.ID] {
+		return
+	}
+	l.status[jm.ID] = jm.Status
+	l.percent[jm.ID] = percent
+	l.events.Emit(events.Event{
+		Type:    events.LayerProgress,
+		App:     l.app,
+		Layer:   jm.ID,
+		Status:  jm.Status,
+		Current: current,
+		Total:   total,
+	})
+}
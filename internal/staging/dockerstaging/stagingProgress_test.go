@@ -0,0 +1,181 @@
+package dockerstaging
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	events "kubefoundry/internal/events"
+	staging "kubefoundry/internal/staging"
+)
+
+// recorder is a sink which keeps the events
+type recorder struct {
+	events []events.Event
+}
+
+func (r *recorder) Emit(e events.Event) {
+	r.events = append(r.events, e)
+}
+
+// phases returns the phases started, with the buildpack if any
+func (r *recorder) phases() (phases []string) {
+	for _, e := range r.events {
+		if e.Type == events.PhaseStart {
+			phases = append(phases, strings.TrimSuffix(e.Phase+"/"+e.Buildpack, "/"))
+		}
+	}
+	return
+}
+
+func TestStagingProgress(t *testing.T) {
+	many := []string{"#--- SRT!", "#--- PHS! compile #0 java_buildpack"}
+	for i := 0; i < 30; i++ {
+		many = append(many, fmt.Sprintf("line %d", i))
+	}
+	cases := []struct {
+		name      string
+		output    []string
+		phase     staging.Phase
+		buildpack string
+		phases    []string
+		first     string
+		last      string
+		hint      string
+	}{
+		{
+			name:   "base image",
+			output: []string{"Step 1/6 : FROM cloudfoundry/cflinuxfs3", "pull access denied"},
+			phase:  staging.PhasePull,
+			phases: []string{"context", "pull"},
+			first:  "Step 1/6 : FROM cloudfoundry/cflinuxfs3",
+			last:   "pull access denied",
+			hint:   "check the base image name",
+		},
+		{
+			name:   "build context",
+			output: []string{"Step 1/6 : FROM cloudfoundry/cflinuxfs3", "Step 2/6 : COPY app /app", "COPY failed: file not found"},
+			phase:  staging.PhaseContext,
+			phases: []string{"context", "pull", "context"},
+			first:  "Step 1/6 : FROM cloudfoundry/cflinuxfs3",
+			last:   "COPY failed: file not found",
+			hint:   ".cfignore",
+		},
+		{
+			name:   "no buildpack detected",
+			output: []string{"Step 5/6 : RUN staging.py", "#--- SRT!", "ERROR: No buildpack detected"},
+			phase:  staging.PhaseDetect,
+			phases: []string{"context", "detect"},
+			first:  "Step 5/6 : RUN staging.py",
+			last:   "ERROR: No buildpack detected",
+			hint:   "no buildpack detected",
+		},
+		{
+			name:   "buildpack download",
+			output: []string{"#--- SRT!", "Downloading buildpack https://example.com/bp.zip", "connection refused"},
+			phase:  staging.PhaseDetect,
+			phases: []string{"context", "detect"},
+			first:  "#--- SRT!",
+			last:   "connection refused",
+			hint:   "vendor it",
+		},
+		{
+			name: "supply",
+			output: []string{
+				"#--- SRT!",
+				"#--- PHS! supply #0 nodejs_buildpack",
+				"-----> Installing node 99.0.0",
+				"#--- PHS! supply #0 nodejs_buildpack",
+				"**ERROR** Unable to install node: no match found for 99.0.0",
+			},
+			phase:     staging.PhaseSupply,
+			buildpack: "nodejs_buildpack",
+			phases:    []string{"context", "detect", "supply/nodejs_buildpack"},
+			first:     "#--- SRT!",
+			last:      "**ERROR** Unable to install node: no match found for 99.0.0",
+			hint:      "buildpack 'nodejs_buildpack' could not build",
+		},
+		{
+			name: "finalize of the last buildpack",
+			output: []string{
+				"#--- SRT!",
+				"#--- PHS! supply #0 nodejs_buildpack",
+				"#--- PHS! finalize #1 python buildpack",
+				"error",
+			},
+			phase:     staging.PhaseFinalize,
+			buildpack: "python buildpack",
+			phases:    []string{"context", "detect", "supply/nodejs_buildpack", "finalize/python buildpack"},
+			first:     "#--- SRT!",
+			last:      "error",
+			hint:      "buildpack 'python buildpack' could not build",
+		},
+		{
+			name:      "out of disk",
+			output:    []string{"#--- SRT!", "#--- PHS! compile #0 java_buildpack", "write /tmp/app.jar: no space left on device"},
+			phase:     staging.PhaseCompile,
+			buildpack: "java_buildpack",
+			phases:    []string{"context", "detect", "compile/java_buildpack"},
+			first:     "#--- SRT!",
+			last:      "write /tmp/app.jar: no space left on device",
+			hint:      "out of disk space",
+		},
+		{
+			name:   "export",
+			output: []string{"#--- SRT!", "#--- PHS! compile #0 go_buildpack", "#--- END!", "start command not found"},
+			phase:  staging.PhaseExport,
+			phases: []string{"context", "detect", "compile/go_buildpack", "export"},
+			first:  "#--- SRT!",
+			last:   "start command not found",
+			hint:   "start command",
+		},
+		{
+			name:      "last lines",
+			output:    many,
+			phase:     staging.PhaseCompile,
+			buildpack: "java_buildpack",
+			phases:    []string{"context", "detect", "compile/java_buildpack"},
+			first:     "line 10",
+			last:      "line 29",
+			hint:      "buildpack 'java_buildpack' could not build",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			sink := &recorder{}
+			p := newStagingProgress("app", sink)
+			for _, line := range c.output {
+				display := p.update(line)
+				if marker := strings.HasPrefix(line, "#--- PHS!"); display == marker {
+					t.Errorf("Line '%s' displayed: %v", line, display)
+				}
+			}
+			err := p.fail(errors.New("build failed"))
+			if err.App != "app" || err.Phase != c.phase || err.Buildpack != c.buildpack {
+				t.Errorf("Expected failure of app in %s/%s, got %s in %s/%s", c.phase, c.buildpack, err.App, err.Phase, err.Buildpack)
+			}
+			if len(err.Lines) == 0 || len(err.Lines) > stagingErrorLines {
+				t.Fatalf("Expected up to %d lines, got %d", stagingErrorLines, len(err.Lines))
+			}
+			if err.Lines[0] != c.first || err.Lines[len(err.Lines)-1] != c.last {
+				t.Errorf("Expected lines from '%s' to '%s', got %v", c.first, c.last, err.Lines)
+			}
+			for _, line := range err.Lines {
+				if strings.HasPrefix(line, "#--- PHS!") {
+					t.Errorf("Phase marker in the lines of the error: %s", line)
+				}
+			}
+			if !strings.Contains(err.Hint, c.hint) {
+				t.Errorf("Expected hint with '%s', got '%s'", c.hint, err.Hint)
+			}
+			if phases := sink.phases(); strings.Join(phases, ",") != strings.Join(c.phases, ",") {
+				t.Errorf("Expected phases %v, got %v", c.phases, phases)
+			}
+			end := sink.events[len(sink.events)-1]
+			if end.Type != events.PhaseEnd || end.Status != events.StatusFailure || end.Phase != string(c.phase) {
+				t.Errorf("Expected failure of phase %s as last event, got %+v", c.phase, end)
+			}
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"io"
 
 	config "kubefoundry/internal/config"
+	events "kubefoundry/internal/events"
 	log "kubefoundry/internal/log"
 	manifest "kubefoundry/internal/manifests"
 )
@@ -15,7 +16,27 @@ type AppStaging interface {
 	Finish(ctx context.Context, appPackages []AppPackage) error
 }
 
+// StackBuilder is implemented by the drivers able to build stack images,
+// base images with the buildpacks preinstalled
+type StackBuilder interface {
+	BuildStack(ctx context.Context, data *manifest.ContextData, image, base string, buildpacks []string, push bool, output io.Writer) (string, error)
+}
+
+// BuildpacksPublisher is implemented by the drivers able to push and pull
+// the vendored buildpacks as an OCI artifact
+type BuildpacksPublisher interface {
+	PushBuildpacks(ctx context.Context, image string) (string, error)
+	PullBuildpacks(ctx context.Context, image string) error
+}
+
+// EventsEmitter is implemented by the drivers able to report the progress
+// of the staging as events
+type EventsEmitter interface {
+	SetEvents(sink events.Sink)
+}
+
 type AppPackage interface {
+	Name() string
 	Build(ctx context.Context) (string, error)
 	Info(ctx context.Context) (map[string]interface{}, error)
 	Push(ctx context.Context) error
@@ -0,0 +1,68 @@
+package staging
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Phase of the staging process of an application
+type Phase string
+
+const (
+	PhaseContext  Phase = "context"
+	PhasePull     Phase = "pull"
+	PhaseDetect   Phase = "detect"
+	PhaseSupply   Phase = "supply"
+	PhaseCompile  Phase = "compile"
+	PhaseFinalize Phase = "finalize"
+	PhaseExport   Phase = "export"
+)
+
+// StagingError is returned by the drivers when the staging of an application
+// fails, with the phase and buildpack running and the last lines of output
+type StagingError struct {
+	App       string
+	Phase     Phase
+	Buildpack string
+	Lines     []string
+	Hint      string
+	Err       error
+}
+
+func (e *StagingError) Error() string {
+	msg := fmt.Sprintf("Staging of '%s' failed in %s phase", e.App, e.Phase)
+	if e.Buildpack != "" {
+		msg += fmt.Sprintf(" of buildpack '%s'", e.Buildpack)
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *StagingError) Unwrap() error {
+	return e.Err
+}
+
+// Summary returns a concise description of the failure for the users: the
+// error, the last lines of output and the hint to fix it
+func (e *StagingError) Summary() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Staging failed\n  application: %s\n  phase:       %s\n", e.App, e.Phase)
+	if e.Buildpack != "" {
+		fmt.Fprintf(&b, "  buildpack:   %s\n", e.Buildpack)
+	}
+	if e.Err != nil {
+		fmt.Fprintf(&b, "  error:       %s\n", e.Err.Error())
+	}
+	if len(e.Lines) > 0 {
+		fmt.Fprintf(&b, "  last %d lines of output:\n", len(e.Lines))
+		for _, line := range e.Lines {
+			fmt.Fprintf(&b, "    %s\n", line)
+		}
+	}
+	if e.Hint != "" {
+		fmt.Fprintf(&b, "  hint: %s\n", e.Hint)
+	}
+	return b.String()
+}
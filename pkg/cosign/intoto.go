@@ -0,0 +1,91 @@
+package cosign
+
+import (
+	"time"
+)
+
+const (
+	InTotoStatementType     = "https://in-toto.io/Statement/v0.1"
+	SLSAProvenancePredicate = "https://slsa.dev/provenance/v0.2"
+)
+
+// Subject of an in-toto statement
+type Subject struct {
+	Name   string            `json:"name"`
+	Digest map[string]string `json:"digest"`
+}
+
+// Statement is an in-toto attestation statement
+type Statement struct {
+	Type          string      `json:"_type"`
+	PredicateType string      `json:"predicateType"`
+	Subject       []Subject   `json:"subject"`
+	Predicate     interface{} `json:"predicate"`
+}
+
+// Material used to build an artifact
+type Material struct {
+	URI    string            `json:"uri"`
+	Digest map[string]string `json:"digest,omitempty"`
+}
+
+// ConfigSource is where the build definition comes from
+type ConfigSource struct {
+	URI        string            `json:"uri,omitempty"`
+	Digest     map[string]string `json:"digest,omitempty"`
+	EntryPoint string            `json:"entryPoint,omitempty"`
+}
+
+// Invocation of the build
+type Invocation struct {
+	ConfigSource ConfigSource      `json:"configSource"`
+	Parameters   map[string]string `json:"parameters,omitempty"`
+	Environment  map[string]string `json:"environment,omitempty"`
+}
+
+// Builder is the entity which runs the build
+type Builder struct {
+	ID string `json:"id"`
+}
+
+// Completeness of the provenance metadata
+type Completeness struct {
+	Parameters  bool `json:"parameters"`
+	Environment bool `json:"environment"`
+	Materials   bool `json:"materials"`
+}
+
+// Metadata of the build
+type Metadata struct {
+	BuildInvocationID string       `json:"buildInvocationId,omitempty"`
+	BuildStartedOn    *time.Time   `json:"buildStartedOn,omitempty"`
+	BuildFinishedOn   *time.Time   `json:"buildFinishedOn,omitempty"`
+	Completeness      Completeness `json:"completeness"`
+	Reproducible      bool         `json:"reproducible"`
+}
+
+// Provenance is the SLSA provenance v0.2 predicate
+type Provenance struct {
+	Builder    Builder    `json:"builder"`
+	BuildType  string     `json:"buildType"`
+	Invocation Invocation `json:"invocation"`
+	Metadata   *Metadata  `json:"metadata,omitempty"`
+	Materials  []Material `json:"materials,omitempty"`
+}
+
+// NewProvenanceStatement returns an in-toto statement with the SLSA
+// provenance of an image (name without tag) and its digest (sha256:...)
+func NewProvenanceStatement(name, digest string, provenance *Provenance) *Statement {
+	algorithm, hex := splitDigest(digest)
+	return &Statement{
+		Type:          InTotoStatementType,
+		PredicateType: SLSAProvenancePredicate,
+		Subject: []Subject{
+			{
+				Name:   name,
+				Digest: map[string]string{algorithm: hex},
+			},
+		},
+		Predicate: provenance,
+	}
+}
@@ -0,0 +1,113 @@
+package cosign
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+
+	"golang.org/x/crypto/nacl/secretbox"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	// PEM types of the private keys generated by 'cosign generate-key-pair'
+	CosignPrivateKeyPemType   = "ENCRYPTED COSIGN PRIVATE KEY"
+	SigstorePrivateKeyPemType = "ENCRYPTED SIGSTORE PRIVATE KEY"
+	// Environment variable with the password of the key (same as cosign)
+	PasswordEnv = "COSIGN_PASSWORD"
+)
+
+// encryptedKey is the content of an encrypted cosign private key
+type encryptedKey struct {
+	KDF struct {
+		Name   string `json:"name"`
+		Params struct {
+			N int `json:"N"`
+			R int `json:"r"`
+			P int `json:"p"`
+		} `json:"params"`
+		Salt []byte `json:"salt"`
+	} `json:"kdf"`
+	Cipher struct {
+		Name  string `json:"name"`
+		Nonce []byte `json:"nonce"`
+	} `json:"cipher"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// LoadPrivateKey reads an ECDSA private key from a PEM file. It supports
+// encrypted cosign keys (using the password), PKCS8 and EC keys.
+func LoadPrivateKey(path string, password []byte) (*ecdsa.PrivateKey, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read signing key '%s': %s", path, err.Error())
+	}
+	block, _ := pem.Decode(content)
+	if block == nil {
+		return nil, fmt.Errorf("Invalid signing key '%s', PEM format expected", path)
+	}
+	der := block.Bytes
+	switch block.Type {
+	case CosignPrivateKeyPemType, SigstorePrivateKeyPemType:
+		if der, err = decrypt(block.Bytes, password); err != nil {
+			return nil, fmt.Errorf("Unable to decrypt signing key '%s': %s", path, err.Error())
+		}
+	case "EC PRIVATE KEY":
+		key, err := x509.ParseECPrivateKey(der)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid signing key '%s': %s", path, err.Error())
+		}
+		return key, nil
+	case "PRIVATE KEY":
+	default:
+		return nil, fmt.Errorf("Unsupported signing key type '%s' in '%s'", block.Type, path)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid signing key '%s': %s", path, err.Error())
+	}
+	ecKey, ok := key.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("Invalid signing key '%s', only ECDSA keys are supported", path)
+	}
+	return ecKey, nil
+}
+
+func decrypt(content, password []byte) ([]byte, error) {
+	ek := encryptedKey{}
+	if err := json.Unmarshal(content, &ek); err != nil {
+		return nil, err
+	}
+	if ek.KDF.Name != "scrypt" || ek.Cipher.Name != "nacl/secretbox" {
+		return nil, fmt.Errorf("Unsupported encryption %s/%s", ek.KDF.Name, ek.Cipher.Name)
+	}
+	if len(ek.Cipher.Nonce) != 24 {
+		return nil, fmt.Errorf("Invalid nonce")
+	}
+	secret, err := scrypt.Key(password, ek.KDF.Salt, ek.KDF.Params.N, ek.KDF.Params.R, ek.KDF.Params.P, 32)
+	if err != nil {
+		return nil, err
+	}
+	var key [32]byte
+	var nonce [24]byte
+	copy(key[:], secret)
+	copy(nonce[:], ek.Cipher.Nonce)
+	der, ok := secretbox.Open(nil, ek.Ciphertext, &nonce, &key)
+	if !ok {
+		return nil, fmt.Errorf("Wrong password")
+	}
+	return der, nil
+}
+
+// PublicKeyPEM returns the public key in PEM format, as cosign.pub
+func PublicKeyPEM(key crypto.Signer) ([]byte, error) {
+	der, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), nil
+}
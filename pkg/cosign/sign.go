@@ -0,0 +1,204 @@
+package cosign
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	registry "kubefoundry/pkg/registry"
+
+	digest "github.com/opencontainers/go-digest"
+	ocispecs "github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+const (
+	SimpleSigningMediaType = "application/vnd.dev.cosign.simplesigning.v1+json"
+	DSSEMediaType          = "application/vnd.dsse.envelope.v1+json"
+	InTotoPayloadType      = "application/vnd.in-toto+json"
+	SignatureAnnotation    = "dev.cosignproject.cosign/signature"
+	PredicateAnnotation    = "predicateType"
+	SignatureTagSuffix     = "sig"
+	AttestationTagSuffix   = "att"
+	SBOMTagSuffix          = "sbom"
+	SignatureType          = "cosign container image signature"
+)
+
+// SimpleSigning is the payload signed by cosign for an image
+type SimpleSigning struct {
+	Critical struct {
+		Identity struct {
+			DockerReference string `json:"docker-reference"`
+		} `json:"identity"`
+		Image struct {
+			DockerManifestDigest string `json:"docker-manifest-digest"`
+		} `json:"image"`
+		Type string `json:"type"`
+	} `json:"critical"`
+	Optional map[string]interface{} `json:"optional"`
+}
+
+// Envelope is a DSSE envelope (https://github.com/secure-systems-lab/dsse)
+type Envelope struct {
+	PayloadType string              `json:"payloadType"`
+	Payload     string              `json:"payload"`
+	Signatures  []EnvelopeSignature `json:"signatures"`
+}
+
+type EnvelopeSignature struct {
+	KeyID string `json:"keyid"`
+	Sig   string `json:"sig"`
+}
+
+// Signer signs images and stores the signatures and attestations in the
+// registry, next to the image, in the same way as cosign
+type Signer struct {
+	key    *ecdsa.PrivateKey
+	client *registry.Client
+}
+
+// NewSigner creates a signer with the key and registry client
+func NewSigner(key *ecdsa.PrivateKey, client *registry.Client) *Signer {
+	return &Signer{
+		key:    key,
+		client: client,
+	}
+}
+
+// Tag returns the tag where cosign stores the artifact (sig, att, sbom) of
+// the image digest: sha256-<hex>.<suffix>
+func Tag(dgst, suffix string) string {
+	algorithm, hex := splitDigest(dgst)
+	return fmt.Sprintf("%s-%s.%s", algorithm, hex, suffix)
+}
+
+// Sign signs the image digest and pushes the signature to the registry
+func (s *Signer) Sign(ctx context.Context, ref registry.Reference, dgst string, annotations map[string]interface{}) (sigRef registry.Reference, err error) {
+	payload := SimpleSigning{Optional: annotations}
+	payload.Critical.Identity.DockerReference = ref.Name()
+	payload.Critical.Image.DockerManifestDigest = dgst
+	payload.Critical.Type = SignatureType
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return
+	}
+	sig, err := s.sign(body)
+	if err != nil {
+		return
+	}
+	layerAnnotations := map[string]string{
+		SignatureAnnotation: base64.StdEncoding.EncodeToString(sig),
+	}
+	sigRef = ref.WithTag(Tag(dgst, SignatureTagSuffix))
+	err = Attach(ctx, s.client, sigRef, SimpleSigningMediaType, body, layerAnnotations, false)
+	return
+}
+
+// Attest signs the in-toto statement with DSSE and pushes it to the
+// registry as an attestation of the image digest
+func (s *Signer) Attest(ctx context.Context, ref registry.Reference, dgst string, statement *Statement) (attRef registry.Reference, err error) {
+	payload, err := json.Marshal(statement)
+	if err != nil {
+		return
+	}
+	sig, err := s.sign(PAE(InTotoPayloadType, payload))
+	if err != nil {
+		return
+	}
+	envelope := Envelope{
+		PayloadType: InTotoPayloadType,
+		Payload:     base64.StdEncoding.EncodeToString(payload),
+		Signatures: []EnvelopeSignature{
+			{Sig: base64.StdEncoding.EncodeToString(sig)},
+		},
+	}
+	body, err := json.Marshal(envelope)
+	if err != nil {
+		return
+	}
+	layerAnnotations := map[string]string{
+		SignatureAnnotation: "",
+		PredicateAnnotation: statement.PredicateType,
+	}
+	attRef = ref.WithTag(Tag(dgst, AttestationTagSuffix))
+	err = Attach(ctx, s.client, attRef, DSSEMediaType, body, layerAnnotations, false)
+	return
+}
+
+// Attach pushes the content as a new layer of the OCI artifact in ref,
+// keeping the layers already there (other signatures) unless replace is
+// set. It is also used to attach other artifacts to images, like SBOMs.
+func Attach(ctx context.Context, client *registry.Client, ref registry.Reference, mediaType string, content []byte, annotations map[string]string, replace bool) (err error) {
+	layer, err := client.PushBlob(ctx, ref, mediaType, content)
+	if err != nil {
+		return
+	}
+	layer.Annotations = annotations
+	manifest := ocispec.Manifest{
+		Versioned: ocispecs.Versioned{SchemaVersion: 2},
+	}
+	if !replace {
+		if _, body, errM := client.Manifest(ctx, ref); errM == nil {
+			if errJ := json.Unmarshal(body, &manifest); errJ != nil {
+				return fmt.Errorf("Unable to parse manifest '%s': %s", ref.String(), errJ.Error())
+			}
+		} else if !errors.Is(errM, registry.ErrNotFound) {
+			return errM
+		}
+	}
+	layers := []ocispec.Descriptor{}
+	for _, l := range manifest.Layers {
+		if l.Digest != layer.Digest {
+			layers = append(layers, l)
+		}
+	}
+	manifest.Layers = append(layers, layer)
+	// Image config with the list of layers
+	config := ocispec.Image{
+		RootFS: ocispec.RootFS{Type: "layers"},
+	}
+	for _, l := range manifest.Layers {
+		config.RootFS.DiffIDs = append(config.RootFS.DiffIDs, l.Digest)
+	}
+	configBody, err := json.Marshal(config)
+	if err != nil {
+		return
+	}
+	if manifest.Config, err = client.PushBlob(ctx, ref, ocispec.MediaTypeImageConfig, configBody); err != nil {
+		return
+	}
+	body, err := json.Marshal(manifest)
+	if err != nil {
+		return
+	}
+	_, err = client.PushManifest(ctx, ref, ocispec.MediaTypeImageManifest, body)
+	return
+}
+
+func (s *Signer) sign(payload []byte) ([]byte, error) {
+	hash := sha256.Sum256(payload)
+	return ecdsa.SignASN1(rand.Reader, s.key, hash[:])
+}
+
+// PAE is the DSSE pre-authentication encoding of the payload
+func PAE(payloadType string, payload []byte) []byte {
+	return []byte(fmt.Sprintf("DSSEv1 %d %s %d %s", len(payloadType), payloadType, len(payload), payload))
+}
+
+func splitDigest(dgst string) (algorithm, hex string) {
+	d := digest.Digest(dgst)
+	if err := d.Validate(); err == nil {
+		return d.Algorithm().String(), d.Encoded()
+	}
+	parts := strings.SplitN(dgst, ":", 2)
+	if len(parts) == 2 {
+		return parts[0], parts[1]
+	}
+	return "sha256", dgst
+}
@@ -0,0 +1,210 @@
+package cosign
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	registry "kubefoundry/pkg/registry"
+
+	digest "github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+const testDigest = "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+// testRegistry is an in-memory registry with anonymous access, blobs and
+// manifests are stored by repository@digest and manifests also by tag
+type testRegistry struct {
+	*httptest.Server
+	content map[string][]byte
+	mu      sync.Mutex
+}
+
+func newTestRegistry(t *testing.T) *testRegistry {
+	r := &testRegistry{content: make(map[string][]byte)}
+	r.Server = httptest.NewServer(http.HandlerFunc(r.serve))
+	t.Cleanup(r.Close)
+	return r
+}
+
+func (r *testRegistry) serve(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	path := strings.TrimPrefix(req.URL.Path, "/v2/")
+	switch {
+	case strings.HasSuffix(path, "/blobs/uploads/") && req.Method == http.MethodPost:
+		w.Header().Set("Location", "/v2/"+path+"1")
+		w.WriteHeader(http.StatusAccepted)
+	case strings.Contains(path, "/blobs/uploads/") && req.Method == http.MethodPut:
+		body, _ := ioutil.ReadAll(req.Body)
+		repo := path[:strings.Index(path, "/blobs/")]
+		r.content[repo+"@"+req.URL.Query().Get("digest")] = body
+		w.WriteHeader(http.StatusCreated)
+	case strings.Contains(path, "/manifests/") && req.Method == http.MethodPut:
+		body, _ := ioutil.ReadAll(req.Body)
+		i := strings.Index(path, "/manifests/")
+		r.content[path[:i]+"@"+path[i+len("/manifests/"):]] = body
+		r.content[path[:i]+"@"+digest.FromBytes(body).String()] = body
+		w.WriteHeader(http.StatusCreated)
+	default:
+		path = strings.Replace(strings.Replace(path, "/blobs/", "@", 1), "/manifests/", "@", 1)
+		body, ok := r.content[path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", ocispec.MediaTypeImageManifest)
+		w.Write(body)
+	}
+}
+
+// artifact returns the layers of the artifact with the tag and their content
+func (r *testRegistry) artifact(t *testing.T, repo, tag string) (layers []ocispec.Descriptor, contents [][]byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	body, ok := r.content[repo+"@"+tag]
+	if !ok {
+		t.Fatalf("Artifact '%s:%s' not found in the registry", repo, tag)
+	}
+	manifest := ocispec.Manifest{}
+	if err := json.Unmarshal(body, &manifest); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok = r.content[repo+"@"+manifest.Config.Digest.String()]; !ok {
+		t.Errorf("Config of artifact '%s:%s' not found in the registry", repo, tag)
+	}
+	for _, l := range manifest.Layers {
+		content, ok := r.content[repo+"@"+l.Digest.String()]
+		if !ok {
+			t.Fatalf("Layer '%s' of artifact '%s:%s' not found in the registry", l.Digest, repo, tag)
+		}
+		contents = append(contents, content)
+	}
+	return manifest.Layers, contents
+}
+
+func testSigner(t *testing.T) (*Signer, *testRegistry, registry.Reference) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := newTestRegistry(t)
+	ref, err := registry.ParseReference(strings.TrimPrefix(r.URL, "http://") + "/team/app:1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewSigner(key, registry.New(nil)), r, ref
+}
+
+func verify(t *testing.T, key *ecdsa.PrivateKey, payload []byte, sig string) {
+	raw, err := base64.StdEncoding.DecodeString(sig)
+	if err != nil {
+		t.Fatalf("Invalid signature encoding: %s", err.Error())
+	}
+	hash := sha256.Sum256(payload)
+	if !ecdsa.VerifyASN1(&key.PublicKey, hash[:], raw) {
+		t.Errorf("Signature does not verify with the public key")
+	}
+}
+
+func TestSign(t *testing.T) {
+	s, r, ref := testSigner(t)
+	ctx := context.Background()
+	sigRef, err := s.Sign(ctx, ref, testDigest, map[string]interface{}{"commit": "abc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tag := "sha256-0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef.sig"
+	if sigRef.Tag != tag || sigRef.Repository != "team/app" {
+		t.Errorf("Expected signature in 'team/app:%s', got '%s'", tag, sigRef.String())
+	}
+	layers, contents := r.artifact(t, "team/app", tag)
+	if len(layers) != 1 || layers[0].MediaType != SimpleSigningMediaType {
+		t.Fatalf("Expected one simple signing layer, got %+v", layers)
+	}
+	verify(t, s.key, contents[0], layers[0].Annotations[SignatureAnnotation])
+	payload := SimpleSigning{}
+	if err = json.Unmarshal(contents[0], &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload.Critical.Image.DockerManifestDigest != testDigest || payload.Critical.Identity.DockerReference != ref.Name() {
+		t.Errorf("Unexpected signed payload %+v", payload.Critical)
+	}
+	if payload.Critical.Type != SignatureType || payload.Optional["commit"] != "abc" {
+		t.Errorf("Unexpected type or annotations in payload %+v", payload)
+	}
+	// Other signatures are kept
+	if _, err = s.Sign(ctx, ref, testDigest, map[string]interface{}{"commit": "def"}); err != nil {
+		t.Fatal(err)
+	}
+	if layers, _ = r.artifact(t, "team/app", tag); len(layers) != 2 {
+		t.Errorf("Expected two signatures, got %d", len(layers))
+	}
+}
+
+func TestAttest(t *testing.T) {
+	s, r, ref := testSigner(t)
+	provenance := &Provenance{
+		Builder:   Builder{ID: "https://example.com/builder"},
+		BuildType: "https://example.com/build",
+		Materials: []Material{
+			{URI: "git+https://example.com/app.git", Digest: map[string]string{"sha1": "abc"}},
+			{URI: "pkg:docker/cloudfoundry/cflinuxfs3", Digest: map[string]string{"sha256": "def"}},
+		},
+	}
+	attRef, err := s.Attest(context.Background(), ref, testDigest, NewProvenanceStatement(ref.Name(), testDigest, provenance))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tag := "sha256-0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef.att"
+	if attRef.Tag != tag {
+		t.Errorf("Expected attestation with tag '%s', got '%s'", tag, attRef.Tag)
+	}
+	layers, contents := r.artifact(t, "team/app", tag)
+	if len(layers) != 1 || layers[0].MediaType != DSSEMediaType {
+		t.Fatalf("Expected one DSSE layer, got %+v", layers)
+	}
+	if layers[0].Annotations[PredicateAnnotation] != SLSAProvenancePredicate {
+		t.Errorf("Expected predicate type annotation '%s', got %v", SLSAProvenancePredicate, layers[0].Annotations)
+	}
+	envelope := Envelope{}
+	if err = json.Unmarshal(contents[0], &envelope); err != nil {
+		t.Fatal(err)
+	}
+	if envelope.PayloadType != InTotoPayloadType || len(envelope.Signatures) != 1 {
+		t.Fatalf("Unexpected envelope %+v", envelope)
+	}
+	payload, err := base64.StdEncoding.DecodeString(envelope.Payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify(t, s.key, PAE(InTotoPayloadType, payload), envelope.Signatures[0].Sig)
+	statement := struct {
+		Statement
+		Predicate Provenance `json:"predicate"`
+	}{}
+	if err = json.Unmarshal(payload, &statement); err != nil {
+		t.Fatal(err)
+	}
+	if statement.Type != InTotoStatementType || statement.PredicateType != SLSAProvenancePredicate {
+		t.Errorf("Unexpected statement type %s (%s)", statement.Type, statement.PredicateType)
+	}
+	if len(statement.Subject) != 1 || statement.Subject[0].Name != ref.Name() || statement.Subject[0].Digest["sha256"] != testDigest[7:] {
+		t.Errorf("Unexpected subject %+v", statement.Subject)
+	}
+	materials := statement.Predicate.Materials
+	if len(materials) != 2 || materials[0].URI != provenance.Materials[0].URI || materials[1].Digest["sha256"] != "def" {
+		t.Errorf("Unexpected materials %+v", materials)
+	}
+}
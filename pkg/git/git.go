@@ -4,10 +4,13 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strings"
 
 	"github.com/go-git/go-billy/v5/osfs"
 	git "github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/client"
 	"github.com/go-git/go-git/v5/storage/memory"
 )
 
@@ -32,6 +35,8 @@ func NewGitRepo(local string, memory bool) (*GitRepo, error) {
 			memory: memory,
 		}
 		return &g, nil
+	} else if err == nil {
+		err = fmt.Errorf("Directory '%s' is not empty", local)
 	}
 	return nil, err
 }
@@ -48,6 +53,9 @@ func (g *GitRepo) Clone(remote, reference string, recursive bool) (err error) {
 		options.RecurseSubmodules = git.DefaultSubmoduleRecursionDepth
 	}
 	switch {
+	case reference == "":
+		// Default branch of the remote
+		options.ReferenceName = plumbing.HEAD
 	case ref.IsBranch():
 		options.ReferenceName = ref
 		options.SingleBranch = true
@@ -74,6 +82,88 @@ func (g *GitRepo) Clone(remote, reference string, recursive bool) (err error) {
 	return err
 }
 
+// Checkout changes the worktree to the commit, updating the submodules if
+// recursive is true
+func (g *GitRepo) Checkout(commit string, recursive bool) error {
+	if g.repo == nil {
+		return fmt.Errorf("Repository not cloned")
+	}
+	worktree, err := g.repo.Worktree()
+	if err != nil {
+		return err
+	}
+	options := git.CheckoutOptions{
+		Hash:  plumbing.NewHash(commit),
+		Force: true,
+	}
+	if err = worktree.Checkout(&options); err != nil || !recursive {
+		return err
+	}
+	submodules, err := worktree.Submodules()
+	if err != nil {
+		return err
+	}
+	return submodules.Update(&git.SubmoduleUpdateOptions{
+		Init:              true,
+		RecurseSubmodules: git.DefaultSubmoduleRecursionDepth,
+	})
+}
+
+// ResolveReference returns the commit of the reference (a tag, a branch or
+// the default branch if empty) in the remote repository, without cloning it
+func ResolveReference(remote, reference string) (commit string, err error) {
+	endpoint, err := transport.NewEndpoint(remote)
+	if err != nil {
+		return
+	}
+	c, err := client.NewClient(endpoint)
+	if err != nil {
+		return
+	}
+	session, err := c.NewUploadPackSession(endpoint, nil)
+	if err != nil {
+		return
+	}
+	defer session.Close()
+	refs, err := session.AdvertisedReferences()
+	if err != nil {
+		return
+	}
+	names := []string{"refs/tags/" + reference, "refs/heads/" + reference}
+	switch {
+	case reference == "":
+		if refs.Head != nil {
+			return refs.Head.String(), nil
+		}
+		names = []string{}
+	case strings.HasPrefix(reference, "refs/"):
+		names = []string{reference}
+	}
+	for _, name := range names {
+		// Annotated tags point to the tag object
+		if hash, ok := refs.Peeled[name]; ok {
+			return hash.String(), nil
+		}
+		if hash, ok := refs.References[name]; ok {
+			return hash.String(), nil
+		}
+	}
+	err = fmt.Errorf("Reference '%s' not found in '%s'", reference, remote)
+	return
+}
+
+// Commit returns the hash of the commit checked out
+func (g *GitRepo) Commit() (string, error) {
+	if g.repo == nil {
+		return "", fmt.Errorf("Repository not cloned")
+	}
+	head, err := g.repo.Head()
+	if err != nil {
+		return "", err
+	}
+	return head.Hash().String(), nil
+}
+
 func (g *GitRepo) Delete() error {
 	// TODO
 	return nil
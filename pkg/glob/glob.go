@@ -17,7 +17,17 @@ type Glob struct {
 // into a *Glob object (which is really just a regular expression)
 // Compile also returns a possible error.
 func New(pattern string) (*Glob, error) {
-	r, err := globToRegex(pattern)
+	r, err := regexp.Compile("^" + globToRegex(pattern, false) + "$")
+	return &Glob{
+		Regexp:  r,
+		Pattern: pattern,
+	}, err
+}
+
+// NewPath is like New but the glob expression is applied to paths: '*' and
+// '?' do not match the separator '/' and '**' matches any number of folders.
+func NewPath(pattern string) (*Glob, error) {
+	r, err := regexp.Compile("^" + globToRegex(pattern, true) + "$")
 	return &Glob{
 		Regexp:  r,
 		Pattern: pattern,
@@ -32,7 +42,7 @@ func (g *Glob) GetRegexp() string {
 	return g.Regexp.String()
 }
 
-func globToRegex(glob string) (*regexp.Regexp, error) {
+func globToRegex(glob string, path bool) string {
 	regex := ""
 	inGroup := 0
 	inClass := 0
@@ -58,16 +68,29 @@ func globToRegex(glob string) (*regexp.Regexp, error) {
 				regex += string(next)
 			}
 		case '*':
-			if inClass == 0 {
-				regex += ".*"
-			} else {
+			if inClass != 0 {
 				regex += "*"
+			} else if i+1 < len(arr) && arr[i+1] == '*' {
+				// '**' matches everything, '**/' also zero folders
+				i++
+				if path && i+1 < len(arr) && arr[i+1] == '/' {
+					i++
+					regex += "(.*/)?"
+				} else {
+					regex += ".*"
+				}
+			} else if path {
+				regex += "[^/]*"
+			} else {
+				regex += ".*"
 			}
 		case '?':
-			if inClass == 0 {
-				regex += "."
-			} else {
+			if inClass != 0 {
 				regex += "?"
+			} else if path {
+				regex += "[^/]"
+			} else {
+				regex += "."
 			}
 		case '[':
 			inClass++
@@ -103,5 +126,5 @@ func globToRegex(glob string) (*regexp.Regexp, error) {
 			regex += string(ch)
 		}
 	}
-	return regexp.Compile("^" + regex + "$")
+	return regex
 }
@@ -0,0 +1,127 @@
+package glob
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// pattern is a path glob which is part of a List
+type pattern struct {
+	*Glob
+	negate  bool
+	dirOnly bool
+}
+
+// List is an ordered set of path glob patterns with the same semantics as
+// .gitignore or .cfignore files:
+//   - A leading '!' negates the pattern, re-including paths excluded before.
+//   - A trailing '/' only matches directories.
+//   - Patterns without '/' match the name of the file/folder at any level,
+//     otherwise they are relative to the root of the list.
+//   - '*' and '?' do not match '/', '**' matches any number of folders.
+//
+// The last matching pattern decides the result.
+type List struct {
+	patterns []*pattern
+}
+
+// NewList creates a List with the patterns provided
+func NewList(patterns ...string) (*List, error) {
+	l := &List{}
+	for _, p := range patterns {
+		if err := l.Add(p); err != nil {
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+// ReadList creates a List from a reader with one pattern per line, blank
+// lines and lines starting with '#' are skipped
+func ReadList(r io.Reader) (*List, error) {
+	l := &List{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if err := l.Add(line); err != nil {
+			return nil, err
+		}
+	}
+	return l, scanner.Err()
+}
+
+// Add appends a new pattern to the list
+func (l *List) Add(p string) error {
+	entry := &pattern{}
+	expr := strings.TrimSpace(p)
+	if strings.HasPrefix(expr, "!") {
+		entry.negate = true
+		expr = expr[1:]
+	}
+	if strings.HasSuffix(expr, "/") {
+		entry.dirOnly = true
+		expr = strings.TrimRight(expr, "/")
+	}
+	// Remove "./" and leading "/", they only mean anchored to root
+	anchored := strings.Contains(expr, "/")
+	expr = strings.TrimPrefix(strings.TrimPrefix(expr, "./"), "/")
+	if expr == "" {
+		return fmt.Errorf("Invalid empty glob pattern '%s'", p)
+	}
+	regex := globToRegex(expr, true)
+	if !anchored {
+		regex = "(.*/)?" + regex
+	}
+	r, err := regexp.Compile("^" + regex + "$")
+	if err != nil {
+		return fmt.Errorf("Invalid glob pattern '%s', %s", p, err.Error())
+	}
+	entry.Glob = &Glob{
+		Regexp:  r,
+		Pattern: p,
+	}
+	l.patterns = append(l.patterns, entry)
+	return nil
+}
+
+// Append adds all patterns of other list after the current ones
+func (l *List) Append(other *List) {
+	if other != nil {
+		l.patterns = append(l.patterns, other.patterns...)
+	}
+}
+
+// Match returns true if the path (relative to the root of the list and
+// using '/' as separator) is matched by the list of patterns
+func (l *List) Match(path string, isDir bool) bool {
+	matched := false
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "./"), "/")
+	for _, p := range l.patterns {
+		if p.dirOnly && !isDir {
+			continue
+		}
+		if p.negate == matched && p.MatchString(path) {
+			matched = !p.negate
+		}
+	}
+	return matched
+}
+
+// Len returns the number of patterns
+func (l *List) Len() int {
+	return len(l.patterns)
+}
+
+func (l *List) String() string {
+	patterns := []string{}
+	for _, p := range l.patterns {
+		patterns = append(patterns, p.Pattern)
+	}
+	return strings.Join(patterns, ", ")
+}
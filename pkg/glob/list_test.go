@@ -0,0 +1,98 @@
+package glob
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestListMatch(t *testing.T) {
+	cases := []struct {
+		name     string
+		patterns []string
+		path     string
+		dir      bool
+		match    bool
+	}{
+		{name: "name at root", patterns: []string{"*.log"}, path: "app.log", match: true},
+		{name: "name at any level", patterns: []string{"*.log"}, path: "logs/2024/app.log", match: true},
+		{name: "star does not match separator", patterns: []string{"logs/*.log"}, path: "logs/2024/app.log"},
+		{name: "star in folder", patterns: []string{"logs/*.log"}, path: "logs/app.log", match: true},
+		{name: "question mark", patterns: []string{"app?.log"}, path: "app1.log", match: true},
+		{name: "question mark does not match separator", patterns: []string{"app?log"}, path: "app/log"},
+		{name: "double star folders", patterns: []string{"**/tmp"}, path: "a/b/tmp", match: true},
+		{name: "double star zero folders", patterns: []string{"**/tmp"}, path: "tmp", match: true},
+		{name: "double star in the middle", patterns: []string{"src/**/test"}, path: "src/a/b/test", match: true},
+		{name: "double star in the middle without folders", patterns: []string{"src/**/test"}, path: "src/test", match: true},
+		{name: "trailing double star", patterns: []string{"build/**"}, path: "build/a/b.o", match: true},
+		{name: "leading slash anchors", patterns: []string{"/config.yml"}, path: "config.yml", match: true},
+		{name: "leading slash not nested", patterns: []string{"/config.yml"}, path: "app/config.yml"},
+		{name: "slash in the middle anchors", patterns: []string{"app/config.yml"}, path: "src/app/config.yml"},
+		{name: "leading dot slash anchors", patterns: []string{"./config.yml"}, path: "config.yml", match: true},
+		{name: "dir only matches folders", patterns: []string{"node_modules/"}, path: "node_modules", dir: true, match: true},
+		{name: "dir only nested folders", patterns: []string{"node_modules/"}, path: "web/node_modules", dir: true, match: true},
+		{name: "dir only skips files", patterns: []string{"node_modules/"}, path: "node_modules"},
+		{name: "negation re-includes", patterns: []string{"*.log", "!keep.log"}, path: "keep.log"},
+		{name: "negation other files", patterns: []string{"*.log", "!keep.log"}, path: "other.log", match: true},
+		{name: "last pattern wins", patterns: []string{"*.log", "!keep.log", "keep.*"}, path: "keep.log", match: true},
+		{name: "negation without match", patterns: []string{"!keep.log"}, path: "keep.log"},
+		{name: "negated dir only", patterns: []string{"vendor", "!vendor/"}, path: "vendor", dir: true},
+		{name: "negated dir only skips files", patterns: []string{"vendor", "!vendor/"}, path: "vendor", match: true},
+		{name: "path with leading slash", patterns: []string{"/tmp"}, path: "/tmp", dir: true, match: true},
+		{name: "braces", patterns: []string{"*.{jpg,png}"}, path: "img/logo.png", match: true},
+		{name: "class", patterns: []string{"[abc].txt"}, path: "b.txt", match: true},
+		{name: "negated class", patterns: []string{"[!abc].txt"}, path: "b.txt"},
+		{name: "escaped star", patterns: []string{`\*.txt`}, path: "a.txt"},
+		{name: "no patterns", path: "app.py"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			l, err := NewList(c.patterns...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if match := l.Match(c.path, c.dir); match != c.match {
+				t.Errorf("Expected match %v of '%s' (dir %v) with %v, got %v", c.match, c.path, c.dir, c.patterns, match)
+			}
+		})
+	}
+}
+
+func TestListInvalid(t *testing.T) {
+	for _, p := range []string{"", "/", "!", "!/", "[a"} {
+		if err := (&List{}).Add(p); err == nil {
+			t.Errorf("Expected error with pattern '%s'", p)
+		}
+	}
+}
+
+func TestReadList(t *testing.T) {
+	l, err := ReadList(strings.NewReader("# comment\n\n*.log  \r\n!keep.log\ntmp/\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.Len() != 3 || l.String() != "*.log, !keep.log, tmp/" {
+		t.Fatalf("Unexpected patterns %s", l.String())
+	}
+	other, _ := NewList("*.tmp")
+	l.Append(other)
+	if !l.Match("a/b.tmp", false) || l.Match("keep.log", false) || !l.Match("tmp", true) {
+		t.Errorf("Unexpected matches of %s", l.String())
+	}
+}
+
+func TestNewPath(t *testing.T) {
+	g, err := NewPath("src/*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !g.MatchString("src/main.go") || g.MatchString("src/pkg/main.go") {
+		t.Errorf("Unexpected matches of path glob %s (%s)", g, g.GetRegexp())
+	}
+	g, err = New("src/*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !g.MatchString("src/pkg/main.go") {
+		t.Errorf("Expected match of nested file with glob %s (%s)", g, g.GetRegexp())
+	}
+}
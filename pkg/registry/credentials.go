@@ -0,0 +1,245 @@
+package registry
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DockerHubServer is the address used by Docker to store Docker Hub credentials
+	DockerHubServer = "https://index.docker.io/v1/"
+	// DockerConfigEnv is the environment variable with the Docker config folder
+	DockerConfigEnv = "DOCKER_CONFIG"
+	// Username used by credential helpers to return identity tokens
+	identityTokenUsername = "<token>"
+	// Time limit to run credential helpers
+	credentialHelperTimeout = 30 * time.Second
+)
+
+// Credential to authenticate with a registry
+type Credential struct {
+	Username      string
+	Password      string
+	IdentityToken string
+}
+
+// Empty returns true if there are no credentials (anonymous access)
+func (c Credential) Empty() bool {
+	return c.Username == "" && c.Password == "" && c.IdentityToken == ""
+}
+
+// DockerAuth is an entry of the 'auths' section of the Docker config
+type DockerAuth struct {
+	Auth          string `json:"auth,omitempty"`
+	Username      string `json:"username,omitempty"`
+	Password      string `json:"password,omitempty"`
+	IdentityToken string `json:"identitytoken,omitempty"`
+}
+
+// DockerConfig is the Docker client configuration file (config.json)
+type DockerConfig struct {
+	Auths       map[string]DockerAuth `json:"auths"`
+	CredHelpers map[string]string     `json:"credHelpers,omitempty"`
+	CredsStore  string                `json:"credsStore,omitempty"`
+	path        string
+}
+
+// DockerConfigDir returns the folder of the Docker client configuration
+func DockerConfigDir() string {
+	if dir := os.Getenv(DockerConfigEnv); dir != "" {
+		return dir
+	}
+	home, err := os.UserHomeDir()
+	if err != nil || home == "" {
+		if usr, errU := user.Current(); errU == nil {
+			home = usr.HomeDir
+		}
+	}
+	return filepath.Join(home, ".docker")
+}
+
+// LoadDockerConfig reads the Docker client configuration file. If path is
+// empty, it uses config.json in DockerConfigDir. A missing file is not an
+// error, it returns an empty configuration.
+func LoadDockerConfig(path string) (*DockerConfig, error) {
+	if path == "" {
+		path = filepath.Join(DockerConfigDir(), "config.json")
+	} else if fi, err := os.Stat(path); err == nil && fi.IsDir() {
+		path = filepath.Join(path, "config.json")
+	}
+	config := &DockerConfig{
+		Auths: make(map[string]DockerAuth),
+		path:  path,
+	}
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return config, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("Unable to read Docker config '%s': %s", path, err.Error())
+	}
+	if err = json.Unmarshal(content, config); err != nil {
+		return nil, fmt.Errorf("Unable to parse Docker config '%s': %s", path, err.Error())
+	}
+	return config, nil
+}
+
+// Credentials returns the credentials of the registry host, from the
+// credential helper of the registry, the credentials store or the auths.
+func (c *DockerConfig) Credentials(host string) (cred Credential, err error) {
+	host = RegistryHost(host)
+	for server, helper := range c.CredHelpers {
+		if RegistryHost(server) == host {
+			return credentialHelper(helper, server)
+		}
+	}
+	for server, auth := range c.Auths {
+		if RegistryHost(server) != host {
+			continue
+		}
+		if c.CredsStore != "" && auth.Auth == "" && auth.Username == "" && auth.IdentityToken == "" {
+			return credentialHelper(c.CredsStore, server)
+		}
+		return auth.credential(server)
+	}
+	if c.CredsStore != "" {
+		server := host
+		if host == DockerHubHost {
+			server = DockerHubServer
+		}
+		if cred, err = credentialHelper(c.CredsStore, server); err != nil {
+			// The store does not have credentials for this registry
+			return Credential{}, nil
+		}
+	}
+	return
+}
+
+func (a DockerAuth) credential(server string) (cred Credential, err error) {
+	cred = Credential{
+		Username:      a.Username,
+		Password:      a.Password,
+		IdentityToken: a.IdentityToken,
+	}
+	if a.Auth != "" {
+		decoded, errD := base64.StdEncoding.DecodeString(a.Auth)
+		if errD != nil {
+			err = fmt.Errorf("Invalid auth for registry '%s' in Docker config: %s", server, errD.Error())
+			return
+		}
+		pair := strings.SplitN(string(decoded), ":", 2)
+		if len(pair) != 2 {
+			err = fmt.Errorf("Invalid auth for registry '%s' in Docker config", server)
+			return
+		}
+		cred.Username = pair[0]
+		cred.Password = pair[1]
+	}
+	return
+}
+
+// credentialHelper runs 'docker-credential-<helper> get' to get the
+// credentials of the server
+func credentialHelper(helper, server string) (cred Credential, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), credentialHelperTimeout)
+	defer cancel()
+	program := "docker-credential-" + helper
+	cmd := exec.CommandContext(ctx, program, "get")
+	cmd.Stdin = strings.NewReader(server)
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	out, err := cmd.Output()
+	if err != nil {
+		msg := strings.TrimSpace(string(out) + stderr.String())
+		err = fmt.Errorf("Credential helper '%s' failed for '%s': %s %s", program, server, err.Error(), msg)
+		return
+	}
+	response := struct {
+		Username string `json:"Username"`
+		Secret   string `json:"Secret"`
+	}{}
+	if err = json.Unmarshal(out, &response); err != nil {
+		err = fmt.Errorf("Unable to parse output of credential helper '%s': %s", program, err.Error())
+		return
+	}
+	if response.Username == identityTokenUsername {
+		cred.IdentityToken = response.Secret
+	} else {
+		cred.Username = response.Username
+		cred.Password = response.Secret
+	}
+	return
+}
+
+// Keychain resolves the credentials for registries, first from the ones
+// added explicitly and then from the Docker config. Results are cached.
+type Keychain struct {
+	static map[string]Credential
+	lazy   map[string]func() (Credential, error)
+	docker *DockerConfig
+	cache  map[string]Credential
+	mu     sync.Mutex
+}
+
+// NewKeychain creates a Keychain, docker can be nil
+func NewKeychain(docker *DockerConfig) *Keychain {
+	return &Keychain{
+		static: make(map[string]Credential),
+		lazy:   make(map[string]func() (Credential, error)),
+		docker: docker,
+		cache:  make(map[string]Credential),
+	}
+}
+
+// Add defines the credentials for a registry, it takes precedence over the
+// Docker config
+func (k *Keychain) Add(server string, cred Credential) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.static[RegistryHost(server)] = cred
+}
+
+// AddFunc defines the function which returns the credentials for a registry
+// the first time they are needed, like Add it takes precedence over the
+// Docker config. Errors are returned when resolving the registry.
+func (k *Keychain) AddFunc(server string, get func() (Credential, error)) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.lazy[RegistryHost(server)] = get
+}
+
+// Resolve returns the credentials for the registry host, empty if there
+// are none defined
+func (k *Keychain) Resolve(host string) (cred Credential, err error) {
+	host = RegistryHost(host)
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if c, ok := k.static[host]; ok {
+		return c, nil
+	}
+	if get, ok := k.lazy[host]; ok {
+		if cred, err = get(); err == nil {
+			k.static[host] = cred
+		}
+		return
+	}
+	if c, ok := k.cache[host]; ok {
+		return c, nil
+	}
+	if k.docker != nil {
+		if cred, err = k.docker.Credentials(host); err == nil {
+			k.cache[host] = cred
+		}
+	}
+	return
+}
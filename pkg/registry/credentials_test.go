@@ -0,0 +1,166 @@
+package registry
+
+import (
+	"encoding/base64"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testHelper is a credential helper (docker-credential-fake) returning the
+// credentials of some servers, it is found in the PATH
+const testHelper = `#!/bin/sh
+[ "$1" = "get" ] || exit 2
+read server
+case "$server" in
+  helper.example.com) echo '{"ServerURL":"helper.example.com","Username":"helper","Secret":"helper-secret"}' ;;
+  token.example.com) echo '{"ServerURL":"token.example.com","Username":"<token>","Secret":"identity"}' ;;
+  stored.example.com) echo '{"ServerURL":"stored.example.com","Username":"store","Secret":"store-secret"}' ;;
+  broken.example.com) echo 'not json' ;;
+  *) echo "credentials not found in native keychain" ; exit 1 ;;
+esac
+`
+
+// testDockerConfig writes a Docker config in a temporary DOCKER_CONFIG and
+// the fake credential helper in the PATH
+func testDockerConfig(t *testing.T, config string) {
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, "config.json"), []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+	bin := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(bin, "docker-credential-fake"), []byte(testHelper), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(DockerConfigEnv, dir)
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func basicAuth(user, password string) string {
+	return base64.StdEncoding.EncodeToString([]byte(user + ":" + password))
+}
+
+func TestDockerConfigCredentials(t *testing.T) {
+	testDockerConfig(t, `{
+  "auths": {
+    "https://index.docker.io/v1/": {"auth": "`+basicAuth("hub", "hub-password")+`"},
+    "registry.example.com:5000": {"username": "user", "password": "password"},
+    "https://auth.example.com/v2/": {"auth": "`+basicAuth("auth", "pass:word")+`"},
+    "identity.example.com": {"identitytoken": "token"},
+    "invalid.example.com": {"auth": "!"},
+    "stored.example.com": {}
+  },
+  "credHelpers": {
+    "helper.example.com": "fake",
+    "token.example.com": "fake",
+    "broken.example.com": "fake",
+    "missing.example.com": "missing"
+  },
+  "credsStore": "fake"
+}`)
+	config, err := LoadDockerConfig("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		name string
+		host string
+		cred Credential
+		err  bool
+	}{
+		{name: "docker hub", host: "docker.io", cred: Credential{Username: "hub", Password: "hub-password"}},
+		{name: "docker hub index", host: "index.docker.io", cred: Credential{Username: "hub", Password: "hub-password"}},
+		{name: "docker hub registry", host: DockerHubHost, cred: Credential{Username: "hub", Password: "hub-password"}},
+		{name: "host with port", host: "registry.example.com:5000", cred: Credential{Username: "user", Password: "password"}},
+		{name: "host with scheme and port", host: "https://registry.example.com:5000/v2/", cred: Credential{Username: "user", Password: "password"}},
+		{name: "other port", host: "registry.example.com"},
+		{name: "auth with scheme and path", host: "auth.example.com", cred: Credential{Username: "auth", Password: "pass:word"}},
+		{name: "identity token", host: "identity.example.com", cred: Credential{IdentityToken: "token"}},
+		{name: "invalid auth", host: "invalid.example.com", err: true},
+		{name: "credential helper", host: "helper.example.com", cred: Credential{Username: "helper", Password: "helper-secret"}},
+		{name: "credential helper token", host: "token.example.com", cred: Credential{IdentityToken: "identity"}},
+		{name: "credential helper invalid output", host: "broken.example.com", err: true},
+		{name: "credential helper not installed", host: "missing.example.com", err: true},
+		{name: "credentials store", host: "stored.example.com", cred: Credential{Username: "store", Password: "store-secret"}},
+		{name: "not in the credentials store", host: "unknown.example.com"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cred, err := config.Credentials(c.host)
+			if c.err {
+				if err == nil {
+					t.Errorf("Expected error, got %+v", cred)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cred != c.cred {
+				t.Errorf("Expected credentials %+v, got %+v", c.cred, cred)
+			}
+		})
+	}
+}
+
+func TestLoadDockerConfig(t *testing.T) {
+	dir := t.TempDir()
+	config, err := LoadDockerConfig(dir)
+	if err != nil {
+		t.Fatalf("Expected empty config without config.json, got %s", err.Error())
+	}
+	if cred, err := config.Credentials("registry.example.com"); err != nil || !cred.Empty() {
+		t.Errorf("Expected no credentials, got %+v (%v)", cred, err)
+	}
+	if err = ioutil.WriteFile(filepath.Join(dir, "config.json"), []byte("{"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = LoadDockerConfig(dir); err == nil {
+		t.Errorf("Expected error with invalid config.json")
+	}
+}
+
+func TestKeychain(t *testing.T) {
+	testDockerConfig(t, `{"auths": {"registry.example.com": {"username": "docker", "password": "docker"}, "lazy.example.com": {"username": "docker", "password": "docker"}}}`)
+	config, err := LoadDockerConfig("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	k := NewKeychain(config)
+	if cred, _ := k.Resolve("registry.example.com"); cred.Username != "docker" {
+		t.Errorf("Expected credentials of the Docker config, got %+v", cred)
+	}
+	// Added credentials take precedence over the Docker config
+	k.Add("https://registry.example.com/v2/", Credential{Username: "static", Password: "static"})
+	if cred, _ := k.Resolve("registry.example.com"); cred.Username != "static" {
+		t.Errorf("Expected added credentials, got %+v", cred)
+	}
+	calls := 0
+	failure := errors.New("no password file")
+	k.AddFunc("lazy.example.com", func() (Credential, error) {
+		calls++
+		if calls == 1 {
+			return Credential{}, failure
+		}
+		return Credential{Username: "lazy", Password: "lazy"}, nil
+	})
+	if calls != 0 {
+		t.Fatalf("Credentials resolved before using the registry")
+	}
+	if _, err = k.Resolve("lazy.example.com"); !errors.Is(err, failure) {
+		t.Errorf("Expected error of the credentials function, got %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if cred, _ := k.Resolve("lazy.example.com"); cred.Username != "lazy" {
+			t.Errorf("Expected credentials of the function, got %+v", cred)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("Expected the credentials function called until it succeeds, got %d calls", calls)
+	}
+	if cred, err := k.Resolve("other.example.com"); err != nil || !cred.Empty() {
+		t.Errorf("Expected anonymous access, got %+v (%v)", cred, err)
+	}
+}
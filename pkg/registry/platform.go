@@ -0,0 +1,71 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	specs "github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// ParsePlatform parses a platform with the format os/arch[/variant]
+func ParsePlatform(platform string) (p ocispec.Platform, err error) {
+	parts := strings.Split(strings.ToLower(strings.TrimSpace(platform)), "/")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+		err = fmt.Errorf("Invalid platform '%s', the format is os/arch[/variant]", platform)
+		return
+	}
+	p.OS = parts[0]
+	p.Architecture = parts[1]
+	if len(parts) == 3 {
+		p.Variant = parts[2]
+	}
+	return
+}
+
+// PlatformString returns the platform with the format os/arch[/variant]
+func PlatformString(p ocispec.Platform) string {
+	s := p.OS + "/" + p.Architecture
+	if p.Variant != "" {
+		s = s + "/" + p.Variant
+	}
+	return s
+}
+
+// MatchPlatform checks if the platform of an image matches the wanted one,
+// the variant is only compared when it is defined
+func MatchPlatform(image, wanted ocispec.Platform) bool {
+	if image.OS != wanted.OS || image.Architecture != wanted.Architecture {
+		return false
+	}
+	return wanted.Variant == "" || image.Variant == wanted.Variant
+}
+
+// PushIndex uploads a manifest list with the images of each platform. The
+// Docker media type is used when all the images are Docker manifests,
+// otherwise it is an OCI index.
+func (c *Client) PushIndex(ctx context.Context, ref Reference, manifests []ocispec.Descriptor) (desc ocispec.Descriptor, err error) {
+	mediaType := MediaTypeDockerManifestList
+	for _, m := range manifests {
+		if m.MediaType != MediaTypeDockerManifest {
+			mediaType = ocispec.MediaTypeImageIndex
+			break
+		}
+	}
+	index := struct {
+		specs.Versioned
+		MediaType string               `json:"mediaType"`
+		Manifests []ocispec.Descriptor `json:"manifests"`
+	}{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: mediaType,
+		Manifests: manifests,
+	}
+	content, err := json.Marshal(index)
+	if err != nil {
+		return
+	}
+	return c.PushManifest(ctx, ref, mediaType, content)
+}
@@ -0,0 +1,99 @@
+package registry
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	digest "github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// BlobExists checks if the blob is in the repository of the reference
+func (c *Client) BlobExists(ctx context.Context, ref Reference, dgst digest.Digest) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, c.url(ref, "blobs", dgst.String()), nil)
+	if err != nil {
+		return false, err
+	}
+	resp, err := c.do(req, ref, "pull,push")
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+	return true, nil
+}
+
+// PushBlob uploads the content to the repository of the reference (in one
+// request) if it is not already there
+func (c *Client) PushBlob(ctx context.Context, ref Reference, mediaType string, content []byte) (desc ocispec.Descriptor, err error) {
+	desc = ocispec.Descriptor{
+		MediaType: mediaType,
+		Digest:    digest.FromBytes(content),
+		Size:      int64(len(content)),
+	}
+	if exists, errE := c.BlobExists(ctx, ref, desc.Digest); errE != nil {
+		err = errE
+		return
+	} else if exists {
+		return
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url(ref, "blobs", "uploads/"), nil)
+	if err != nil {
+		return
+	}
+	resp, err := c.do(req, ref, "pull,push")
+	if err != nil {
+		err = fmt.Errorf("Unable to start upload of blob '%s': %s", desc.Digest, err.Error())
+		return
+	}
+	resp.Body.Close()
+	location, err := resp.Request.URL.Parse(resp.Header.Get("Location"))
+	if err != nil || resp.Header.Get("Location") == "" {
+		err = fmt.Errorf("Invalid upload location for blob '%s' from registry '%s'", desc.Digest, ref.Host())
+		return
+	}
+	query := location.Query()
+	query.Set("digest", desc.Digest.String())
+	location.RawQuery = query.Encode()
+	if err = c.put(ctx, ref, location, "application/octet-stream", content); err != nil {
+		err = fmt.Errorf("Unable to upload blob '%s': %s", desc.Digest, err.Error())
+	}
+	return
+}
+
+// PushManifest uploads a manifest (or index) to the registry with the tag
+// or digest of the reference
+func (c *Client) PushManifest(ctx context.Context, ref Reference, mediaType string, content []byte) (desc ocispec.Descriptor, err error) {
+	desc = ocispec.Descriptor{
+		MediaType: mediaType,
+		Digest:    digest.FromBytes(content),
+		Size:      int64(len(content)),
+	}
+	location, err := url.Parse(c.url(ref, "manifests", ref.Identifier()))
+	if err != nil {
+		return
+	}
+	if err = c.put(ctx, ref, location, mediaType, content); err != nil {
+		err = fmt.Errorf("Unable to upload manifest '%s': %s", ref.String(), err.Error())
+	}
+	return
+}
+
+func (c *Client) put(ctx context.Context, ref Reference, location *url.URL, mediaType string, content []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, location.String(), bytes.NewReader(content))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", mediaType)
+	resp, err := c.do(req, ref, "pull,push")
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
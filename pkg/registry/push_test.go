@@ -0,0 +1,221 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	digest "github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+const (
+	testUsername = "user"
+	testPassword = "secret"
+	testToken    = "token-123"
+)
+
+// testRegistry is an in-memory registry which requires a bearer token from
+// its own token endpoint, with basic authentication
+type testRegistry struct {
+	*httptest.Server
+	blobs     map[string][]byte
+	manifests map[string][]byte
+	types     map[string]string
+	scopes    []string
+	uploads   int
+	mu        sync.Mutex
+}
+
+func newTestRegistry(t *testing.T) *testRegistry {
+	r := &testRegistry{
+		blobs:     make(map[string][]byte),
+		manifests: make(map[string][]byte),
+		types:     make(map[string]string),
+	}
+	r.Server = httptest.NewServer(http.HandlerFunc(r.serve))
+	t.Cleanup(r.Close)
+	return r
+}
+
+// host returns the host of the registry, 127.0.0.1 is always http
+func (r *testRegistry) host() string {
+	return strings.TrimPrefix(r.URL, "http://")
+}
+
+func (r *testRegistry) serve(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if req.URL.Path == "/token" {
+		if user, password, ok := req.BasicAuth(); !ok || user != testUsername || password != testPassword {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		r.scopes = append(r.scopes, req.URL.Query().Get("scope"))
+		fmt.Fprintf(w, `{"token": "%s"}`, testToken)
+		return
+	}
+	if req.Header.Get("Authorization") != "Bearer "+testToken {
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="test"`, r.URL))
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	path := strings.TrimPrefix(req.URL.Path, "/v2/")
+	switch {
+	case strings.Contains(path, "/blobs/uploads/"):
+		repo := path[:strings.Index(path, "/blobs/uploads/")]
+		if req.Method == http.MethodPost {
+			r.uploads++
+			w.Header().Set("Location", fmt.Sprintf("/v2/%s/blobs/uploads/%d?state=abc", repo, r.uploads))
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		body, _ := ioutil.ReadAll(req.Body)
+		dgst := req.URL.Query().Get("digest")
+		if req.Method != http.MethodPut || req.URL.Query().Get("state") != "abc" || digest.FromBytes(body).String() != dgst {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		r.blobs[repo+"@"+dgst] = body
+		w.WriteHeader(http.StatusCreated)
+	case strings.Contains(path, "/blobs/"):
+		i := strings.Index(path, "/blobs/")
+		body, ok := r.blobs[path[:i]+"@"+path[i+len("/blobs/"):]]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(body)
+	case strings.Contains(path, "/manifests/"):
+		i := strings.Index(path, "/manifests/")
+		key := path[:i] + "@" + path[i+len("/manifests/"):]
+		if req.Method == http.MethodPut {
+			body, _ := ioutil.ReadAll(req.Body)
+			r.manifests[key] = body
+			r.types[key] = req.Header.Get("Content-Type")
+			dgst := digest.FromBytes(body).String()
+			r.manifests[path[:i]+"@"+dgst] = body
+			r.types[path[:i]+"@"+dgst] = req.Header.Get("Content-Type")
+			w.Header().Set("Docker-Content-Digest", dgst)
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+		body, ok := r.manifests[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", r.types[key])
+		w.Header().Set("Docker-Content-Digest", digest.FromBytes(body).String())
+		w.Write(body)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func testCredentials(host string) (Credential, error) {
+	return Credential{Username: testUsername, Password: testPassword}, nil
+}
+
+func TestPushBlob(t *testing.T) {
+	r := newTestRegistry(t)
+	client := New(testCredentials)
+	ref, err := ParseReference(r.host() + "/team/app:1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := []byte("layer content")
+	ctx := context.Background()
+	desc, err := client.PushBlob(ctx, ref, MediaTypeDockerLayer, content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if desc.Digest != digest.FromBytes(content) || desc.Size != int64(len(content)) || desc.MediaType != MediaTypeDockerLayer {
+		t.Errorf("Unexpected descriptor %+v", desc)
+	}
+	if string(r.blobs["team/app@"+desc.Digest.String()]) != string(content) {
+		t.Errorf("Blob not stored in the registry")
+	}
+	if len(r.scopes) != 1 || r.scopes[0] != "repository:team/app:pull,push" {
+		t.Errorf("Expected one token request for the push scope, got %v", r.scopes)
+	}
+	// The blob is already there and the token is cached
+	if _, err = client.PushBlob(ctx, ref, MediaTypeDockerLayer, content); err != nil {
+		t.Fatal(err)
+	}
+	if r.uploads != 1 || len(r.scopes) != 1 {
+		t.Errorf("Expected one upload and one token request, got %d and %v", r.uploads, r.scopes)
+	}
+	body, err := client.Blob(ctx, ref, desc.Digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != string(content) {
+		t.Errorf("Expected blob '%s', got '%s'", content, body)
+	}
+}
+
+func TestPushManifest(t *testing.T) {
+	r := newTestRegistry(t)
+	client := New(testCredentials)
+	ref, err := ParseReference(r.host() + "/team/app:1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	config, err := client.PushBlob(ctx, ref, ocispec.MediaTypeImageConfig, []byte(`{"config": {"Labels": {"a": "b"}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := json.Marshal(ocispec.Manifest{Config: config})
+	if err != nil {
+		t.Fatal(err)
+	}
+	desc, err := client.PushManifest(ctx, ref, ocispec.MediaTypeImageManifest, manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if desc.Digest != digest.FromBytes(manifest) {
+		t.Errorf("Expected digest %s, got %s", digest.FromBytes(manifest), desc.Digest)
+	}
+	head, err := client.HeadManifest(ctx, ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head.Digest != desc.Digest || head.MediaType != ocispec.MediaTypeImageManifest {
+		t.Errorf("Expected manifest %s (%s), got %s (%s)", desc.Digest, ocispec.MediaTypeImageManifest, head.Digest, head.MediaType)
+	}
+	image, err := client.ImageConfig(ctx, ref, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if image.Config.Labels["a"] != "b" {
+		t.Errorf("Expected label a=b in the image config, got %v", image.Config.Labels)
+	}
+	if _, err = client.HeadManifest(ctx, ref.WithTag("missing")); err != ErrNotFound {
+		t.Errorf("Expected not found error, got %v", err)
+	}
+}
+
+func TestPushUnauthorized(t *testing.T) {
+	r := newTestRegistry(t)
+	client := New(func(host string) (Credential, error) {
+		return Credential{Username: testUsername, Password: "wrong"}, nil
+	})
+	ref, err := ParseReference(r.host() + "/team/app:1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = client.PushBlob(context.Background(), ref, MediaTypeDockerLayer, []byte("layer")); err == nil {
+		t.Fatal("Expected authentication error")
+	}
+	if len(r.blobs) != 0 {
+		t.Errorf("Blob pushed without authentication")
+	}
+}
@@ -0,0 +1,142 @@
+package registry
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	// DockerHub is the name of the default registry
+	DockerHub = "docker.io"
+	// DockerHubHost is the API endpoint of the default registry
+	DockerHubHost = "registry-1.docker.io"
+	// DefaultTag is the tag used when the reference does not define one
+	DefaultTag = "latest"
+)
+
+var (
+	tagRegexp    = regexp.MustCompile(`^[\w][\w.-]{0,127}$`)
+	digestRegexp = regexp.MustCompile(`^[a-z0-9]+(?:[.+_-][a-z0-9]+)*:[a-fA-F0-9]{32,}$`)
+)
+
+// Reference is a parsed image name: registry/repository[:tag][@digest]
+type Reference struct {
+	Registry   string
+	Repository string
+	Tag        string
+	Digest     string
+}
+
+// ParseReference splits an image name in its components. The first part of
+// the name is the registry only if it looks like a hostname (it has a '.' or
+// ':' or it is 'localhost'), otherwise the image is in Docker Hub.
+func ParseReference(image string) (ref Reference, err error) {
+	name := strings.TrimSpace(image)
+	if name == "" {
+		err = fmt.Errorf("Invalid empty image reference")
+		return
+	}
+	if i := strings.Index(name, "@"); i >= 0 {
+		ref.Digest = name[i+1:]
+		name = name[:i]
+		if !digestRegexp.MatchString(ref.Digest) {
+			err = fmt.Errorf("Invalid digest in image reference '%s'", image)
+			return
+		}
+	}
+	// The tag is after the last ':' if there is no '/' after it (port)
+	if i := strings.LastIndex(name, ":"); i >= 0 && !strings.Contains(name[i:], "/") {
+		ref.Tag = name[i+1:]
+		name = name[:i]
+		if !tagRegexp.MatchString(ref.Tag) {
+			err = fmt.Errorf("Invalid tag in image reference '%s'", image)
+			return
+		}
+	}
+	parts := strings.SplitN(name, "/", 2)
+	if len(parts) == 2 && IsHostname(parts[0]) {
+		ref.Registry = strings.ToLower(parts[0])
+		ref.Repository = parts[1]
+	} else {
+		ref.Registry = DockerHub
+		ref.Repository = name
+	}
+	if ref.Registry == "index.docker.io" || ref.Registry == DockerHubHost {
+		ref.Registry = DockerHub
+	}
+	if ref.Registry == DockerHub && !strings.Contains(ref.Repository, "/") {
+		ref.Repository = "library/" + ref.Repository
+	}
+	if ref.Repository == "" || ref.Repository != strings.ToLower(ref.Repository) {
+		err = fmt.Errorf("Invalid repository name in image reference '%s'", image)
+		return
+	}
+	if ref.Tag == "" && ref.Digest == "" {
+		ref.Tag = DefaultTag
+	}
+	return
+}
+
+// IsHostname returns true if the first component of an image name is a
+// registry hostname
+func IsHostname(name string) bool {
+	return strings.ContainsAny(name, ".:") || name == "localhost"
+}
+
+// RegistryHost returns the hostname to use with the registry API, given a
+// registry name or a server address with scheme and/or path
+func RegistryHost(server string) string {
+	host := strings.TrimSpace(server)
+	if i := strings.Index(host, "://"); i >= 0 {
+		host = host[i+3:]
+	}
+	host = strings.ToLower(strings.SplitN(host, "/", 2)[0])
+	switch host {
+	case "", DockerHub, "index.docker.io", DockerHubHost:
+		return DockerHubHost
+	}
+	return host
+}
+
+// Host returns the hostname of the registry API
+func (r Reference) Host() string {
+	return RegistryHost(r.Registry)
+}
+
+// Identifier returns the digest if defined, otherwise the tag
+func (r Reference) Identifier() string {
+	if r.Digest != "" {
+		return r.Digest
+	}
+	return r.Tag
+}
+
+// WithTag returns a copy of the reference pointing to another tag
+func (r Reference) WithTag(tag string) Reference {
+	r.Tag = tag
+	r.Digest = ""
+	return r
+}
+
+// WithDigest returns a copy of the reference pointing to a digest
+func (r Reference) WithDigest(digest string) Reference {
+	r.Digest = digest
+	return r
+}
+
+// Name returns the registry and the repository without tag or digest
+func (r Reference) Name() string {
+	return r.Registry + "/" + r.Repository
+}
+
+func (r Reference) String() string {
+	s := r.Name()
+	if r.Tag != "" {
+		s = s + ":" + r.Tag
+	}
+	if r.Digest != "" {
+		s = s + "@" + r.Digest
+	}
+	return s
+}
@@ -0,0 +1,369 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"runtime"
+	"strings"
+	"sync"
+
+	digest "github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+const (
+	// ClientID used to identify the client in OAuth2 token requests
+	ClientID                    = "kubefoundry"
+	MediaTypeDockerManifest     = "application/vnd.docker.distribution.manifest.v2+json"
+	MediaTypeDockerManifestList = "application/vnd.docker.distribution.manifest.list.v2+json"
+	MediaTypeDockerConfig       = "application/vnd.docker.container.image.v1+json"
+	MediaTypeDockerLayer        = "application/vnd.docker.image.rootfs.diff.tar.gzip"
+)
+
+var (
+	// ErrNotFound is returned when the manifest or blob does not exist
+	ErrNotFound = errors.New("Not found in registry")
+	// ManifestMediaTypes are the types accepted when getting manifests
+	ManifestMediaTypes = []string{
+		ocispec.MediaTypeImageManifest,
+		ocispec.MediaTypeImageIndex,
+		MediaTypeDockerManifest,
+		MediaTypeDockerManifestList,
+	}
+)
+
+// CredentialsFunc returns the credentials for a registry host, empty
+// credentials mean anonymous access
+type CredentialsFunc func(host string) (Credential, error)
+
+// Client is a minimal OCI distribution API client
+type Client struct {
+	Credentials CredentialsFunc
+	Insecure    []string
+	http        *http.Client
+	tokens      map[string]string
+	mu          sync.Mutex
+}
+
+// New returns a registry client using the credentials function provided
+func New(credentials CredentialsFunc) *Client {
+	return &Client{
+		Credentials: credentials,
+		http:        http.DefaultClient,
+		tokens:      make(map[string]string),
+	}
+}
+
+// Manifest gets a manifest (or index) from the registry
+func (c *Client) Manifest(ctx context.Context, ref Reference) (desc ocispec.Descriptor, body []byte, err error) {
+	resp, err := c.manifest(ctx, http.MethodGet, ref)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	if body, err = ioutil.ReadAll(resp.Body); err != nil {
+		err = fmt.Errorf("Unable to read manifest '%s': %s", ref.String(), err.Error())
+		return
+	}
+	desc = ocispec.Descriptor{
+		MediaType: resp.Header.Get("Content-Type"),
+		Digest:    digest.FromBytes(body),
+		Size:      int64(len(body)),
+	}
+	return
+}
+
+// HeadManifest returns the descriptor of a manifest without downloading it
+func (c *Client) HeadManifest(ctx context.Context, ref Reference) (desc ocispec.Descriptor, err error) {
+	resp, err := c.manifest(ctx, http.MethodHead, ref)
+	if err != nil {
+		return
+	}
+	resp.Body.Close()
+	desc = ocispec.Descriptor{
+		MediaType: resp.Header.Get("Content-Type"),
+		Digest:    digest.Digest(resp.Header.Get("Docker-Content-Digest")),
+		Size:      resp.ContentLength,
+	}
+	if desc.Digest == "" {
+		// Not all registries return the digest header
+		if desc, _, err = c.Manifest(ctx, ref); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (c *Client) manifest(ctx context.Context, method string, ref Reference) (*http.Response, error) {
+	endpoint := c.url(ref, "manifests", ref.Identifier())
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", strings.Join(ManifestMediaTypes, ", "))
+	return c.do(req, ref, "pull")
+}
+
+// Blob downloads a blob from the repository of the reference
+func (c *Client) Blob(ctx context.Context, ref Reference, dgst digest.Digest) (body []byte, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url(ref, "blobs", dgst.String()), nil)
+	if err != nil {
+		return
+	}
+	resp, err := c.do(req, ref, "pull")
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	if body, err = ioutil.ReadAll(resp.Body); err != nil {
+		err = fmt.Errorf("Unable to read blob '%s': %s", dgst.String(), err.Error())
+	} else if digest.FromBytes(body) != dgst {
+		err = fmt.Errorf("Blob '%s' does not match its digest", dgst.String())
+	}
+	return
+}
+
+// ImageConfig returns the configuration of an image. If the reference
+// points to an index, the image for the platform (or the current one if it
+// is nil) is used.
+func (c *Client) ImageConfig(ctx context.Context, ref Reference, platform *ocispec.Platform) (config *ocispec.Image, err error) {
+	desc, body, err := c.Manifest(ctx, ref)
+	if err != nil {
+		return
+	}
+	if desc.MediaType == ocispec.MediaTypeImageIndex || desc.MediaType == MediaTypeDockerManifestList {
+		index := ocispec.Index{}
+		if err = json.Unmarshal(body, &index); err != nil {
+			err = fmt.Errorf("Unable to parse image index '%s': %s", ref.String(), err.Error())
+			return
+		}
+		if platform == nil {
+			platform = &ocispec.Platform{OS: "linux", Architecture: runtime.GOARCH}
+		}
+		found := false
+		for _, m := range index.Manifests {
+			if m.Platform != nil && MatchPlatform(*m.Platform, *platform) {
+				ref = ref.WithDigest(m.Digest.String())
+				found = true
+				break
+			}
+		}
+		if !found {
+			err = fmt.Errorf("Image '%s' not available for platform %s", ref.String(), PlatformString(*platform))
+			return
+		}
+		if _, body, err = c.Manifest(ctx, ref); err != nil {
+			return
+		}
+	}
+	manifest := ocispec.Manifest{}
+	if err = json.Unmarshal(body, &manifest); err != nil {
+		err = fmt.Errorf("Unable to parse image manifest '%s': %s", ref.String(), err.Error())
+		return
+	}
+	blob, err := c.Blob(ctx, ref, manifest.Config.Digest)
+	if err != nil {
+		return
+	}
+	config = &ocispec.Image{}
+	if err = json.Unmarshal(blob, config); err != nil {
+		err = fmt.Errorf("Unable to parse image config '%s': %s", ref.String(), err.Error())
+	}
+	return
+}
+
+func (c *Client) url(ref Reference, kind, id string) string {
+	scheme := "https"
+	if c.isInsecure(ref.Host()) {
+		scheme = "http"
+	}
+	return fmt.Sprintf("%s://%s/v2/%s/%s/%s", scheme, ref.Host(), ref.Repository, kind, id)
+}
+
+func (c *Client) isInsecure(host string) bool {
+	name := strings.SplitN(host, ":", 2)[0]
+	if name == "localhost" || strings.HasPrefix(name, "127.") {
+		return true
+	}
+	for _, h := range c.Insecure {
+		if RegistryHost(h) == host {
+			return true
+		}
+	}
+	return false
+}
+
+// do sends the request and handles the authentication challenges, the
+// bearer tokens are cached by registry and scope
+func (c *Client) do(req *http.Request, ref Reference, actions string) (*http.Response, error) {
+	scope := fmt.Sprintf("repository:%s:%s", ref.Repository, actions)
+	key := ref.Host() + "|" + scope
+	c.mu.Lock()
+	token := c.tokens[key]
+	c.mu.Unlock()
+	if token != "" {
+		req.Header.Set("Authorization", token)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to connect with registry '%s': %s", ref.Host(), err.Error())
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		resp.Body.Close()
+		if token, err = c.authorize(req.Context(), ref.Host(), challenge, scope); err != nil {
+			return nil, err
+		}
+		c.mu.Lock()
+		c.tokens[key] = token
+		c.mu.Unlock()
+		if req, err = retry(req); err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", token)
+		if resp, err = c.http.Do(req); err != nil {
+			return nil, fmt.Errorf("Unable to connect with registry '%s': %s", ref.Host(), err.Error())
+		}
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, ErrNotFound
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("Registry '%s' returned %s: %s", ref.Host(), resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return resp, nil
+}
+
+// retry returns a copy of the request with a new body
+func retry(req *http.Request) (*http.Request, error) {
+	r := req.Clone(req.Context())
+	if req.Body != nil && req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		r.Body = body
+	}
+	return r, nil
+}
+
+// authorize returns the value of the Authorization header to answer the
+// challenge of the registry
+func (c *Client) authorize(ctx context.Context, host, challenge, scope string) (string, error) {
+	cred := Credential{}
+	if c.Credentials != nil {
+		var err error
+		if cred, err = c.Credentials(host); err != nil {
+			return "", err
+		}
+	}
+	scheme, params := parseChallenge(challenge)
+	switch scheme {
+	case "basic":
+		if cred.Username == "" {
+			return "", fmt.Errorf("Registry '%s' requires credentials", host)
+		}
+		req := &http.Request{Header: http.Header{}}
+		req.SetBasicAuth(cred.Username, cred.Password)
+		return req.Header.Get("Authorization"), nil
+	case "bearer":
+		realm, err := url.Parse(params["realm"])
+		if err != nil || params["realm"] == "" {
+			return "", fmt.Errorf("Invalid authentication realm from registry '%s'", host)
+		}
+		query := url.Values{}
+		if params["service"] != "" {
+			query.Set("service", params["service"])
+		}
+		query.Set("scope", scope)
+		var req *http.Request
+		if cred.IdentityToken != "" {
+			// OAuth2 refresh token flow
+			query.Set("grant_type", "refresh_token")
+			query.Set("refresh_token", cred.IdentityToken)
+			query.Set("client_id", ClientID)
+			req, err = http.NewRequestWithContext(ctx, http.MethodPost, realm.String(), strings.NewReader(query.Encode()))
+			if err == nil {
+				req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			}
+		} else {
+			values := realm.Query()
+			for k, v := range query {
+				values[k] = v
+			}
+			realm.RawQuery = values.Encode()
+			req, err = http.NewRequestWithContext(ctx, http.MethodGet, realm.String(), nil)
+			if err == nil && cred.Username != "" {
+				req.SetBasicAuth(cred.Username, cred.Password)
+			}
+		}
+		if err != nil {
+			return "", err
+		}
+		resp, err := c.http.Do(req)
+		if err != nil {
+			return "", fmt.Errorf("Unable to get token from '%s': %s", realm.Host, err.Error())
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return "", fmt.Errorf("Authentication with registry '%s' failed: %s", host, resp.Status)
+		}
+		token := struct {
+			Token       string `json:"token"`
+			AccessToken string `json:"access_token"`
+		}{}
+		if err = json.NewDecoder(resp.Body).Decode(&token); err != nil {
+			return "", fmt.Errorf("Unable to parse token from '%s': %s", realm.Host, err.Error())
+		}
+		if token.Token == "" {
+			token.Token = token.AccessToken
+		}
+		return "Bearer " + token.Token, nil
+	}
+	return "", fmt.Errorf("Unsupported authentication '%s' from registry '%s'", challenge, host)
+}
+
+// parseChallenge parses a WWW-Authenticate header
+func parseChallenge(header string) (scheme string, params map[string]string) {
+	params = make(map[string]string)
+	parts := strings.SplitN(strings.TrimSpace(header), " ", 2)
+	scheme = strings.ToLower(parts[0])
+	if len(parts) < 2 {
+		return
+	}
+	rest := parts[1]
+	for rest != "" {
+		eq := strings.Index(rest, "=")
+		if eq < 0 {
+			break
+		}
+		key := strings.ToLower(strings.TrimSpace(rest[:eq]))
+		rest = strings.TrimSpace(rest[eq+1:])
+		value := ""
+		if strings.HasPrefix(rest, "\"") {
+			end := strings.Index(rest[1:], "\"")
+			if end < 0 {
+				value, rest = rest[1:], ""
+			} else {
+				value, rest = rest[1:end+1], rest[end+2:]
+			}
+		} else if comma := strings.Index(rest, ","); comma >= 0 {
+			value, rest = rest[:comma], rest[comma:]
+		} else {
+			value, rest = rest, ""
+		}
+		params[key] = value
+		rest = strings.TrimPrefix(strings.TrimSpace(rest), ",")
+		rest = strings.TrimSpace(rest)
+	}
+	return
+}
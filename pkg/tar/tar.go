@@ -24,6 +24,7 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
+	"time"
 
 	log "kubefoundry/internal/log"
 	glob "kubefoundry/pkg/glob"
@@ -38,9 +39,13 @@ type Tar struct {
 	SkipFileGlob *glob.Glob
 	FileGlob     *glob.Glob
 	DirGlob      *glob.Glob
+	Ignore       *glob.List
+	Reproducible bool
+	ModTime      time.Time
 	file         *os.File
 	ctx          context.Context
 	mw           io.Writer
+	digest       io.Writer
 	tw           *tar.Writer
 	mu           sync.Mutex
 	log          log.Logger
@@ -77,11 +82,96 @@ func NewTarFile(tarfile string, l log.Logger) (*Tar, error) {
 	return t, err
 }
 
-func (t *Tar) Close() {
-	t.tw.Close()
+func (t *Tar) Close() error {
+	err := t.tw.Close()
 	if t.file != nil {
-		t.file.Close()
+		if errf := t.file.Close(); err == nil {
+			err = errf
+		}
+	}
+	return err
+}
+
+// SetReproducible makes the content of the tar only depend on the name,
+// content and permissions of the files, the same input will always produce
+// the same tar. Entries are added in lexical order, owners are removed,
+// modes normalized and all timestamps are set to mtime (SOURCE_DATE_EPOCH).
+func (t *Tar) SetReproducible(mtime time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.Reproducible = true
+	t.ModTime = mtime.UTC().Truncate(time.Second)
+}
+
+// SetDigest writes the name, mode, size and link of the entries and the
+// content of the files to w while they are added, without owners nor dates,
+// to hash the content of the tar in the same pass.
+func (t *Tar) SetDigest(w io.Writer) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.digest = w
+}
+
+// writeHeader writes the header of an entry to the tar and to the digest
+func (t *Tar) writeHeader(header *tar.Header) error {
+	if t.digest != nil {
+		mode := int64(0644)
+		switch {
+		case header.Typeflag == tar.TypeDir:
+			mode = 0755
+		case header.Typeflag == tar.TypeSymlink:
+			mode = 0777
+		case header.Mode&0111 != 0:
+			mode = 0755
+		}
+		fmt.Fprintf(t.digest, "%s %o %d %s\n", header.Name, mode, header.Size, header.Linkname)
+	}
+	return t.tw.WriteHeader(header)
+}
+
+// content returns the writer of the content of the files
+func (t *Tar) content() io.Writer {
+	if t.digest != nil {
+		return io.MultiWriter(t.tw, t.digest)
+	}
+	return t.tw
+}
+
+// header returns the tar header for a file, normalized in reproducible mode
+func (t *Tar) header(i os.FileInfo, path string) (*tar.Header, error) {
+	link := ""
+	if i.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(path)
+		if err != nil {
+			return nil, err
+		}
+		link = target
+	}
+	header, err := tar.FileInfoHeader(i, link)
+	if err != nil {
+		return nil, err
 	}
+	if t.Reproducible {
+		header.Uid = 0
+		header.Gid = 0
+		header.Uname = ""
+		header.Gname = ""
+		header.ModTime = t.ModTime
+		header.AccessTime = time.Time{}
+		header.ChangeTime = time.Time{}
+		header.PAXRecords = nil
+		switch {
+		case i.IsDir():
+			header.Mode = 0755
+		case i.Mode()&os.ModeSymlink != 0:
+			header.Mode = 0777
+		case i.Mode()&0111 != 0:
+			header.Mode = 0755
+		default:
+			header.Mode = 0644
+		}
+	}
+	return header, nil
 }
 
 // Option to pass to the using Functional Options
@@ -143,11 +233,22 @@ func DirGlob(s string) Option {
 	}
 }
 
+// IgnoreList is a function used by users to skip the files and folders
+// matching the list of patterns, relative to the source path. Unlike the
+// rest of options, it only applies to the Add call where it is passed.
+func IgnoreList(l *glob.List) Option {
+	return func(t *Tar) error {
+		t.Ignore = l
+		return nil
+	}
+}
+
 func (t *Tar) Add(ctx context.Context, src, dstpath string, opts ...Option) error {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 	t.ctx = ctx
 	t.srcPath = src
+	t.Ignore = nil
 	t.dstPath = "."
 	if dstpath != "" {
 		t.dstPath = dstpath
@@ -166,6 +267,28 @@ func (t *Tar) Add(ctx context.Context, src, dstpath string, opts ...Option) erro
 	}
 }
 
+// AddDir adds an empty folder
+func (t *Tar) AddDir(path string, mode os.FileMode) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	header := &tar.Header{
+		Typeflag: tar.TypeDir,
+		Name:     filepath.Join(t.BasePath, path) + "/",
+		Mode:     int64(mode.Perm()),
+		ModTime:  time.Now(),
+	}
+	if t.Reproducible {
+		header.ModTime = t.ModTime
+	}
+	if err := t.writeHeader(header); err != nil {
+		err = fmt.Errorf("Cannot store tar header for folder '%s': %s", path, err.Error())
+		t.log.Error(err)
+		return err
+	}
+	t.log.Debugf("Adding directory '%s'", path)
+	return nil
+}
+
 func (t *Tar) AddFile(f fs.File, path string, mode os.FileMode) error {
 	t.mu.Lock()
 	defer t.mu.Unlock()
@@ -175,7 +298,7 @@ func (t *Tar) AddFile(f fs.File, path string, mode os.FileMode) error {
 		t.log.Error(err)
 		return err
 	}
-	header, err := tar.FileInfoHeader(i, i.Name())
+	header, err := t.header(i, path)
 	if err != nil {
 		err = fmt.Errorf("Cannot get tar header for file '%s': %s", path, err.Error())
 		t.log.Error(err)
@@ -183,12 +306,12 @@ func (t *Tar) AddFile(f fs.File, path string, mode os.FileMode) error {
 	}
 	header.Mode = int64(mode.Perm())
 	header.Name = filepath.Join(t.BasePath, path)
-	if err := t.tw.WriteHeader(header); err != nil {
+	if err := t.writeHeader(header); err != nil {
 		err = fmt.Errorf("Cannot store tar header for file '%s': %s", path, err.Error())
 		t.log.Error(err)
 		return err
 	}
-	bytes, err := io.Copy(t.tw, f)
+	bytes, err := io.Copy(t.content(), f)
 	if err != nil {
 		err = fmt.Errorf("Cannot tar file '%s': %s", path, err.Error())
 		t.log.Error(err)
@@ -204,6 +327,16 @@ func (t *Tar) scan(p string, i os.FileInfo, err error) error {
 		t.log.Error(err)
 		return err
 	}
+	if t.Ignore != nil {
+		name, err := filepath.Rel(t.srcPath, p)
+		if err == nil && name != "." && t.Ignore.Match(filepath.ToSlash(name), i.IsDir()) {
+			t.log.Debugf("Skipping ignored path: %s", p)
+			if i.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+	}
 	if i.IsDir() {
 		if t.SkipDirGlob != nil && t.SkipDirGlob.MatchString(p) {
 			t.log.Debugf("Skipping folder due to glob '%s': %s", t.SkipDirGlob.String(), p)
@@ -235,7 +368,7 @@ func (t *Tar) scan(p string, i os.FileInfo, err error) error {
 }
 
 func (t *Tar) tarFile(path string, i os.FileInfo) error {
-	header, err := tar.FileInfoHeader(i, i.Name())
+	header, err := t.header(i, path)
 	if err != nil {
 		err = fmt.Errorf("Cannot get tar header for file '%s': %s", path, err.Error())
 		t.log.Error(err)
@@ -243,13 +376,19 @@ func (t *Tar) tarFile(path string, i os.FileInfo) error {
 	}
 	// Remove relative paths
 	name := strings.TrimPrefix(path, t.srcPath)
-	if name == "" {
+	if name == "" && i.IsDir() {
+		// The source folder is the destination
+		name = "."
+	} else if name == "" {
 		name = filepath.Base(path)
 	}
 	header.Name = filepath.Join(t.dstPath, name)
 	// Add base
 	header.Name = filepath.Join(t.BasePath, header.Name)
-	if err := t.tw.WriteHeader(header); err != nil {
+	if i.IsDir() && header.Name == "." {
+		return nil
+	}
+	if err := t.writeHeader(header); err != nil {
 		err = fmt.Errorf("Cannot store tar header for file '%s': %s", path, err.Error())
 		t.log.Error(err)
 		return err
@@ -257,6 +396,9 @@ func (t *Tar) tarFile(path string, i os.FileInfo) error {
 	if i.IsDir() {
 		t.log.Debugf("Adding directory '%s'", path)
 		return nil
+	} else if header.Typeflag == tar.TypeSymlink {
+		t.log.Debugf("Adding symlink '%s' to '%s'", path, header.Linkname)
+		return nil
 	}
 	file, err := os.Open(path)
 	if err != nil {
@@ -265,7 +407,7 @@ func (t *Tar) tarFile(path string, i os.FileInfo) error {
 		return err
 	}
 	defer file.Close()
-	bytes, err := io.Copy(t.tw, file)
+	bytes, err := io.Copy(t.content(), file)
 	if err != nil {
 		err = fmt.Errorf("Cannot tar file '%s': %s", path, err.Error())
 		t.log.Error(err)
@@ -0,0 +1,141 @@
+package tar
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	log "kubefoundry/internal/log"
+)
+
+// testTree writes a folder with files, an executable and a symlink
+func testTree(t *testing.T) string {
+	dir := t.TempDir()
+	files := map[string]os.FileMode{"b.txt": 0600, "a/run.sh": 0750, "a/c.txt": 0664}
+	for name, mode := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(name+"\n"), mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink("b.txt", filepath.Join(dir, "link")); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+// pack returns the tar of the folder in reproducible mode and its digest
+func pack(t *testing.T, dir string, mtime time.Time) ([]byte, string) {
+	var buf bytes.Buffer
+	tr := NewTar(".", log.StandardLogger(), &buf)
+	tr.SetReproducible(mtime)
+	if err := tr.Add(context.Background(), dir, "app"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tr.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes(), fmt.Sprintf("sha256:%x", sha256.Sum256(buf.Bytes()))
+}
+
+func TestReproducible(t *testing.T) {
+	dir := testTree(t)
+	epoch := time.Unix(1700000000, 0)
+	content, digest := pack(t, dir, epoch)
+	// Other dates and permissions of the same tree
+	changed := time.Now().Add(-time.Hour)
+	for _, name := range []string{"b.txt", "a/c.txt", "a"} {
+		if err := os.Chtimes(filepath.Join(dir, name), changed, changed); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Chmod(filepath.Join(dir, "b.txt"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, again := pack(t, dir, epoch); again != digest {
+		t.Errorf("Expected the same tar %s packing the same tree, got %s", digest, again)
+	}
+	if _, other := pack(t, dir, epoch.Add(time.Second)); other == digest {
+		t.Errorf("Expected other tar with other SOURCE_DATE_EPOCH, got %s", other)
+	}
+	expected := []struct {
+		name string
+		mode int64
+	}{
+		{name: "app", mode: 0755},
+		{name: "app/a", mode: 0755},
+		{name: "app/a/c.txt", mode: 0644},
+		{name: "app/a/run.sh", mode: 0755},
+		{name: "app/b.txt", mode: 0644},
+		{name: "app/link", mode: 0777},
+	}
+	reader := tar.NewReader(bytes.NewReader(content))
+	for i := 0; ; i++ {
+		header, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			if i != len(expected) {
+				t.Errorf("Expected %d entries, got %d", len(expected), i)
+			}
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		if i >= len(expected) {
+			t.Fatalf("Unexpected entry %s", header.Name)
+		}
+		if header.Name != expected[i].name && header.Name != expected[i].name+"/" {
+			t.Errorf("Expected entry %s in position %d, got %s", expected[i].name, i, header.Name)
+		}
+		if header.Mode != expected[i].mode {
+			t.Errorf("Expected mode %o of %s, got %o", expected[i].mode, header.Name, header.Mode)
+		}
+		if !header.ModTime.Equal(epoch) {
+			t.Errorf("Expected date %s of %s, got %s", epoch, header.Name, header.ModTime)
+		}
+		if header.Uid != 0 || header.Gid != 0 || header.Uname != "" || header.Gname != "" {
+			t.Errorf("Expected no owner of %s, got %d:%d (%s:%s)", header.Name, header.Uid, header.Gid, header.Uname, header.Gname)
+		}
+	}
+}
+
+func TestSetDigest(t *testing.T) {
+	dir := testTree(t)
+	digest := func() string {
+		h := sha256.New()
+		tr := NewTar(".", log.StandardLogger(), ioutil.Discard)
+		tr.SetDigest(h)
+		if err := tr.Add(context.Background(), dir, "app"); err != nil {
+			t.Fatal(err)
+		}
+		if err := tr.Close(); err != nil {
+			t.Fatal(err)
+		}
+		return fmt.Sprintf("sha256:%x", h.Sum(nil))
+	}
+	first := digest()
+	changed := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(filepath.Join(dir, "b.txt"), changed, changed); err != nil {
+		t.Fatal(err)
+	}
+	if again := digest(); again != first {
+		t.Errorf("Expected the same digest %s with other dates, got %s", first, again)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "b.txt"), []byte("changed\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if other := digest(); other == first {
+		t.Errorf("Expected other digest with other content, got %s", other)
+	}
+}